@@ -15,6 +15,7 @@ type Context struct {
 	GoContext  context.Context
 	logger     log.FieldLogger
 	baseLogger *log.Logger
+	spanID     string
 }
 
 // NewBaseContextWithReason creates a Context with a given logger
@@ -38,6 +39,13 @@ func CreateContextWithField(baseContext *Context, key string, value string, reas
 	return &Context{UUID: u, logger: logger, Reason: reason, GoContext: baseContext.GoContext, baseLogger: baseContext.baseLogger}
 }
 
+// RestoreContext recreates a Context with a known ID and reason, e.g. for the metadata of persisted store entries
+func RestoreContext(id string, reason string) *Context {
+	u, _ := uuid.Parse(id)
+	logger := log.StandardLogger()
+	return &Context{UUID: u, logger: logger.WithField("uuid", id), Reason: reason, GoContext: context.TODO(), baseLogger: logger}
+}
+
 // GetID returns the string represantation of the ID for this execution tree
 func (c *Context) GetID() string {
 	return c.UUID.String()
@@ -58,15 +66,44 @@ func (c *Context) Done() <-chan struct{} {
 }
 
 func (c *Context) ChildContextWithField(key string, value string) *Context {
-	return &Context{UUID: c.UUID, logger: c.logger.WithField(key, value), Reason: c.Reason, GoContext: c.GoContext, baseLogger: c.baseLogger}
+	return &Context{UUID: c.UUID, logger: c.logger.WithField(key, value), Reason: c.Reason, GoContext: c.GoContext, baseLogger: c.baseLogger, spanID: c.spanID}
+}
+
+// GetSpanID returns the ID of the innermost span of the trace this context belongs to, empty if there is none
+func (c *Context) GetSpanID() string {
+	return c.spanID
+}
+
+// ChildContextWithSpan creates a Context that belongs to the same execution tree but to a new span
+func (c *Context) ChildContextWithSpan(spanID string) *Context {
+	return &Context{UUID: c.UUID, logger: c.logger, Reason: c.Reason, GoContext: c.GoContext, baseLogger: c.baseLogger, spanID: spanID}
+}
+
+// ChildContextWithValue creates a Context that belongs to the same execution tree and carries the value for key, see context.WithValue
+func (c *Context) ChildContextWithValue(key any, value any) *Context {
+	goCtx := context.WithValue(c.GoContext, key, value)
+	return &Context{UUID: c.UUID, logger: c.logger, Reason: c.Reason, GoContext: goCtx, baseLogger: c.baseLogger, spanID: c.spanID}
+}
+
+// Value returns the value for key that was added to this Context or one of its parents with ChildContextWithValue
+func (c *Context) Value(key any) any {
+	return c.GoContext.Value(key)
 }
 
 func (c *Context) ChildContextWithTimeout(timeout time.Duration) (*Context, context.CancelFunc) {
 	goCtx, cancel := context.WithTimeout(c.GoContext, timeout)
-	ctx := &Context{UUID: c.UUID, logger: c.logger, Reason: c.Reason, GoContext: goCtx, baseLogger: c.baseLogger}
+	ctx := &Context{UUID: c.UUID, logger: c.logger, Reason: c.Reason, GoContext: goCtx, baseLogger: c.baseLogger, spanID: c.spanID}
 	return ctx, cancel
 }
 
+// DetachedChildContext creates a Context with a new ID that is not cancelled together with c, it can only be cancelled with the returned function
+func (c *Context) DetachedChildContext(key string, value string, reason string) (*Context, context.CancelFunc) {
+	u := uuid.New()
+	goCtx, cancel := context.WithCancel(context.WithoutCancel(c.GoContext))
+	logger := c.logger.WithField(key, value).WithField("uuid", u.String())
+	return &Context{UUID: u, logger: logger, Reason: reason, GoContext: goCtx, baseLogger: c.baseLogger}, cancel
+}
+
 func (c *Context) EnableDebugLogging() log.Level {
 	prevLevel := c.baseLogger.GetLevel()
 	if prevLevel != log.DebugLevel {
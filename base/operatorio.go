@@ -25,6 +25,7 @@ const (
 	Object        OutputT = "object"
 	Integer       OutputT = "integer"
 	FloatingPoint OutputT = "floating"
+	Skipped       OutputT = "skipped"
 )
 
 const MAXSTRINGLENGTH = 1024 * 10
@@ -55,6 +56,8 @@ func GetOutputT(typeDescription string) (OutputT, error) {
 		return FloatingPoint, nil
 	case "empty", "":
 		return Empty, nil
+	case "skipped":
+		return Skipped, nil
 	default:
 		return Empty, fmt.Errorf("Unknown output type: %s", typeDescription)
 	}
@@ -69,6 +72,7 @@ func GetPossibleOutputTypes() []string {
 		string(Object),
 		string(Integer),
 		string(FloatingPoint),
+		string(Skipped),
 	}
 }
 
@@ -85,6 +89,11 @@ func MakeEmptyOutput() *OperatorIO {
 	return &OperatorIO{OutputType: Empty, HTTPCode: 200, Output: nil}
 }
 
+// MakeSkippedOutput is the output of an operation that was not executed because of a condition, the reason is kept for display
+func MakeSkippedOutput(reason string, a ...interface{}) *OperatorIO {
+	return &OperatorIO{OutputType: Skipped, HTTPCode: http.StatusOK, Output: fmt.Sprintf(reason, a...)}
+}
+
 func MakeSprintfOutput(msg string, a ...interface{}) *OperatorIO {
 	return &OperatorIO{OutputType: PlainText, HTTPCode: 200, Output: fmt.Sprintf(msg, a...)}
 }
@@ -206,6 +215,12 @@ func MakeOutputWithGivenType(output interface{}, outputType OutputT) (*OperatorI
 			return nil, fmt.Errorf("expected error, got %T", output)
 		}
 		return MakeInternalServerErrorOutput(value), nil
+	case Skipped:
+		value, ok := output.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected string, got %T", output)
+		}
+		return MakeSkippedOutput("%s", value), nil
 	case Integer:
 		value, err := utils.ConvertToInt64(output)
 		if err != nil {
@@ -330,6 +345,8 @@ func (io *OperatorIO) GetBytes() ([]byte, error) {
 		return []byte(io.Output.(string)), nil
 	case Error:
 		return []byte(io.Output.(error).Error()), nil
+	case Skipped:
+		return make([]byte, 0), nil
 	default:
 		return json.MarshalIndent(io.Output, "", "  ")
 	}
@@ -345,6 +362,8 @@ func (io *OperatorIO) GetSize() (int, error) {
 		return len(io.Output.(string)), nil
 	case Error:
 		return len(io.Output.(error).Error()), nil
+	case Skipped:
+		return 0, nil
 	default:
 		return 0, fmt.Errorf("Size not available")
 	}
@@ -372,7 +391,7 @@ func (io *OperatorIO) GetObject() interface{} {
 func (io *OperatorIO) GetString() string {
 	var b []byte
 	switch io.OutputType {
-	case Empty:
+	case Empty, Skipped:
 		return ""
 	case Byte:
 		b = io.Output.([]byte)
@@ -439,6 +458,20 @@ func (io *OperatorIO) IsError() bool {
 	return io.OutputType == Error
 }
 
+// IsSkipped returns true if the output belongs to an operation that was not executed because of a condition
+func (io *OperatorIO) IsSkipped() bool {
+	return io.OutputType == Skipped
+}
+
+// GetSkipReason returns why the operation was skipped or "" if it was not skipped
+func (io *OperatorIO) GetSkipReason() string {
+	if !io.IsSkipped() {
+		return ""
+	}
+	reason, _ := io.Output.(string)
+	return reason
+}
+
 func (io *OperatorIO) IsPlain() bool {
 	return io.OutputType == PlainText
 }
@@ -465,7 +498,7 @@ func (io *OperatorIO) IsFormData() bool {
 
 func (io *OperatorIO) IsEmpty() bool {
 	switch io.OutputType {
-	case Empty:
+	case Empty, Skipped:
 		return true
 	case Byte:
 		return len(io.Output.([]byte)) == 0
@@ -496,6 +529,10 @@ func (oio *OperatorIO) WriteTo(bwriter io.Writer, maxLen int) (int, error) {
 		return fmt.Fprintf(bwriter, "Error Code: %v, %v", oio.HTTPCode, oio.Output.(error))
 	}
 
+	if oio.IsSkipped() {
+		return fmt.Fprintf(bwriter, "Skipped: %v", oio.GetSkipReason())
+	}
+
 	if oio.HTTPCode != 200 {
 		fmt.Fprintf(bwriter, "HTTP Code: %v, ", oio.HTTPCode)
 	}
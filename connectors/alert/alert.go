@@ -36,6 +36,16 @@ type AlertWithMetadata struct {
 	First        time.Time
 	Last         time.Time
 	SilenceUntil time.Time
+
+	Route            string `json:",omitempty"` // name of the route that notifies about the alert
+	RouteSince       time.Time
+	LastNotification time.Time
+
+	AcknowledgedBy string `json:",omitempty"` // somebody is taking care of the alert, it is not repeated or escalated anymore
+	AcknowledgedAt time.Time
+
+	InhibitedBy       string `json:",omitempty"` // full name of the alert that suppressed the triggers and notifications of this alert
+	InhibitedAfterSet bool   `json:",omitempty"` // the triggers had already been executed when the source became active
 }
 
 func (a *AlertWithMetadata) IsExpired() bool {
@@ -61,6 +71,9 @@ type ReadableAlert struct {
 	DurationSinceLast  time.Duration
 	SilenceDuration    time.Duration
 	ModifiedBy         string
+	Route              string
+	AcknowledgedBy     string
+	InhibitedBy        string
 }
 
 func (a *ReadableAlert) GetFullName() string {
@@ -68,7 +81,7 @@ func (a *ReadableAlert) GetFullName() string {
 }
 
 func NewReadableAlert(a AlertWithMetadata, modifiedBy string) ReadableAlert {
-	r := ReadableAlert{Name: a.Name, Category: a.Category, Severity: a.Severity, Counter: a.Counter, ModifiedBy: modifiedBy}
+	r := ReadableAlert{Name: a.Name, Category: a.Category, Severity: a.Severity, Counter: a.Counter, ModifiedBy: modifiedBy, Route: a.Route, AcknowledgedBy: a.AcknowledgedBy, InhibitedBy: a.InhibitedBy}
 	if a.Desc != nil {
 		r.Desc = *a.Desc
 	}
@@ -3,4 +3,6 @@ package opalert
 type AlertConfig struct {
 	Enabled           bool
 	SeverityOverrides map[string]int
+	Routes            []AlertRoute     // the first matching route notifies about a new alert
+	Inhibitions       []InhibitionRule // alerts matching a rule do not execute triggers while a source alert of the rule is active
 }
@@ -0,0 +1,210 @@
+package opalert
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+	freepsstore "github.com/hannesrauhe/freeps/connectors/store"
+)
+
+const alertHistoryNamespace = "_alert_history"
+
+// AlertEvent is an entry in the alert history
+type AlertEvent struct {
+	Time       time.Time
+	Event      string // set, reset, silence, resetSilence or acknowledge
+	Name       string
+	Category   string
+	Severity   int
+	Desc       string        `json:",omitempty"`
+	Duration   time.Duration `json:",omitempty"` // the silence duration, or the time since the alert was set for reset and acknowledge
+	By         string        `json:",omitempty"` // who acknowledged the alert
+	ModifiedBy string
+}
+
+// GetFullName returns category and name of the alert
+func (e *AlertEvent) GetFullName() string {
+	return getAlertName(e.Name, e.Category)
+}
+
+// getHistoryNamespace returns the log namespace of the alert history, it is kept across restarts unless the store config says otherwise
+func getHistoryNamespace() (freepsstore.StoreNamespace, error) {
+	return freepsstore.GetGlobalStore().GetNamespaceWithDefault(alertHistoryNamespace, freepsstore.StoreNamespaceConfig{NamespaceType: "log", AutoTrim: 10000, PersistInterval: time.Minute})
+}
+
+// recordEvent appends an event to the alert history
+func (oc *OpAlert) recordEvent(ctx *base.Context, event string, a AlertWithMetadata, duration time.Duration, by string) {
+	ns, err := getHistoryNamespace()
+	if err != nil {
+		ctx.GetLogger().Errorf("Cannot record alert history: %v", err)
+		return
+	}
+	e := AlertEvent{Time: time.Now(), Event: event, Name: a.Name, Category: a.Category, Severity: a.Severity, Duration: duration, By: by, ModifiedBy: ctx.GetID()}
+	if a.Desc != nil {
+		e.Desc = *a.Desc
+	}
+	ns.SetValue("", base.MakeObjectOutput(e), ctx)
+}
+
+// AlertHistoryArgs selects events from the alert history
+type AlertHistoryArgs struct {
+	Name     *string
+	Category *string
+	Event    *string
+	MaxAge   *time.Duration
+	Limit    *int // 100 if not set
+}
+
+// EventSuggestions returns the kinds of events
+func (ah *AlertHistoryArgs) EventSuggestions() []string {
+	return []string{"set", "reset", "silence", "resetSilence", "acknowledge"}
+}
+
+// getHistory returns all events matching the args, the newest first
+func (oc *OpAlert) getHistory(args AlertHistoryArgs) ([]AlertEvent, error) {
+	ns, err := getHistoryNamespace()
+	if err != nil {
+		return nil, err
+	}
+	limit := 100
+	if args.Limit != nil {
+		limit = *args.Limit
+	}
+	events := []AlertEvent{}
+	// the keys of a log namespace are increasing numbers
+	keys := []int{}
+	for _, k := range ns.GetKeys() {
+		if i, err := strconv.Atoi(k); err == nil {
+			keys = append(keys, i)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(keys)))
+	for _, k := range keys {
+		if limit > 0 && len(events) >= limit {
+			break
+		}
+		var e AlertEvent
+		if ns.GetValue(strconv.Itoa(k)).ParseJSON(&e) != nil {
+			continue
+		}
+		if args.MaxAge != nil && time.Since(e.Time) > *args.MaxAge {
+			break
+		}
+		if args.Name != nil && *args.Name != "" && e.Name != *args.Name {
+			continue
+		}
+		if args.Category != nil && *args.Category != "" && e.Category != *args.Category {
+			continue
+		}
+		if args.Event != nil && *args.Event != "" && e.Event != *args.Event {
+			continue
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// GetHistory returns the set, reset, silence and acknowledge events of alerts, the newest first
+func (oc *OpAlert) GetHistory(ctx *base.Context, mainInput *base.OperatorIO, args AlertHistoryArgs) *base.OperatorIO {
+	events, err := oc.getHistory(args)
+	if err != nil {
+		return base.MakeOutputError(http.StatusInternalServerError, "Error getting alert history: %v", err)
+	}
+	return base.MakeObjectOutput(events)
+}
+
+// AlertStatistic summarizes the history of a single alert
+type AlertStatistic struct {
+	Name                  string
+	Category              string
+	Activations           int
+	Resets                int
+	Acknowledgements      int
+	MeanTimeToReset       time.Duration
+	MeanTimeToAcknowledge time.Duration
+	LastActivation        time.Time
+}
+
+// AlertStatistics summarizes the alert history
+type AlertStatistics struct {
+	Activations     int
+	MeanTimeToReset time.Duration
+	MostFrequent    []AlertStatistic // sorted by the number of activations
+}
+
+// AlertStatisticsArgs selects the part of the history the statistics are calculated for
+type AlertStatisticsArgs struct {
+	Category *string
+	MaxAge   *time.Duration
+	Top      *int // number of alerts in MostFrequent, 10 if not set
+}
+
+// GetStatistics returns the mean time to reset and the most frequent alerts in the alert history
+func (oc *OpAlert) GetStatistics(ctx *base.Context, mainInput *base.OperatorIO, args AlertStatisticsArgs) *base.OperatorIO {
+	noLimit := 0
+	events, err := oc.getHistory(AlertHistoryArgs{Category: args.Category, MaxAge: args.MaxAge, Limit: &noLimit})
+	if err != nil {
+		return base.MakeOutputError(http.StatusInternalServerError, "Error getting alert history: %v", err)
+	}
+	perAlert := map[string]*AlertStatistic{}
+	resetDurations := map[string]time.Duration{}
+	ackDurations := map[string]time.Duration{}
+	stats := AlertStatistics{MostFrequent: []AlertStatistic{}}
+	var totalResetDuration time.Duration
+	totalResets := 0
+	for _, e := range events {
+		s, ok := perAlert[e.GetFullName()]
+		if !ok {
+			s = &AlertStatistic{Name: e.Name, Category: e.Category}
+			perAlert[e.GetFullName()] = s
+		}
+		switch e.Event {
+		case "set":
+			s.Activations++
+			stats.Activations++
+			if e.Time.After(s.LastActivation) {
+				s.LastActivation = e.Time
+			}
+		case "reset":
+			s.Resets++
+			resetDurations[e.GetFullName()] += e.Duration
+			totalResetDuration += e.Duration
+			totalResets++
+		case "acknowledge":
+			s.Acknowledgements++
+			ackDurations[e.GetFullName()] += e.Duration
+		}
+	}
+	if totalResets > 0 {
+		stats.MeanTimeToReset = totalResetDuration / time.Duration(totalResets)
+	}
+	for name, s := range perAlert {
+		if s.Resets > 0 {
+			s.MeanTimeToReset = resetDurations[name] / time.Duration(s.Resets)
+		}
+		if s.Acknowledgements > 0 {
+			s.MeanTimeToAcknowledge = ackDurations[name] / time.Duration(s.Acknowledgements)
+		}
+		if s.Activations > 0 {
+			stats.MostFrequent = append(stats.MostFrequent, *s)
+		}
+	}
+	sort.Slice(stats.MostFrequent, func(i, j int) bool {
+		a, b := stats.MostFrequent[i], stats.MostFrequent[j]
+		if a.Activations != b.Activations {
+			return a.Activations > b.Activations
+		}
+		return getAlertName(a.Name, a.Category) < getAlertName(b.Name, b.Category)
+	})
+	top := 10
+	if args.Top != nil {
+		top = *args.Top
+	}
+	if top >= 0 && len(stats.MostFrequent) > top {
+		stats.MostFrequent = stats.MostFrequent[:top]
+	}
+	return base.MakeObjectOutput(stats)
+}
@@ -0,0 +1,96 @@
+package opalert
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+	freepsstore "github.com/hannesrauhe/freeps/connectors/store"
+	"github.com/hannesrauhe/freeps/freepsflow"
+	"github.com/hannesrauhe/freeps/utils"
+	"github.com/sirupsen/logrus"
+	"gotest.tools/v3/assert"
+)
+
+func TestAlertHistory(t *testing.T) {
+	tdir := t.TempDir()
+	cr, err := utils.NewConfigReader(logrus.StandardLogger(), path.Join(tdir, "test_config.json"))
+	assert.NilError(t, err)
+	ctx := base.NewBaseContextWithReason(logrus.StandardLogger(), "")
+	ge := freepsflow.NewFlowEngine(ctx, cr, func() {})
+	ge.AddOperators(base.MakeFreepsOperators(&freepsstore.OpStore{CR: cr, GE: ge}, cr, ctx))
+	op := &OpAlert{CR: cr, GE: ge}
+	t.Cleanup(func() {
+		for _, name := range []string{"door", "window", "neverSet"} {
+			freepsstore.GetGlobalStore().GetNamespaceNoError("_alerts").DeleteValue(getAlertName(name, "history"))
+		}
+	})
+
+	category := "history"
+	getHistory := func(event string) []AlertEvent {
+		events := []AlertEvent{}
+		out := op.GetHistory(ctx, base.MakeEmptyOutput(), AlertHistoryArgs{Category: &category, Event: &event})
+		assert.NilError(t, out.ParseJSON(&events))
+		return events
+	}
+
+	op.SetAlert(ctx, base.MakeEmptyOutput(), Alert{Name: "door", Category: category, Severity: 2}, base.MakeEmptyFunctionArguments())
+	/* only the activation is recorded */
+	op.SetAlert(ctx, base.MakeEmptyOutput(), Alert{Name: "door", Category: category, Severity: 2}, base.MakeEmptyFunctionArguments())
+	op.SetAlert(ctx, base.MakeEmptyOutput(), Alert{Name: "window", Category: category, Severity: 3}, base.MakeEmptyFunctionArguments())
+	assert.Equal(t, len(getHistory("set")), 2)
+
+	/* acknowledge */
+	by := "alice"
+	out := op.AcknowledgeAlert(ctx, base.MakeEmptyOutput(), AcknowledgeAlertArgs{Name: "door", Category: category, By: &by})
+	assert.Assert(t, !out.IsError(), out.GetString())
+	a := ReadableAlert{}
+	assert.NilError(t, op.GetActiveAlert(ctx, base.MakeEmptyOutput(), IsActiveAlertArgs{Name: "door", Category: category}).ParseJSON(&a))
+	assert.Equal(t, a.AcknowledgedBy, "alice")
+	out = op.AcknowledgeAlert(ctx, base.MakeEmptyOutput(), AcknowledgeAlertArgs{Name: "unknown", Category: category})
+	assert.Equal(t, out.HTTPCode, 404)
+	acks := getHistory("acknowledge")
+	assert.Equal(t, len(acks), 1)
+	assert.Equal(t, acks[0].By, "alice")
+	assert.Equal(t, acks[0].ModifiedBy, ctx.GetID())
+
+	op.SilenceAlert(ctx, base.MakeEmptyOutput(), SilenceAlertArgs{Name: "window", Category: category, SilenceDuration: time.Hour})
+	silences := getHistory("silence")
+	assert.Equal(t, len(silences), 1)
+	assert.Equal(t, silences[0].Duration, time.Hour)
+
+	/* reset clears the acknowledgement, resetting an inactive alert is not recorded */
+	op.ResetAlert(ctx, base.MakeEmptyOutput(), ResetAlertArgs{Name: "door", Category: category})
+	op.ResetAlert(ctx, base.MakeEmptyOutput(), ResetAlertArgs{Name: "door", Category: category})
+	op.ResetAlert(ctx, base.MakeEmptyOutput(), ResetAlertArgs{Name: "neverSet", Category: category})
+	assert.Equal(t, len(getHistory("reset")), 1)
+	op.SetAlert(ctx, base.MakeEmptyOutput(), Alert{Name: "door", Category: category, Severity: 2}, base.MakeEmptyFunctionArguments())
+	assert.NilError(t, op.GetActiveAlert(ctx, base.MakeEmptyOutput(), IsActiveAlertArgs{Name: "door", Category: category}).ParseJSON(&a))
+	assert.Equal(t, a.AcknowledgedBy, "")
+
+	/* the newest event comes first */
+	events := getHistory("")
+	assert.Equal(t, len(events), 6)
+	assert.Equal(t, events[0].Event, "set")
+	assert.Equal(t, events[0].Name, "door")
+	assert.Equal(t, events[len(events)-1].Name, "door")
+	limit := 2
+	out = op.GetHistory(ctx, base.MakeEmptyOutput(), AlertHistoryArgs{Category: &category, Limit: &limit})
+	assert.NilError(t, out.ParseJSON(&events))
+	assert.Equal(t, len(events), 2)
+
+	stats := AlertStatistics{}
+	assert.NilError(t, op.GetStatistics(ctx, base.MakeEmptyOutput(), AlertStatisticsArgs{Category: &category}).ParseJSON(&stats))
+	assert.Equal(t, stats.Activations, 3)
+	assert.Assert(t, stats.MeanTimeToReset > 0)
+	assert.Equal(t, len(stats.MostFrequent), 2)
+	assert.Equal(t, stats.MostFrequent[0].Name, "door")
+	assert.Equal(t, stats.MostFrequent[0].Activations, 2)
+	assert.Equal(t, stats.MostFrequent[0].Resets, 1)
+	assert.Equal(t, stats.MostFrequent[0].Acknowledgements, 1)
+	assert.Equal(t, stats.MostFrequent[1].Name, "window")
+	top := 1
+	assert.NilError(t, op.GetStatistics(ctx, base.MakeEmptyOutput(), AlertStatisticsArgs{Category: &category, Top: &top}).ParseJSON(&stats))
+	assert.Equal(t, len(stats.MostFrequent), 1)
+}
@@ -0,0 +1,205 @@
+package opalert
+
+import (
+	"path"
+	"sort"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+	freepsstore "github.com/hannesrauhe/freeps/connectors/store"
+)
+
+// InhibitionRule suppresses the triggers and notifications of target alerts while a matching source alert is active
+type InhibitionRule struct {
+	SourceCategory    string `json:",omitempty"` // all categories if empty
+	SourceNamePattern string `json:",omitempty"` // glob pattern as in path.Match, all names if empty
+	TargetCategory    string `json:",omitempty"` // all categories if empty
+	TargetNamePattern string `json:",omitempty"` // glob pattern as in path.Match, all names if empty
+}
+
+func matchesAlert(category string, namePattern string, a *AlertWithMetadata) bool {
+	if category != "" && category != a.Category {
+		return false
+	}
+	if namePattern != "" {
+		match, _ := path.Match(namePattern, a.Name)
+		return match
+	}
+	return true
+}
+
+// inhibits returns true if the active alert source suppresses the alert target
+func (r *InhibitionRule) inhibits(source *AlertWithMetadata, target *AlertWithMetadata) bool {
+	if source.GetFullName() == target.GetFullName() {
+		return false
+	}
+	return matchesAlert(r.SourceCategory, r.SourceNamePattern, source) && matchesAlert(r.TargetCategory, r.TargetNamePattern, target)
+}
+
+// validateInhibitions returns all rules that can be used and logs the invalid ones
+func validateInhibitions(ctx *base.Context, rules []InhibitionRule) []InhibitionRule {
+	valid := []InhibitionRule{}
+	for i, r := range rules {
+		if r.SourceCategory == "" && r.SourceNamePattern == "" {
+			ctx.GetLogger().Errorf("Inhibition rule %d is ignored: every alert would be a source", i)
+			continue
+		}
+		if r.TargetCategory == "" && r.TargetNamePattern == "" {
+			ctx.GetLogger().Errorf("Inhibition rule %d is ignored: every alert would be a target", i)
+			continue
+		}
+		_, errSource := path.Match(r.SourceNamePattern, "")
+		_, errTarget := path.Match(r.TargetNamePattern, "")
+		if errSource != nil || errTarget != nil {
+			ctx.GetLogger().Errorf("Inhibition rule %d is ignored: invalid name pattern", i)
+			continue
+		}
+		valid = append(valid, r)
+	}
+	return valid
+}
+
+// getAllAlerts returns all alerts in the store including expired ones
+func (oc *OpAlert) getAllAlerts() map[string]AlertWithMetadata {
+	alerts := map[string]AlertWithMetadata{}
+	ns, err := freepsstore.GetGlobalStore().GetNamespace("_alerts")
+	if err != nil {
+		return alerts
+	}
+	for _, key := range ns.GetKeys() {
+		var a AlertWithMetadata
+		if ns.GetValue(key).ParseJSON(&a) == nil {
+			alerts[a.GetFullName()] = a
+		}
+	}
+	return alerts
+}
+
+// getInhibitor returns the full name of an active alert that inhibits a or an empty string if there is none
+func (oc *OpAlert) getInhibitor(a *AlertWithMetadata, alerts map[string]AlertWithMetadata) string {
+	names := make([]string, 0, len(alerts))
+	for name := range alerts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, r := range oc.inhibitions {
+		for _, name := range names {
+			source := alerts[name]
+			if !source.IsExpired() && r.inhibits(&source, a) {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// isInhibited returns true if the inhibitor of a that was determined when it was set is still active
+func isInhibited(a *AlertWithMetadata, alerts map[string]AlertWithMetadata) bool {
+	if a.InhibitedBy == "" {
+		return false
+	}
+	source, ok := alerts[a.InhibitedBy]
+	return ok && !source.IsExpired()
+}
+
+// releaseInhibited executes the triggers and notifications of the active alerts that have been inhibited by the alert source that was just reset
+func (oc *OpAlert) releaseInhibited(ctx *base.Context, source AlertWithMetadata) {
+	alerts := oc.getAllAlerts()
+	for _, target := range alerts {
+		if target.InhibitedBy == source.GetFullName() && !target.IsExpired() {
+			oc.release(ctx, target, alerts)
+		}
+	}
+}
+
+// releaseExpiredInhibitions executes the triggers and notifications of the active alerts whose source expired instead of being reset
+func (oc *OpAlert) releaseExpiredInhibitions(ctx *base.Context) {
+	alerts := oc.getAllAlerts()
+	for _, target := range alerts {
+		if target.InhibitedBy != "" && !target.IsExpired() && !isInhibited(&target, alerts) {
+			oc.release(ctx, target, alerts)
+		}
+	}
+}
+
+// release executes the triggers and the notification of target unless another active alert inhibits it
+func (oc *OpAlert) release(ctx *base.Context, target AlertWithMetadata, alerts map[string]AlertWithMetadata) {
+	released := false
+	alreadySet := false
+	notify := false
+	var route *AlertRoute
+	a, err := oc.updateAlert(ctx, target.Category, target.Name, func(a *AlertWithMetadata, newAlert bool) {
+		if a.InhibitedBy != target.InhibitedBy || a.IsExpired() {
+			return
+		}
+		// the alert might still be inhibited by another source
+		a.InhibitedBy = oc.getInhibitor(a, alerts)
+		if a.InhibitedBy != "" {
+			return
+		}
+		released = true
+		alreadySet = a.InhibitedAfterSet
+		a.InhibitedAfterSet = false
+		route = oc.getRoute(a.Route)
+		if alreadySet {
+			// repeats and escalations continue, the alert has been notified before it was inhibited
+			if route != nil {
+				a.RouteSince = time.Now()
+			}
+			return
+		}
+		if route != nil {
+			a.RouteSince = time.Now()
+			notify = !a.IsSilenced()
+			if notify {
+				a.LastNotification = a.RouteSince
+			}
+		}
+	})
+	if err != nil {
+		ctx.GetLogger().Errorf("Cannot release inhibited alert \"%v\": %v", target.GetFullName(), err)
+		return
+	}
+	if !released || alreadySet {
+		return
+	}
+	oc.execTriggers(ctx, a)
+	if notify {
+		oc.notify(ctx, route, a, "set")
+	}
+}
+
+// inhibitActive marks the active alerts that have been set before the source became active as inhibited, so that they are
+// not repeated or escalated anymore
+func (oc *OpAlert) inhibitActive(ctx *base.Context, source AlertWithMetadata) {
+	for _, target := range oc.getAllAlerts() {
+		if target.InhibitedBy != "" || target.IsExpired() || !oc.isInhibitedBy(&source, &target) {
+			continue
+		}
+		_, err := oc.updateAlert(ctx, target.Category, target.Name, func(a *AlertWithMetadata, newAlert bool) {
+			if newAlert || a.InhibitedBy != "" || a.IsExpired() {
+				return
+			}
+			a.InhibitedBy = source.GetFullName()
+			a.InhibitedAfterSet = true
+		})
+		if err != nil {
+			ctx.GetLogger().Errorf("Cannot inhibit alert \"%v\": %v", target.GetFullName(), err)
+		}
+	}
+}
+
+// isInhibitedBy returns true if any of the rules lets source suppress target
+func (oc *OpAlert) isInhibitedBy(source *AlertWithMetadata, target *AlertWithMetadata) bool {
+	for _, r := range oc.inhibitions {
+		if r.inhibits(source, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetInhibitions returns the configured inhibition rules
+func (oc *OpAlert) GetInhibitions(ctx *base.Context, mainInput *base.OperatorIO) *base.OperatorIO {
+	return base.MakeObjectOutput(oc.inhibitions)
+}
@@ -0,0 +1,237 @@
+package opalert
+
+import (
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+	freepsstore "github.com/hannesrauhe/freeps/connectors/store"
+	freepsutils "github.com/hannesrauhe/freeps/connectors/utils"
+	"github.com/hannesrauhe/freeps/freepsflow"
+	"github.com/hannesrauhe/freeps/utils"
+	"github.com/sirupsen/logrus"
+	"gotest.tools/v3/assert"
+)
+
+func TestInhibition(t *testing.T) {
+	tdir := t.TempDir()
+	cr, err := utils.NewConfigReader(logrus.StandardLogger(), path.Join(tdir, "test_config.json"))
+	assert.NilError(t, err)
+	ctx := base.NewBaseContextWithReason(logrus.StandardLogger(), "")
+	ge := freepsflow.NewFlowEngine(ctx, cr, func() {})
+	for _, op := range []base.FreepsOperator{&freepsstore.OpStore{CR: cr, GE: ge}, &freepsutils.OpUtils{}} {
+		ge.AddOperators(base.MakeFreepsOperators(op, cr, ctx))
+	}
+	op := &OpAlert{CR: cr, GE: ge, inhibitions: validateInhibitions(ctx, []InhibitionRule{
+		{SourceCategory: "fritz", SourceNamePattern: "Unreachable", TargetCategory: "fritz"},
+		{SourceCategory: "fritz", SourceNamePattern: "Unreachable", TargetNamePattern: "LongLoop*"},
+		{TargetCategory: "fritz"},
+	})}
+	t.Cleanup(func() {
+		ns := freepsstore.GetGlobalStore().GetNamespaceNoError("_alerts")
+		for _, k := range ns.GetKeys() {
+			ns.DeleteValue(k)
+		}
+	})
+	inhibitions := []InhibitionRule{}
+	assert.NilError(t, op.GetInhibitions(ctx, base.MakeEmptyOutput()).ParseJSON(&inhibitions))
+	assert.Equal(t, len(inhibitions), 2)
+
+	for _, name := range []string{"fritz.Unreachable", "fritz.host1", "fritz.host2", "system.LongLoopDuration", "house.door"} {
+		assert.NilError(t, ge.AddFlow(ctx, name, createTestFlow(name), false))
+		assert.Assert(t, !op.SetAlertSetTrigger(ctx, base.MakeEmptyOutput(), NameTrigger{Name: name, FlowID: name}).IsError())
+	}
+	triggered := freepsstore.GetGlobalStore().GetNamespaceNoError("test")
+	wasTriggered := func(name string) bool {
+		found := triggered.GetValue(name) != freepsstore.NotFoundEntry
+		triggered.DeleteValue(name)
+		return found
+	}
+	setAlert := func(category string, name string) {
+		op.SetAlert(ctx, base.MakeEmptyOutput(), Alert{Name: name, Category: category, Severity: 2}, base.MakeEmptyFunctionArguments())
+	}
+
+	setAlert("fritz", "Unreachable")
+	assert.Assert(t, wasTriggered("fritz.Unreachable"))
+	setAlert("fritz", "host1")
+	setAlert("system", "LongLoopDuration")
+	setAlert("house", "door")
+	assert.Assert(t, !wasTriggered("fritz.host1"))
+	assert.Assert(t, !wasTriggered("system.LongLoopDuration"))
+	assert.Assert(t, wasTriggered("house.door"))
+
+	/* inhibited alerts are still recorded and marked */
+	alerts := map[string]ReadableAlert{}
+	assert.NilError(t, op.GetAlerts(ctx, base.MakeEmptyOutput(), GetAlertArgs{}).ParseJSON(&alerts))
+	assert.Equal(t, len(alerts), 4)
+	assert.Equal(t, alerts["fritz.host1"].InhibitedBy, "fritz.Unreachable")
+	assert.Equal(t, alerts["system.LongLoopDuration"].InhibitedBy, "fritz.Unreachable")
+	assert.Equal(t, alerts["fritz.Unreachable"].InhibitedBy, "")
+	assert.Equal(t, alerts["house.door"].InhibitedBy, "")
+	category := "fritz"
+	out := op.GetShortAlertString(ctx, base.MakeEmptyOutput(), GetAlertArgs{Category: &category})
+	assert.Assert(t, strings.HasPrefix(out.GetString(), "2 fritz alerts (1 inhibited): "), out.GetString())
+	assert.Assert(t, strings.Contains(out.GetString(), "host1(inhibited)"), out.GetString())
+
+	/* resetting an inhibited alert does not execute the reset triggers */
+	assert.Assert(t, !op.SetAlertResetTrigger(ctx, base.MakeEmptyOutput(), NameTrigger{Name: "system.LongLoopDuration", FlowID: "system.LongLoopDuration"}).IsError())
+	op.ResetAlert(ctx, base.MakeEmptyOutput(), ResetAlertArgs{Name: "LongLoopDuration", Category: "system"})
+	assert.Assert(t, !wasTriggered("system.LongLoopDuration"))
+
+	/* alerts that are still active execute their triggers when the source is reset */
+	op.ResetAlert(ctx, base.MakeEmptyOutput(), ResetAlertArgs{Name: "Unreachable", Category: "fritz"})
+	assert.Assert(t, wasTriggered("fritz.host1"))
+	setAlert("fritz", "host2")
+	assert.Assert(t, wasTriggered("fritz.host2"))
+	assert.NilError(t, op.GetAlerts(ctx, base.MakeEmptyOutput(), GetAlertArgs{Category: &category}).ParseJSON(&alerts))
+	assert.Equal(t, alerts["fritz.host1"].InhibitedBy, "")
+	out = op.GetShortAlertString(ctx, base.MakeEmptyOutput(), GetAlertArgs{Category: &category})
+	assert.Assert(t, strings.HasPrefix(out.GetString(), "2 fritz alerts: "), out.GetString())
+	assert.Assert(t, !strings.Contains(out.GetString(), "inhibited"), out.GetString())
+}
+
+func TestInhibitionOfEarlierAlerts(t *testing.T) {
+	tdir := t.TempDir()
+	cr, err := utils.NewConfigReader(logrus.StandardLogger(), path.Join(tdir, "test_config.json"))
+	assert.NilError(t, err)
+	ctx := base.NewBaseContextWithReason(logrus.StandardLogger(), "")
+	ge := freepsflow.NewFlowEngine(ctx, cr, func() {})
+	for _, op := range []base.FreepsOperator{&freepsstore.OpStore{CR: cr, GE: ge}, &freepsutils.OpUtils{}} {
+		ge.AddOperators(base.MakeFreepsOperators(op, cr, ctx))
+	}
+	assert.NilError(t, ge.AddFlow(ctx, "notifyFritz", createNotificationFlow("fritz"), false))
+	op := &OpAlert{CR: cr, GE: ge,
+		inhibitions: validateInhibitions(ctx, []InhibitionRule{{SourceCategory: "fritz", SourceNamePattern: "Unreachable", TargetCategory: "fritz"}}),
+		routes:      validateRoutes(ctx, []AlertRoute{{Name: "fritz", Category: "fritz", FlowID: "notifyFritz", RepeatInterval: time.Minute}}),
+	}
+	t.Cleanup(func() {
+		ns := freepsstore.GetGlobalStore().GetNamespaceNoError("_alerts")
+		for _, k := range ns.GetKeys() {
+			ns.DeleteValue(k)
+		}
+	})
+
+	for _, name := range []string{"fritz.host1", "fritz.host2"} {
+		assert.NilError(t, ge.AddFlow(ctx, name, createTestFlow(name), false))
+		assert.Assert(t, !op.SetAlertSetTrigger(ctx, base.MakeEmptyOutput(), NameTrigger{Name: name, FlowID: name}).IsError())
+		assert.Assert(t, !op.SetAlertResetTrigger(ctx, base.MakeEmptyOutput(), NameTrigger{Name: name, FlowID: name}).IsError())
+	}
+	triggered := freepsstore.GetGlobalStore().GetNamespaceNoError("test")
+	wasTriggered := func(name string) bool {
+		found := triggered.GetValue(name) != freepsstore.NotFoundEntry
+		triggered.DeleteValue(name)
+		return found
+	}
+	notifications := freepsstore.GetGlobalStore().GetNamespaceNoError("notifications")
+	setAlert := func(name string) {
+		op.SetAlert(ctx, base.MakeEmptyOutput(), Alert{Name: name, Category: "fritz", Severity: 2}, base.MakeEmptyFunctionArguments())
+	}
+
+	/* the targets are set before the source */
+	setAlert("host1")
+	setAlert("host2")
+	assert.Assert(t, wasTriggered("fritz.host1"))
+	assert.Assert(t, wasTriggered("fritz.host2"))
+	notifications.DeleteValue("fritz")
+	setAlert("Unreachable")
+	notifications.DeleteValue("fritz")
+
+	alerts := map[string]ReadableAlert{}
+	category := "fritz"
+	assert.NilError(t, op.GetAlerts(ctx, base.MakeEmptyOutput(), GetAlertArgs{Category: &category}).ParseJSON(&alerts))
+	assert.Equal(t, alerts["fritz.host1"].InhibitedBy, "fritz.Unreachable")
+	assert.Equal(t, alerts["fritz.host2"].InhibitedBy, "fritz.Unreachable")
+	assert.Equal(t, alerts["fritz.Unreachable"].InhibitedBy, "")
+
+	/* inhibited alerts are not repeated, only the source is */
+	later := time.Now().Add(2 * time.Minute)
+	all := op.getAllAlerts()
+	for _, name := range []string{"fritz.host1", "fritz.host2"} {
+		a := all[name]
+		route, _ := op.nextNotification(&a, later)
+		assert.Assert(t, route == nil, name)
+	}
+	source := all["fritz.Unreachable"]
+	_, notification := op.nextNotification(&source, later)
+	assert.Equal(t, notification, "repeat")
+
+	/* the reset triggers of an alert that has been triggered before it was inhibited are executed */
+	op.ResetAlert(ctx, base.MakeEmptyOutput(), ResetAlertArgs{Name: "host1", Category: "fritz"})
+	assert.Assert(t, wasTriggered("fritz.host1"))
+
+	/* releasing an alert that has been triggered before it was inhibited does not trigger it again */
+	op.ResetAlert(ctx, base.MakeEmptyOutput(), ResetAlertArgs{Name: "Unreachable", Category: "fritz"})
+	assert.Assert(t, !wasTriggered("fritz.host2"))
+	assert.NilError(t, op.GetAlerts(ctx, base.MakeEmptyOutput(), GetAlertArgs{Category: &category}).ParseJSON(&alerts))
+	assert.Equal(t, alerts["fritz.host2"].InhibitedBy, "")
+}
+
+func TestInhibitionByExpiredSource(t *testing.T) {
+	tdir := t.TempDir()
+	cr, err := utils.NewConfigReader(logrus.StandardLogger(), path.Join(tdir, "test_config.json"))
+	assert.NilError(t, err)
+	ctx := base.NewBaseContextWithReason(logrus.StandardLogger(), "")
+	ge := freepsflow.NewFlowEngine(ctx, cr, func() {})
+	for _, op := range []base.FreepsOperator{&freepsstore.OpStore{CR: cr, GE: ge}, &freepsutils.OpUtils{}} {
+		ge.AddOperators(base.MakeFreepsOperators(op, cr, ctx))
+	}
+	assert.NilError(t, ge.AddFlow(ctx, "notifyFritz", createNotificationFlow("fritz"), false))
+	op := &OpAlert{CR: cr, GE: ge,
+		inhibitions: validateInhibitions(ctx, []InhibitionRule{{SourceCategory: "fritz", SourceNamePattern: "Unreachable", TargetCategory: "fritz"}}),
+		routes:      validateRoutes(ctx, []AlertRoute{{Name: "fritz", Category: "fritz", FlowID: "notifyFritz"}}),
+	}
+	t.Cleanup(func() {
+		ns := freepsstore.GetGlobalStore().GetNamespaceNoError("_alerts")
+		for _, k := range ns.GetKeys() {
+			ns.DeleteValue(k)
+		}
+	})
+
+	assert.NilError(t, ge.AddFlow(ctx, "fritz.host1", createTestFlow("fritz.host1"), false))
+	assert.Assert(t, !op.SetAlertSetTrigger(ctx, base.MakeEmptyOutput(), NameTrigger{Name: "fritz.host1", FlowID: "fritz.host1"}).IsError())
+	triggered := freepsstore.GetGlobalStore().GetNamespaceNoError("test")
+	wasTriggered := func(name string) bool {
+		found := triggered.GetValue(name) != freepsstore.NotFoundEntry
+		triggered.DeleteValue(name)
+		return found
+	}
+	notifications := freepsstore.GetGlobalStore().GetNamespaceNoError("notifications")
+	lastNotification := func() map[string]string {
+		args := map[string]string{}
+		e := notifications.GetValue("fritz")
+		if e == freepsstore.NotFoundEntry {
+			return args
+		}
+		assert.NilError(t, e.ParseJSON(&args))
+		notifications.DeleteValue("fritz")
+		return args
+	}
+
+	expiresIn := 50 * time.Millisecond
+	op.SetAlert(ctx, base.MakeEmptyOutput(), Alert{Name: "Unreachable", Category: "fritz", Severity: 2, ExpiresInDuration: &expiresIn}, base.MakeEmptyFunctionArguments())
+	lastNotification()
+	op.SetAlert(ctx, base.MakeEmptyOutput(), Alert{Name: "host1", Category: "fritz", Severity: 2}, base.MakeEmptyFunctionArguments())
+	assert.Assert(t, !wasTriggered("fritz.host1"))
+	assert.Equal(t, len(lastNotification()), 0)
+
+	/* nothing is released while the source is active */
+	op.processRoutes(ctx, time.Now())
+	assert.Assert(t, !wasTriggered("fritz.host1"))
+	assert.Equal(t, op.getAllAlerts()["fritz.host1"].InhibitedBy, "fritz.Unreachable")
+
+	/* the target is released once the source expired instead of being reset */
+	time.Sleep(2 * expiresIn)
+	op.processRoutes(ctx, time.Now())
+	assert.Assert(t, wasTriggered("fritz.host1"))
+	n := lastNotification()
+	assert.Equal(t, n["notification"], "set")
+	assert.Equal(t, n["Name"], "host1")
+	assert.Equal(t, op.getAllAlerts()["fritz.host1"].InhibitedBy, "")
+
+	/* and only once */
+	op.processRoutes(ctx, time.Now())
+	assert.Assert(t, !wasTriggered("fritz.host1"))
+	assert.Equal(t, len(lastNotification()), 0)
+}
@@ -19,19 +19,23 @@ type OpAlert struct {
 	GE                *freepsflow.FlowEngine
 	config            AlertConfig
 	severityOverrides utils.CIMap[int]
+	routes            []AlertRoute
+	inhibitions       []InhibitionRule
+	stopRouting       chan struct{}
 }
 
 var _ base.FreepsOperator = &OpAlert{}
 var _ base.FreepsOperatorWithConfig = &OpAlert{}
+var _ base.FreepsOperatorWithShutdown = &OpAlert{}
 
 func (oc *OpAlert) GetDefaultConfig() interface{} {
-	cfg := AlertConfig{Enabled: true, SeverityOverrides: map[string]int{}}
+	cfg := AlertConfig{Enabled: true, SeverityOverrides: map[string]int{}, Routes: []AlertRoute{}, Inhibitions: []InhibitionRule{}}
 	return &cfg
 }
 
 func (oc *OpAlert) InitCopyOfOperator(ctx *base.Context, config interface{}, name string) (base.FreepsOperatorWithConfig, error) {
 	opc := config.(*AlertConfig)
-	return &OpAlert{CR: oc.CR, GE: oc.GE, config: *opc, severityOverrides: utils.NewCIMap(opc.SeverityOverrides)}, nil
+	return &OpAlert{CR: oc.CR, GE: oc.GE, config: *opc, severityOverrides: utils.NewCIMap(opc.SeverityOverrides), routes: validateRoutes(ctx, opc.Routes), inhibitions: validateInhibitions(ctx, opc.Inhibitions)}, nil
 }
 
 func (oc *OpAlert) SeveritySuggestions() []string {
@@ -114,6 +118,12 @@ func (oc *OpAlert) SetAlert(ctx *base.Context, mainInput *base.OperatorIO, args
 		args.Severity = oc.severityOverrides.GetOrDefault(args.GetFullName(), args.Severity)
 	}
 
+	var alerts map[string]AlertWithMetadata
+	if len(oc.inhibitions) > 0 {
+		alerts = oc.getAllAlerts()
+	}
+	var route *AlertRoute
+	notify := false
 	a, err := oc.updateAlert(ctx, args.Category, args.Name, func(a *AlertWithMetadata, newAlert bool) {
 		if newAlert {
 			a.First = time.Now()
@@ -127,14 +137,44 @@ func (oc *OpAlert) SetAlert(ctx *base.Context, mainInput *base.OperatorIO, args
 		a.Last = time.Now()
 
 		a.Alert = args
+
+		if execTrigger {
+			a.AcknowledgedBy = ""
+			a.AcknowledgedAt = time.Time{}
+			a.InhibitedBy = oc.getInhibitor(a, alerts)
+			a.InhibitedAfterSet = false
+			a.Route = ""
+			route = oc.selectRoute(a)
+			// the first notification might only be sent as a repeat once the alert is not silenced anymore
+			a.LastNotification = time.Time{}
+			if route != nil {
+				a.Route = route.Name
+				a.RouteSince = a.Last
+				notify = a.InhibitedBy == "" && !addArgs.Has("noTrigger") && !a.IsSilenced()
+				if notify {
+					a.LastNotification = a.Last
+				}
+			}
+		}
 	})
 
 	if err != nil {
 		return base.MakeOutputError(http.StatusInternalServerError, "Error setting alert: %v", err)
 	}
 
-	if execTrigger && !addArgs.Has("noTrigger") {
+	if execTrigger {
+		oc.recordEvent(ctx, "set", a, 0, "")
+		if len(oc.inhibitions) > 0 {
+			oc.inhibitActive(ctx, a)
+		}
+	}
+	if execTrigger && a.InhibitedBy != "" {
+		ctx.GetLogger().Debugf("Alert \"%v\" is inhibited by \"%v\"", a.GetFullName(), a.InhibitedBy)
+	} else if execTrigger && !addArgs.Has("noTrigger") {
 		oc.execTriggers(ctx, a)
+		if notify {
+			oc.notify(ctx, route, a, "set")
+		}
 	}
 	return base.MakeEmptyOutput()
 }
@@ -151,13 +191,16 @@ func (sa *SilenceAlertArgs) NameSuggestions(otherArgs base.FunctionArguments, oc
 
 // SilenceAlert keeps the alert from triggering for the given duration
 func (oc *OpAlert) SilenceAlert(ctx *base.Context, mainInput *base.OperatorIO, args SilenceAlertArgs) *base.OperatorIO {
-	_, err := oc.updateAlert(ctx, args.Category, args.Name, func(a *AlertWithMetadata, newAlert bool) {
+	a, err := oc.updateAlert(ctx, args.Category, args.Name, func(a *AlertWithMetadata, newAlert bool) {
 		a.SilenceUntil = time.Now().Add(args.SilenceDuration)
+		// the time until the alert is escalated starts again after the silence
+		a.RouteSince = a.SilenceUntil
 	})
 
 	if err != nil {
 		return base.MakeOutputError(http.StatusInternalServerError, "Error setting alert: %v", err)
 	}
+	oc.recordEvent(ctx, "silence", a, args.SilenceDuration, "")
 
 	return base.MakeEmptyOutput()
 }
@@ -174,6 +217,7 @@ func (ra *ResetAlertArgs) NameSuggestions(otherArgs base.FunctionArguments, oc *
 // ResetAlert deletes the alert and resets the counter
 func (oc *OpAlert) ResetAlert(ctx *base.Context, mainInput *base.OperatorIO, args ResetAlertArgs) *base.OperatorIO {
 	execTriggers := false
+	wasInhibited := false
 	a, err := oc.updateAlert(ctx, args.Category, args.Name, func(a *AlertWithMetadata, newAlert bool) {
 		if newAlert {
 			// after restarts, the alert might be reset, so we need to execute the triggers
@@ -183,6 +227,12 @@ func (oc *OpAlert) ResetAlert(ctx *base.Context, mainInput *base.OperatorIO, arg
 		if a.ExpiresInDuration == nil || !a.IsExpired() {
 			eTime := time.Now().Sub(a.Last)
 			a.ExpiresInDuration = &eTime
+			a.AcknowledgedBy = ""
+			a.AcknowledgedAt = time.Time{}
+			// the reset triggers are executed if the set triggers have been executed
+			wasInhibited = a.InhibitedBy != "" && !a.InhibitedAfterSet
+			a.InhibitedBy = ""
+			a.InhibitedAfterSet = false
 			execTriggers = true
 		}
 
@@ -193,20 +243,77 @@ func (oc *OpAlert) ResetAlert(ctx *base.Context, mainInput *base.OperatorIO, arg
 	}
 
 	if execTriggers {
-		oc.execTriggers(ctx, a)
+		if !a.First.IsZero() {
+			oc.recordEvent(ctx, "reset", a, time.Now().Sub(a.First), "")
+		}
+		// the triggers of an inhibited alert have not been executed when it was set
+		if !wasInhibited {
+			oc.execTriggers(ctx, a)
+			if route := oc.getRoute(a.Route); route != nil && route.NotifyReset && !a.IsSilenced() {
+				oc.notify(ctx, route, a, "reset")
+			}
+		}
+		if len(oc.inhibitions) > 0 {
+			oc.releaseInhibited(ctx, a)
+		}
 	}
 	return base.MakeEmptyOutput()
 }
 
 // ResetSilence stops ignoring alerts
 func (oc *OpAlert) ResetSilence(ctx *base.Context, mainInput *base.OperatorIO, args ResetAlertArgs) *base.OperatorIO {
-	_, err := oc.updateAlert(ctx, args.Category, args.Name, func(a *AlertWithMetadata, newAlert bool) {
+	a, err := oc.updateAlert(ctx, args.Category, args.Name, func(a *AlertWithMetadata, newAlert bool) {
 		a.SilenceUntil = time.Now()
 	})
 
 	if err != nil {
 		return base.MakeOutputError(http.StatusInternalServerError, "Error setting alert: %v", err)
 	}
+	oc.recordEvent(ctx, "resetSilence", a, 0, "")
+
+	return base.MakeEmptyOutput()
+}
+
+type AcknowledgeAlertArgs struct {
+	Name     string
+	Category string
+	By       *string // the ID of the request if not set
+}
+
+func (aa *AcknowledgeAlertArgs) NameSuggestions(otherArgs base.FunctionArguments, oc *OpAlert) map[string]string {
+	return oc.nameSuggestions(&aa.Category, false)
+}
+
+// AcknowledgeAlert records that somebody takes care of an active alert, it is not repeated or escalated until it is set again
+func (oc *OpAlert) AcknowledgeAlert(ctx *base.Context, mainInput *base.OperatorIO, args AcknowledgeAlertArgs) *base.OperatorIO {
+	by := ctx.GetID()
+	if args.By != nil && *args.By != "" {
+		by = *args.By
+	}
+	ns, err := freepsstore.GetGlobalStore().GetNamespace("_alerts")
+	if err != nil {
+		return base.MakeOutputError(http.StatusInternalServerError, "Error getting store: %v", err)
+	}
+	if ns.GetValue(getAlertName(args.Name, args.Category)) == freepsstore.NotFoundEntry {
+		return base.MakeOutputError(http.StatusNotFound, "Alert %v does not exist", getAlertName(args.Name, args.Category))
+	}
+
+	active := true
+	a, err := oc.updateAlert(ctx, args.Category, args.Name, func(a *AlertWithMetadata, newAlert bool) {
+		if a.IsExpired() {
+			active = false
+			return
+		}
+		a.AcknowledgedBy = by
+		a.AcknowledgedAt = time.Now()
+	})
+	if err != nil {
+		return base.MakeOutputError(http.StatusInternalServerError, "Error acknowledging alert: %v", err)
+	}
+	if !active {
+		return base.MakeOutputError(http.StatusExpectationFailed, "Alert %v has expired", a.GetFullName())
+	}
+	oc.recordEvent(ctx, "acknowledge", a, a.AcknowledgedAt.Sub(a.First), by)
 
 	return base.MakeEmptyOutput()
 }
@@ -226,6 +333,10 @@ func (oc *OpAlert) getAlerts(args GetAlertArgs) (map[string]ReadableAlert, error
 	if err != nil {
 		return alerts, fmt.Errorf("Error getting store: %v", err)
 	}
+	var all map[string]AlertWithMetadata
+	if len(oc.inhibitions) > 0 {
+		all = oc.getAllAlerts()
+	}
 	for _, entry := range ns.GetSearchResultWithMetadata("", "", "", 0, math.MaxInt64) {
 		var a AlertWithMetadata
 		err := entry.ParseJSON(&a)
@@ -245,6 +356,9 @@ func (oc *OpAlert) getAlerts(args GetAlertArgs) (map[string]ReadableAlert, error
 			continue
 		}
 
+		if !isInhibited(&a, all) {
+			a.InhibitedBy = ""
+		}
 		alerts[a.GetFullName()] = NewReadableAlert(a, entry.GetModifiedBy())
 	}
 	return alerts, nil
@@ -273,15 +387,24 @@ func (oc *OpAlert) GetShortAlertString(ctx *base.Context, mainInput *base.Operat
 	}
 	alertNames := make([]string, 0)
 	categories := make(map[string]int, 0)
+	inhibited := 0
 	var a ReadableAlert // used if there is only one alert
 	for _, a = range activeAlerts {
 		categories[a.Category] = 1
-		alertNames = append(alertNames, a.Name)
+		if a.InhibitedBy != "" {
+			inhibited++
+			alertNames = append(alertNames, a.Name+"(inhibited)")
+		} else {
+			alertNames = append(alertNames, a.Name)
+		}
 	}
 	if len(activeAlerts) == 0 {
 		return base.MakeEmptyOutput()
 	}
 	if len(activeAlerts) == 1 {
+		if a.InhibitedBy != "" {
+			return base.MakeSprintfOutput("Inhibited alert: %v (caused by %v)", a.GetFullName(), a.InhibitedBy)
+		}
 		if a.Desc != "" {
 			return base.MakePlainOutput(a.Desc)
 		}
@@ -292,15 +415,19 @@ func (oc *OpAlert) GetShortAlertString(ctx *base.Context, mainInput *base.Operat
 	if len(alertNames) <= 3 {
 		alertListStr = strings.Join(alertNames, ",")
 	}
+	inhibitedStr := ""
+	if inhibited > 0 {
+		inhibitedStr = fmt.Sprintf(" (%d inhibited)", inhibited)
+	}
 	if len(categories) == 0 {
-		return base.MakeSprintfOutput("%d alerts: %v", len(activeAlerts), alertListStr)
+		return base.MakeSprintfOutput("%d alerts%v: %v", len(activeAlerts), inhibitedStr, alertListStr)
 	}
 	if len(categories) == 1 {
 		for c := range categories {
-			return base.MakeSprintfOutput("%d %v alerts: %v", len(activeAlerts), c, alertListStr)
+			return base.MakeSprintfOutput("%d %v alerts%v: %v", len(activeAlerts), c, inhibitedStr, alertListStr)
 		}
 	}
-	return base.MakeSprintfOutput("%d alerts: %v", len(activeAlerts), alertListStr)
+	return base.MakeSprintfOutput("%d alerts%v: %v", len(activeAlerts), inhibitedStr, alertListStr)
 }
 
 // HasAlerts returns an empty output if there are any active alerts matching the criteria
@@ -0,0 +1,210 @@
+package opalert
+
+import (
+	"path"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+	freepsstore "github.com/hannesrauhe/freeps/connectors/store"
+)
+
+// routeCheckInterval is the interval in which repeated notifications and escalations are checked
+var routeCheckInterval = 10 * time.Second
+
+// AlertRoute sends notifications about matching alerts by executing a flow
+type AlertRoute struct {
+	Name              string
+	Category          string        `json:",omitempty"` // all categories if empty
+	NamePattern       string        `json:",omitempty"` // glob pattern as in path.Match for the name of the alert, all names if empty
+	MaxSeverity       int           `json:",omitempty"` // alerts up to this severity (1 is the most severe), all severities if 0
+	FlowID            string        // the notification flow, it gets the alert as arguments
+	RepeatInterval    time.Duration `json:",omitempty"` // the flow is executed again after this interval while the alert is active and not acknowledged, only once if 0
+	EscalateAfter     time.Duration `json:",omitempty"` // the alert is handed over to the route EscalateTo if it has not been silenced, acknowledged or reset in this time
+	EscalateTo        string        `json:",omitempty"`
+	NotifyReset       bool          `json:",omitempty"` // execute the flow when the alert is reset
+	OnlyForEscalation bool          `json:",omitempty"` // the route is never selected for new alerts
+}
+
+func (r *AlertRoute) matches(a *AlertWithMetadata) bool {
+	if r.OnlyForEscalation {
+		return false
+	}
+	if r.Category != "" && r.Category != a.Category {
+		return false
+	}
+	if r.MaxSeverity > 0 && a.Severity > r.MaxSeverity {
+		return false
+	}
+	if r.NamePattern != "" {
+		match, _ := path.Match(r.NamePattern, a.Name)
+		return match
+	}
+	return true
+}
+
+// validateRoutes returns all routes that can be used and logs the invalid ones
+func validateRoutes(ctx *base.Context, routes []AlertRoute) []AlertRoute {
+	names := map[string]bool{}
+	for _, r := range routes {
+		names[r.Name] = true
+	}
+	valid := []AlertRoute{}
+	seen := map[string]bool{}
+	for _, r := range routes {
+		if r.Name == "" || seen[r.Name] {
+			ctx.GetLogger().Errorf("Alert route \"%v\" is ignored: the name is empty or not unique", r.Name)
+			continue
+		}
+		seen[r.Name] = true
+		if r.FlowID == "" {
+			ctx.GetLogger().Errorf("Alert route \"%v\" is ignored: no FlowID", r.Name)
+			continue
+		}
+		if _, err := path.Match(r.NamePattern, ""); err != nil {
+			ctx.GetLogger().Errorf("Alert route \"%v\" is ignored: invalid NamePattern: %v", r.Name, err)
+			continue
+		}
+		if r.EscalateTo != "" && !names[r.EscalateTo] {
+			ctx.GetLogger().Errorf("Alert route \"%v\" escalates to unknown route \"%v\", escalation is disabled", r.Name, r.EscalateTo)
+			r.EscalateTo = ""
+		}
+		valid = append(valid, r)
+	}
+	return valid
+}
+
+// getRoute returns the route with the given name or nil if it does not exist
+func (oc *OpAlert) getRoute(name string) *AlertRoute {
+	for i := range oc.routes {
+		if oc.routes[i].Name == name {
+			return &oc.routes[i]
+		}
+	}
+	return nil
+}
+
+// selectRoute returns the first route that matches the alert or nil if there is none
+func (oc *OpAlert) selectRoute(a *AlertWithMetadata) *AlertRoute {
+	for i := range oc.routes {
+		if oc.routes[i].matches(a) {
+			return &oc.routes[i]
+		}
+	}
+	return nil
+}
+
+// nextNotification returns the route that has to notify about the alert now and the kind of notification ("set", "repeat" or "escalation")
+func (oc *OpAlert) nextNotification(a *AlertWithMetadata, now time.Time) (*AlertRoute, string) {
+	if a.IsExpired() || a.SilenceUntil.After(now) || a.AcknowledgedBy != "" || a.InhibitedBy != "" {
+		return nil, ""
+	}
+	r := oc.getRoute(a.Route)
+	if r == nil {
+		// the alert has been set before a matching route was configured or its route has been removed
+		r = oc.selectRoute(a)
+		if r == nil {
+			return nil, ""
+		}
+		return r, "set"
+	}
+	if r.EscalateTo != "" && r.EscalateAfter > 0 && now.Sub(a.RouteSince) >= r.EscalateAfter {
+		if e := oc.getRoute(r.EscalateTo); e != nil {
+			return e, "escalation"
+		}
+	}
+	if r.RepeatInterval > 0 && now.Sub(a.LastNotification) >= r.RepeatInterval {
+		return r, "repeat"
+	}
+	return nil, ""
+}
+
+// notify executes the flow of the route with the alert as arguments
+func (oc *OpAlert) notify(ctx *base.Context, r *AlertRoute, a AlertWithMetadata, notification string) {
+	args, err := base.NewFunctionArgumentsFromObject(a)
+	if err != nil {
+		ctx.GetLogger().Errorf("Cannot notify about alert \"%v\": %v", a.GetFullName(), err)
+		return
+	}
+	args.Append("notification", notification)
+	out := oc.GE.ExecuteFlow(ctx, r.FlowID, args, base.MakeEmptyOutput())
+	if out.IsError() {
+		ctx.GetLogger().Errorf("Notification flow \"%v\" of route \"%v\" failed for alert \"%v\": %v", r.FlowID, r.Name, a.GetFullName(), out.GetString())
+	}
+}
+
+// processRoutes releases the alerts whose inhibiting source has expired, sends the repeated notifications and escalates the alerts that are due
+func (oc *OpAlert) processRoutes(ctx *base.Context, now time.Time) {
+	ns, err := freepsstore.GetGlobalStore().GetNamespace("_alerts")
+	if err != nil {
+		return
+	}
+	if len(oc.inhibitions) > 0 {
+		oc.releaseExpiredInhibitions(ctx)
+	}
+	for _, key := range ns.GetKeys() {
+		var a AlertWithMetadata
+		if ns.GetValue(key).ParseJSON(&a) != nil {
+			continue
+		}
+		if r, _ := oc.nextNotification(&a, now); r == nil {
+			continue
+		}
+
+		// check again while the alert cannot be modified, so that no notification is sent twice
+		var route *AlertRoute
+		notification := ""
+		a, err = oc.updateAlert(ctx, a.Category, a.Name, func(a *AlertWithMetadata, newAlert bool) {
+			route, notification = oc.nextNotification(a, now)
+			if route == nil {
+				return
+			}
+			if notification != "repeat" {
+				a.Route = route.Name
+				a.RouteSince = now
+			}
+			a.LastNotification = now
+		})
+		if err != nil {
+			ctx.GetLogger().Errorf("Cannot update route of alert \"%v\": %v", key, err)
+			continue
+		}
+		if route != nil {
+			oc.notify(ctx, route, a, notification)
+		}
+	}
+}
+
+func (oc *OpAlert) routingLoop(ctx *base.Context, stop chan struct{}) {
+	ticker := time.NewTicker(routeCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			oc.processRoutes(base.CreateContextWithField(ctx, "component", "alert", "alert routing"), time.Now())
+		}
+	}
+}
+
+// StartListening starts checking for repeated notifications, escalations and expired inhibitions if routes or inhibitions are configured
+func (oc *OpAlert) StartListening(ctx *base.Context) {
+	if len(oc.routes) == 0 && len(oc.inhibitions) == 0 {
+		return
+	}
+	oc.stopRouting = make(chan struct{})
+	go oc.routingLoop(ctx, oc.stopRouting)
+}
+
+// Shutdown stops the routing
+func (oc *OpAlert) Shutdown(ctx *base.Context) {
+	if oc.stopRouting != nil {
+		close(oc.stopRouting)
+		oc.stopRouting = nil
+	}
+}
+
+// GetRoutes returns the configured routes
+func (oc *OpAlert) GetRoutes(ctx *base.Context, mainInput *base.OperatorIO) *base.OperatorIO {
+	return base.MakeObjectOutput(oc.routes)
+}
@@ -0,0 +1,117 @@
+package opalert
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+	freepsstore "github.com/hannesrauhe/freeps/connectors/store"
+	freepsutils "github.com/hannesrauhe/freeps/connectors/utils"
+	"github.com/hannesrauhe/freeps/freepsflow"
+	"github.com/hannesrauhe/freeps/utils"
+	"github.com/sirupsen/logrus"
+	"gotest.tools/v3/assert"
+)
+
+func createNotificationFlow(route string) freepsflow.FlowDesc {
+	return freepsflow.FlowDesc{Operations: []freepsflow.FlowOperationDesc{
+		{Operator: "utils", Function: "echoArguments", UseMainArgs: true},
+		{Operator: "store", Function: "set", InputFrom: "#0", Arguments: map[string]string{"namespace": "notifications", "key": route}},
+	}}
+}
+
+func TestAlertRouting(t *testing.T) {
+	tdir := t.TempDir()
+	cr, err := utils.NewConfigReader(logrus.StandardLogger(), path.Join(tdir, "test_config.json"))
+	assert.NilError(t, err)
+	ctx := base.NewBaseContextWithReason(logrus.StandardLogger(), "")
+	ge := freepsflow.NewFlowEngine(ctx, cr, func() {})
+	for _, op := range []base.FreepsOperator{&freepsstore.OpStore{CR: cr, GE: ge}, &freepsutils.OpUtils{}} {
+		ge.AddOperators(base.MakeFreepsOperators(op, cr, ctx))
+	}
+	assert.NilError(t, ge.AddFlow(ctx, "notifyTeam", createNotificationFlow("team"), false))
+	assert.NilError(t, ge.AddFlow(ctx, "notifyOncall", createNotificationFlow("oncall"), false))
+
+	op := &OpAlert{CR: cr, GE: ge, routes: validateRoutes(ctx, []AlertRoute{
+		{Name: "team", Category: "house", NamePattern: "window*", MaxSeverity: 3, FlowID: "notifyTeam", RepeatInterval: time.Minute, EscalateAfter: 10 * time.Minute, EscalateTo: "oncall", NotifyReset: true},
+		{Name: "oncall", FlowID: "notifyOncall", RepeatInterval: 5 * time.Minute, OnlyForEscalation: true},
+		{Name: "noFlow"},
+		{Name: "unknownEscalation", FlowID: "notifyTeam", Category: "garden", EscalateTo: "nobody", EscalateAfter: time.Minute},
+	})}
+	routes := []AlertRoute{}
+	assert.NilError(t, op.GetRoutes(ctx, base.MakeEmptyOutput()).ParseJSON(&routes))
+	assert.Equal(t, len(routes), 3)
+	assert.Equal(t, routes[2].EscalateTo, "")
+
+	notifications := freepsstore.GetGlobalStore().GetNamespaceNoError("notifications")
+	lastNotification := func(route string) map[string]string {
+		args := map[string]string{}
+		e := notifications.GetValue(route)
+		if e == freepsstore.NotFoundEntry {
+			return args
+		}
+		assert.NilError(t, e.ParseJSON(&args))
+		notifications.DeleteValue(route)
+		return args
+	}
+
+	start := time.Now()
+	op.SetAlert(ctx, base.MakeEmptyOutput(), Alert{Name: "windowKitchen", Category: "house", Severity: 2}, base.MakeEmptyFunctionArguments())
+	n := lastNotification("team")
+	assert.Equal(t, n["notification"], "set")
+	assert.Equal(t, n["Name"], "windowKitchen")
+
+	/* alerts that do not match any route */
+	op.SetAlert(ctx, base.MakeEmptyOutput(), Alert{Name: "door", Category: "house", Severity: 2}, base.MakeEmptyFunctionArguments())
+	op.SetAlert(ctx, base.MakeEmptyOutput(), Alert{Name: "windowBath", Category: "house", Severity: 4}, base.MakeEmptyFunctionArguments())
+	assert.Equal(t, notifications.Len(), 0)
+
+	/* repeat while active */
+	op.processRoutes(ctx, start.Add(30*time.Second))
+	assert.Equal(t, notifications.Len(), 0)
+	op.processRoutes(ctx, start.Add(61*time.Second))
+	assert.Equal(t, lastNotification("team")["notification"], "repeat")
+	op.processRoutes(ctx, start.Add(62*time.Second))
+	assert.Equal(t, notifications.Len(), 0)
+
+	/* escalate if nobody reacts */
+	op.processRoutes(ctx, start.Add(11*time.Minute))
+	assert.Equal(t, lastNotification("oncall")["notification"], "escalation")
+	assert.Equal(t, notifications.Len(), 0)
+	out := op.GetActiveAlert(ctx, base.MakeEmptyOutput(), IsActiveAlertArgs{Name: "windowKitchen", Category: "house"})
+	a := ReadableAlert{}
+	assert.NilError(t, out.ParseJSON(&a))
+	assert.Equal(t, a.Route, "oncall")
+	op.processRoutes(ctx, start.Add(15*time.Minute))
+	assert.Equal(t, notifications.Len(), 0)
+	op.processRoutes(ctx, start.Add(17*time.Minute))
+	assert.Equal(t, lastNotification("oncall")["notification"], "repeat")
+
+	/* silenced alerts are neither repeated nor escalated */
+	op.SetAlert(ctx, base.MakeEmptyOutput(), Alert{Name: "windowBedroom", Category: "house", Severity: 1}, base.MakeEmptyFunctionArguments())
+	assert.Equal(t, lastNotification("team")["Name"], "windowBedroom")
+	op.SilenceAlert(ctx, base.MakeEmptyOutput(), SilenceAlertArgs{Name: "windowBedroom", Category: "house", SilenceDuration: time.Hour})
+	op.SilenceAlert(ctx, base.MakeEmptyOutput(), SilenceAlertArgs{Name: "windowKitchen", Category: "house", SilenceDuration: time.Hour})
+	op.processRoutes(ctx, start.Add(30*time.Minute))
+	assert.Equal(t, notifications.Len(), 0)
+
+	/* reset alerts are not escalated and the route is notified */
+	op.ResetSilence(ctx, base.MakeEmptyOutput(), ResetAlertArgs{Name: "windowBedroom", Category: "house"})
+	op.ResetAlert(ctx, base.MakeEmptyOutput(), ResetAlertArgs{Name: "windowBedroom", Category: "house"})
+	assert.Equal(t, lastNotification("team")["notification"], "reset")
+	op.ResetAlert(ctx, base.MakeEmptyOutput(), ResetAlertArgs{Name: "windowKitchen", Category: "house"})
+	op.processRoutes(ctx, start.Add(2*time.Hour))
+	assert.Equal(t, notifications.Len(), 0)
+
+	/* an alert set without triggers has not been notified yet, so the repeat is due right away */
+	op.SetAlert(ctx, base.MakeEmptyOutput(), Alert{Name: "windowGarage", Category: "house", Severity: 1}, base.NewSingleFunctionArgument("noTrigger", "true"))
+	assert.Equal(t, notifications.Len(), 0)
+	now := time.Now()
+	op.processRoutes(ctx, now)
+	n = lastNotification("team")
+	assert.Equal(t, n["notification"], "repeat")
+	assert.Equal(t, n["Name"], "windowGarage")
+	op.processRoutes(ctx, now.Add(30*time.Second))
+	assert.Equal(t, notifications.Len(), 0)
+}
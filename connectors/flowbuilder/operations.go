@@ -18,6 +18,8 @@ type AddOperation struct {
 	ExecuteOnFailOf    *string
 	ArgumentsFrom      *string
 	UseMainArgs        *bool
+	ForEach            *string
+	Condition          *string
 }
 
 // AddOperation adds an operation to a flow in the store
@@ -56,6 +58,12 @@ func (m *OpFlowBuilder) AddOperation(ctx *base.Context, input *base.OperatorIO,
 	if args.UseMainArgs != nil {
 		operationDesc.UseMainArgs = *args.UseMainArgs
 	}
+	if args.ForEach != nil {
+		operationDesc.ForEach = *args.ForEach
+	}
+	if args.Condition != nil {
+		operationDesc.Condition = *args.Condition
+	}
 	if operationNumber < 0 || operationNumber > len(gd.Operations) {
 		gd.Operations = append(gd.Operations, operationDesc)
 	} else {
@@ -72,6 +80,7 @@ type SetOperationArgs struct {
 	Function        *string
 	ArgumentName    *string
 	ArgumentValue   *string
+	Condition       *string
 }
 
 // SetOperation sets the fields of an operation given by the number in a flow in the store
@@ -99,6 +108,56 @@ func (m *OpFlowBuilder) SetOperation(ctx *base.Context, input *base.OperatorIO,
 		}
 		gd.Operations[args.OperationNumber].Arguments[*args.ArgumentName] = *args.ArgumentValue
 	}
+	if args.Condition != nil {
+		gd.Operations[args.OperationNumber].Condition = *args.Condition
+	}
+	return freepsstore.StoreFlow(args.FlowName, gd, ctx)
+}
+
+// SetSwitchArgs are the arguments for the SetSwitch function, an empty CaseTarget removes the case
+type SetSwitchArgs struct {
+	FlowName        string
+	OperationNumber int
+	Value           *string
+	CaseValue       *string
+	CaseTarget      *string
+	Default         *string
+	Remove          *bool
+}
+
+// SetSwitch turns an operation into a switch or modifies its cases
+func (m *OpFlowBuilder) SetSwitch(ctx *base.Context, input *base.OperatorIO, args SetSwitchArgs) *base.OperatorIO {
+	gd, err := freepsstore.GetFlow(args.FlowName)
+	if err != nil {
+		return base.MakeOutputError(404, "Flow not found in store: %v", err)
+	}
+	if args.OperationNumber < 0 || args.OperationNumber >= len(gd.Operations) {
+		return base.MakeOutputError(400, "Invalid operation number")
+	}
+	op := &gd.Operations[args.OperationNumber]
+	if args.Remove != nil && *args.Remove {
+		op.Switch = nil
+		return freepsstore.StoreFlow(args.FlowName, gd, ctx)
+	}
+	if op.Switch == nil {
+		op.Switch = &freepsflow.SwitchDesc{Cases: map[string]string{}}
+	}
+	if op.Switch.Cases == nil {
+		op.Switch.Cases = map[string]string{}
+	}
+	if args.Value != nil {
+		op.Switch.Value = *args.Value
+	}
+	if args.Default != nil {
+		op.Switch.Default = *args.Default
+	}
+	if args.CaseValue != nil {
+		if args.CaseTarget == nil || *args.CaseTarget == "" {
+			delete(op.Switch.Cases, *args.CaseValue)
+		} else {
+			op.Switch.Cases[*args.CaseValue] = *args.CaseTarget
+		}
+	}
 	return freepsstore.StoreFlow(args.FlowName, gd, ctx)
 }
 
@@ -6,6 +6,8 @@ type HTTPConfig struct {
 	Port int `json:"port"`
 	// enablePprof enables pprof on the given port
 	EnablePprof bool `json:"enablePprof"`
+	// EnableMetrics serves the metrics in the Prometheus text format at /metrics
+	EnableMetrics bool `json:"enableMetrics"`
 	// Flow processing timeout in seconds
 	FlowProcessingTimeout int `json:"flowProcessingTimeout"`
 }
@@ -121,6 +121,11 @@ func (r *FreepsHttpListener) ServeHTTP(w http.ResponseWriter, req *http.Request)
 		opio = r.flowengine.ExecuteOperatorByName(ctx, vars["mod"], vars["function"], mainArgs, mainInput)
 	}
 
+	r.writeResponse(w, req, ctx, opio, redirectLocation)
+}
+
+// writeResponse writes the output of an operator or flow as HTTP response
+func (r *FreepsHttpListener) writeResponse(w http.ResponseWriter, req *http.Request, ctx *base.Context, opio *base.OperatorIO, redirectLocation string) {
 	w.Header().Set("X-Freeps-ID", ctx.GetID())
 	if redirectLocation != "" && opio.IsEmpty() {
 		http.Redirect(w, req, redirectLocation, http.StatusFound)
@@ -147,6 +152,13 @@ func (r *FreepsHttpListener) Shutdown(ctx context.Context) {
 	r.srv.Shutdown(ctx)
 }
 
+// handleMetrics serves the metrics in the Prometheus text format
+func (r *FreepsHttpListener) handleMetrics(w http.ResponseWriter, req *http.Request) {
+	ctx := base.CreateContextWithField(r.baseContext, "component", "http", "Metrics request from "+req.RemoteAddr)
+	opio := r.flowengine.ExecuteOperatorByName(ctx, "metrics", "Prometheus", base.MakeEmptyFunctionArguments(), base.MakeEmptyOutput())
+	r.writeResponse(w, req, ctx, opio, "")
+}
+
 func (r *FreepsHttpListener) handleStaticContent(w http.ResponseWriter, req *http.Request) {
 	vars := mux.Vars(req)
 
@@ -175,6 +187,9 @@ func NewFreepsHttp(ctx *base.Context, cfg HTTPConfig, ge *freepsflow.FlowEngine)
 		r.HandleFunc("/debug/pprof/{profile}", pprof.Index)
 		r.HandleFunc("/debug/pprof/", pprof.Index)
 	}
+	if cfg.EnableMetrics {
+		r.HandleFunc("/metrics", rest.handleMetrics)
+	}
 	r.HandleFunc("/{file}", rest.handleStaticContent)
 	r.Handle("/{mod}/", rest)
 	r.Handle("/{mod}/{function}", rest)
@@ -28,6 +28,7 @@ func (o *OpCurl) GetDefaultConfig() interface{} {
 	return &HTTPConfig{
 		Port:                  8080,
 		EnablePprof:           false,
+		EnableMetrics:         false,
 		FlowProcessingTimeout: 120,
 	}
 }
@@ -0,0 +1,217 @@
+package freepsmetrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+	opalert "github.com/hannesrauhe/freeps/connectors/alert"
+	"github.com/hannesrauhe/freeps/connectors/sensor"
+	"github.com/hannesrauhe/freeps/freepsflow"
+	"github.com/hannesrauhe/freeps/utils"
+)
+
+// PrometheusContentType is the content type of the Prometheus text exposition format
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// prometheusWriter creates metrics in the Prometheus text exposition format
+type prometheusWriter struct {
+	b strings.Builder
+}
+
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatPrometheusValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// header starts a new metric family
+func (w *prometheusWriter) header(name string, metricType string, help string) {
+	fmt.Fprintf(&w.b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+// sample writes a single value, labels are pairs of names and values
+func (w *prometheusWriter) sample(name string, value float64, labels ...string) {
+	w.b.WriteString(name)
+	if len(labels) > 0 {
+		w.b.WriteString("{")
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.b.WriteString(",")
+			}
+			fmt.Fprintf(&w.b, "%s=\"%s\"", labels[i], prometheusLabelEscaper.Replace(labels[i+1]))
+		}
+		w.b.WriteString("}")
+	}
+	w.b.WriteString(" ")
+	w.b.WriteString(formatPrometheusValue(value))
+	w.b.WriteString("\n")
+}
+
+// histogram writes the buckets, sum and count of a latency histogram
+func (w *prometheusWriter) histogram(name string, s freepsflow.ExecutionStatistics, labels ...string) {
+	for i, bound := range freepsflow.LatencyBuckets {
+		w.sample(name+"_bucket", float64(s.LatencyBuckets[i]), append(labels, "le", formatPrometheusValue(bound))...)
+	}
+	w.sample(name+"_bucket", float64(s.Executions), append(labels, "le", "+Inf")...)
+	w.sample(name+"_sum", s.LatencySum, labels...)
+	w.sample(name+"_count", float64(s.Executions), labels...)
+}
+
+func sortedCodes(errorsByCode map[int]uint64) []int {
+	codes := make([]int, 0, len(errorsByCode))
+	for code := range errorsByCode {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	return codes
+}
+
+func (o *OpMetrics) writeFlowMetrics(w *prometheusWriter) {
+	m := o.GE.GetMetrics()
+	w.header("freeps_build_info", "gauge", "Version of the running freeps instance")
+	w.sample("freeps_build_info", 1, "version", utils.Version, "commit", utils.CommitHash, "branch", utils.Branch)
+	w.header("freeps_start_time_seconds", "gauge", "Start time of freeps since unix epoch in seconds")
+	w.sample("freeps_start_time_seconds", float64(utils.StartTimestamp.Unix()))
+	w.header("freeps_engine_flow_executions_total", "counter", "Number of flows executed by the engine")
+	w.sample("freeps_engine_flow_executions_total", float64(m.FlowExecutions))
+	w.header("freeps_engine_operation_executions_total", "counter", "Number of operations executed by the engine")
+	w.sample("freeps_engine_operation_executions_total", float64(m.OperationExecutions))
+
+	flowStats := o.GE.GetFlowStatistics()
+	flowIDs := make([]string, 0, len(flowStats))
+	for flowID := range flowStats {
+		flowIDs = append(flowIDs, flowID)
+	}
+	sort.Strings(flowIDs)
+	w.header("freeps_flow_executions_total", "counter", "Number of executions per flow")
+	for _, flowID := range flowIDs {
+		w.sample("freeps_flow_executions_total", float64(flowStats[flowID].Executions), "flow", flowID)
+	}
+	w.header("freeps_flow_errors_total", "counter", "Number of failed executions per flow and HTTP status code")
+	for _, flowID := range flowIDs {
+		for _, code := range sortedCodes(flowStats[flowID].ErrorsByCode) {
+			w.sample("freeps_flow_errors_total", float64(flowStats[flowID].ErrorsByCode[code]), "flow", flowID, "code", strconv.Itoa(code))
+		}
+	}
+	w.header("freeps_flow_duration_seconds", "histogram", "Execution time of flows")
+	for _, flowID := range flowIDs {
+		w.histogram("freeps_flow_duration_seconds", flowStats[flowID], "flow", flowID)
+	}
+
+	opStats := o.GE.GetOperationStatistics()
+	opKeys := make([]freepsflow.OperationKey, 0, len(opStats))
+	for key := range opStats {
+		opKeys = append(opKeys, key)
+	}
+	sort.Slice(opKeys, func(a, b int) bool {
+		if opKeys[a].Operator != opKeys[b].Operator {
+			return opKeys[a].Operator < opKeys[b].Operator
+		}
+		return opKeys[a].Function < opKeys[b].Function
+	})
+	w.header("freeps_operation_executions_total", "counter", "Number of executions per operator function")
+	for _, key := range opKeys {
+		w.sample("freeps_operation_executions_total", float64(opStats[key].Executions), "operator", key.Operator, "function", key.Function)
+	}
+	w.header("freeps_operation_errors_total", "counter", "Number of failed executions per operator function and HTTP status code")
+	for _, key := range opKeys {
+		for _, code := range sortedCodes(opStats[key].ErrorsByCode) {
+			w.sample("freeps_operation_errors_total", float64(opStats[key].ErrorsByCode[code]), "operator", key.Operator, "function", key.Function, "code", strconv.Itoa(code))
+		}
+	}
+	w.header("freeps_operation_duration_seconds", "histogram", "Execution time of operator functions")
+	for _, key := range opKeys {
+		w.histogram("freeps_operation_duration_seconds", opStats[key], "operator", key.Operator, "function", key.Function)
+	}
+}
+
+func (o *OpMetrics) writeAlertMetrics(ctx *base.Context, w *prometheusWriter) {
+	if o.GE.GetOperator("alert") == nil {
+		return
+	}
+	out := o.GE.ExecuteOperatorByName(ctx, "alert", "GetAlerts", base.NewSingleFunctionArgument("IncludeSilenced", "true"), base.MakeEmptyOutput())
+	alerts := map[string]opalert.ReadableAlert{}
+	if out.IsError() || out.ParseJSON(&alerts) != nil {
+		return
+	}
+	// always export all severities so that the series do not disappear when the last alert is reset
+	active := map[int][2]int{1: {}, 2: {}, 3: {}, 4: {}, 5: {}}
+	for _, a := range alerts {
+		counts := active[a.Severity]
+		if a.SilenceDuration > 0 {
+			counts[1]++
+		} else {
+			counts[0]++
+		}
+		active[a.Severity] = counts
+	}
+	severities := make([]int, 0, len(active))
+	for sev := range active {
+		severities = append(severities, sev)
+	}
+	sort.Ints(severities)
+	w.header("freeps_alerts_active", "gauge", "Number of active alerts per severity")
+	for _, sev := range severities {
+		w.sample("freeps_alerts_active", float64(active[sev][0]), "severity", strconv.Itoa(sev), "silenced", "false")
+		w.sample("freeps_alerts_active", float64(active[sev][1]), "severity", strconv.Itoa(sev), "silenced", "true")
+	}
+}
+
+func (o *OpMetrics) writeSensorMetrics(ctx *base.Context, w *prometheusWriter) {
+	opSensor := sensor.GetGlobalSensors()
+	if opSensor == nil {
+		return
+	}
+	categories, err := opSensor.GetSensorCategoriesInternal(ctx)
+	if err != nil {
+		return
+	}
+	sort.Strings(categories)
+	w.header("freeps_sensor_value", "gauge", "Numeric properties of sensors")
+	for _, category := range categories {
+		names, err := opSensor.GetSensorNamesInternal(ctx, category)
+		if err != nil {
+			continue
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			properties, err := opSensor.GetSensorPropertyKeysInternal(ctx, category, name)
+			if err != nil {
+				continue
+			}
+			sort.Strings(properties)
+			for _, property := range properties {
+				v := opSensor.GetSensorPropertyInternal(ctx, category, name, property)
+				if !v.IsInteger() && !v.IsFloatingPoint() {
+					continue
+				}
+				w.sample("freeps_sensor_value", v.GetFloat64(true), "category", category, "sensor", name, "property", property)
+			}
+		}
+	}
+}
+
+// Prometheus returns flow and operator execution statistics, active alerts and numeric sensor properties in the Prometheus text format
+func (o *OpMetrics) Prometheus(ctx *base.Context) *base.OperatorIO {
+	w := &prometheusWriter{}
+	start := time.Now()
+	o.writeFlowMetrics(w)
+	o.writeAlertMetrics(ctx, w)
+	o.writeSensorMetrics(ctx, w)
+	w.header("freeps_metrics_scrape_duration_seconds", "gauge", "Time it took to collect these metrics")
+	w.sample("freeps_metrics_scrape_duration_seconds", time.Since(start).Seconds())
+	return base.MakeByteOutputWithContentType([]byte(w.b.String()), PrometheusContentType)
+}
@@ -0,0 +1,52 @@
+package freepsmetrics_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hannesrauhe/freeps/base"
+	"github.com/hannesrauhe/freeps/connectors/sensor"
+	"github.com/hannesrauhe/freeps/freepsd/helper"
+	"github.com/hannesrauhe/freeps/freepsflow"
+	"gotest.tools/v3/assert"
+)
+
+func TestPrometheus(t *testing.T) {
+	ctx, ge, _ := helper.SetupEngineWithCommonOperators(t, nil)
+
+	testFlow := freepsflow.FlowDesc{Operations: []freepsflow.FlowOperationDesc{
+		{Name: "echo", Operator: "utils", Function: "echo", Arguments: map[string]string{"output": "a\"b"}},
+		{Name: "fail", Operator: "utils", Function: "doesNotExist"},
+	}, OutputFrom: "echo", Source: "test"}
+	assert.NilError(t, ge.AddFlow(ctx, "test\"flow", testFlow, true))
+	ge.ExecuteFlow(ctx, "test\"flow", base.MakeEmptyFunctionArguments(), base.MakeEmptyOutput())
+	ge.ExecuteFlow(ctx, "test\"flow", base.MakeEmptyFunctionArguments(), base.MakeEmptyOutput())
+
+	assert.NilError(t, sensor.GetGlobalSensors().SetSensorPropertiesInternal(ctx, "room", "kitchen", map[string]interface{}{"temperature": 21.5, "name": "Kitchen"}))
+	ge.ExecuteOperatorByName(ctx, "alert", "SetAlert", base.NewFunctionArguments(map[string]string{"Name": "a", "Category": "test", "Severity": "2"}), base.MakeEmptyOutput())
+
+	out := ge.ExecuteOperatorByName(ctx, "metrics", "Prometheus", base.MakeEmptyFunctionArguments(), base.MakeEmptyOutput())
+	assert.Assert(t, !out.IsError(), out.GetString())
+	assert.Assert(t, strings.HasPrefix(out.ContentType, "text/plain"))
+	lines := strings.Split(out.GetString(), "\n")
+	has := func(line string) bool {
+		for _, l := range lines {
+			if l == line {
+				return true
+			}
+		}
+		return false
+	}
+
+	assert.Assert(t, has(`freeps_flow_executions_total{flow="test\"flow"} 2`))
+	assert.Assert(t, has(`freeps_operation_executions_total{operator="utils",function="echo"} 2`))
+	assert.Assert(t, has(`freeps_operation_errors_total{operator="utils",function="doesnotexist",code="404"} 2`))
+	assert.Assert(t, has(`freeps_operation_duration_seconds_bucket{operator="utils",function="echo",le="+Inf"} 2`))
+	assert.Assert(t, has(`freeps_operation_duration_seconds_count{operator="utils",function="echo"} 2`))
+	assert.Assert(t, has(`freeps_alerts_active{severity="2",silenced="false"} 1`))
+	assert.Assert(t, has(`freeps_alerts_active{severity="1",silenced="false"} 0`))
+	assert.Assert(t, has(`freeps_sensor_value{category="room",sensor="kitchen",property="temperature"} 21.5`))
+	for _, l := range lines {
+		assert.Assert(t, !strings.Contains(l, `property="name"`), l)
+	}
+}
@@ -0,0 +1,181 @@
+package opschedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schedule computes the next execution time after a given point in time
+type schedule interface {
+	Next(t time.Time) time.Time
+}
+
+// everySchedule runs in fixed intervals
+type everySchedule struct {
+	interval time.Duration
+}
+
+func (s *everySchedule) Next(t time.Time) time.Time {
+	return t.Add(s.interval)
+}
+
+// cronSchedule is a classic 5-field cron expression: minute hour day-of-month month day-of-week
+type cronSchedule struct {
+	minute     uint64
+	hour       uint64
+	dayOfMonth uint64
+	month      uint64
+	dayOfWeek  uint64
+	// if both day fields are restricted, a day matches if either of them matches (like in Vixie cron)
+	dayOfMonthStar bool
+	dayOfWeekStar  bool
+}
+
+var cronShortcuts = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// parseSchedule creates a schedule from a tag like "cron:*/5 * * * *" or "every:10m"
+func parseSchedule(tag string) (schedule, error) {
+	key, value, found := strings.Cut(tag, ":")
+	if !found {
+		return nil, fmt.Errorf("\"%v\" is not a schedule tag", tag)
+	}
+	switch strings.ToLower(key) {
+	case "every":
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return nil, err
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("Interval must be at least one second, got %v", d)
+		}
+		return &everySchedule{interval: d}, nil
+	case "cron":
+		return parseCron(value)
+	}
+	return nil, fmt.Errorf("\"%v\" is not a schedule tag", tag)
+}
+
+func parseCron(expr string) (*cronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if s, ok := cronShortcuts[strings.ToLower(expr)]; ok {
+		expr = s
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("Cron expression \"%v\" must have 5 fields, has %v", expr, len(fields))
+	}
+
+	var err error
+	c := &cronSchedule{}
+	if c.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("Invalid minute field: %v", err)
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("Invalid hour field: %v", err)
+	}
+	if c.dayOfMonth, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("Invalid day-of-month field: %v", err)
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("Invalid month field: %v", err)
+	}
+	if c.dayOfWeek, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("Invalid day-of-week field: %v", err)
+	}
+	// 7 is an alias for Sunday
+	if c.dayOfWeek&(1<<7) != 0 {
+		c.dayOfWeek |= 1
+	}
+	c.dayOfMonthStar = strings.HasPrefix(fields[2], "*")
+	c.dayOfWeekStar = strings.HasPrefix(fields[4], "*")
+	return c, nil
+}
+
+// parseCronField parses a comma-separated list of values, ranges and steps into a bitmask
+func parseCronField(field string, min int, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step \"%v\"", stepPart)
+			}
+		}
+
+		start, end := min, max
+		if rangePart != "*" {
+			lo, hi, isRange := strings.Cut(rangePart, "-")
+			var err error
+			start, err = strconv.Atoi(lo)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value \"%v\"", lo)
+			}
+			end = start
+			if isRange {
+				end, err = strconv.Atoi(hi)
+				if err != nil {
+					return 0, fmt.Errorf("invalid value \"%v\"", hi)
+				}
+			} else if hasStep {
+				end = max
+			}
+		}
+		if start < min || end > max || start > end {
+			return 0, fmt.Errorf("\"%v\" is out of range %v-%v", part, min, max)
+		}
+		for i := start; i <= end; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+	return bits, nil
+}
+
+func (c *cronSchedule) matchesDay(t time.Time) bool {
+	domMatch := c.dayOfMonth&(1<<uint(t.Day())) != 0
+	dowMatch := c.dayOfWeek&(1<<uint(t.Weekday())) != 0
+	if c.dayOfMonthStar || c.dayOfWeekStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Next returns the first matching minute after t, or the zero time if there is none within the next five years
+func (c *cronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !c.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
@@ -0,0 +1,253 @@
+package opschedule
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+	"github.com/hannesrauhe/freeps/freepsflow"
+	"github.com/hannesrauhe/freeps/utils"
+)
+
+// OpSchedule executes flows periodically, flows need the tag "schedule" and one or more tags like "cron:*/5 * * * *" or "every:10m"
+type OpSchedule struct {
+	CR       *utils.ConfigReader
+	GE       *freepsflow.FlowEngine
+	config   *ScheduleConfig
+	ticker   *time.Ticker
+	lastRuns map[string]map[string]time.Time // flowID -> schedule tag -> time of the last run
+	lock     sync.Mutex
+}
+
+// ScheduleConfig is the config for the schedule operator
+type ScheduleConfig struct {
+	Enabled bool
+	// CheckInterval is the resolution in which schedules are checked
+	CheckInterval time.Duration
+	// StateFile is the file in the config directory that stores the time of the last run of each schedule
+	StateFile string
+}
+
+var _ base.FreepsOperatorWithConfig = &OpSchedule{}
+var _ base.FreepsOperatorWithShutdown = &OpSchedule{}
+
+// GetDefaultConfig returns the default config for the schedule operator
+func (o *OpSchedule) GetDefaultConfig() interface{} {
+	return &ScheduleConfig{Enabled: true, CheckInterval: 5 * time.Second, StateFile: "schedule_state.json"}
+}
+
+// InitCopyOfOperator creates a copy of the operator and reads the last runs from disk
+func (o *OpSchedule) InitCopyOfOperator(ctx *base.Context, config interface{}, name string) (base.FreepsOperatorWithConfig, error) {
+	opc := config.(*ScheduleConfig)
+	if opc.CheckInterval <= 0 {
+		return nil, fmt.Errorf("CheckInterval must be positive")
+	}
+	op := &OpSchedule{CR: o.CR, GE: o.GE, config: opc, lastRuns: map[string]map[string]time.Time{}}
+	err := op.loadState()
+	if err != nil {
+		ctx.GetLogger().Errorf("Cannot read last runs of schedules: %v", err)
+	}
+	return op, nil
+}
+
+func (o *OpSchedule) getStateFilePath() string {
+	return path.Join(o.CR.GetConfigDir(), o.config.StateFile)
+}
+
+func (o *OpSchedule) loadState() error {
+	b, err := os.ReadFile(o.getStateFilePath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, &o.lastRuns)
+}
+
+// saveState writes the last runs to disk, caller must hold the lock
+func (o *OpSchedule) saveState() error {
+	b, err := json.MarshalIndent(o.lastRuns, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(o.getStateFilePath(), b, 0644)
+}
+
+// lastRunsEqual returns true if both contain the same schedules with the same last runs
+func lastRunsEqual(a map[string]map[string]time.Time, b map[string]map[string]time.Time) bool {
+	return maps.EqualFunc(a, b, func(x map[string]time.Time, y map[string]time.Time) bool {
+		return maps.EqualFunc(x, y, time.Time.Equal)
+	})
+}
+
+// getSchedules returns all parsed schedule tags for the given flow
+func getSchedules(ctx *base.Context, flowID string, fd freepsflow.FlowDesc) map[string]schedule {
+	schedules := map[string]schedule{}
+	for _, tag := range fd.Tags {
+		k, _ := freepsflow.SplitTag(tag)
+		k = strings.ToLower(k)
+		if k != "cron" && k != "every" {
+			continue
+		}
+		s, err := parseSchedule(tag)
+		if err != nil {
+			ctx.GetLogger().Errorf("Invalid schedule \"%v\" in flow \"%v\": %v", tag, flowID, err)
+			continue
+		}
+		schedules[tag] = s
+	}
+	return schedules
+}
+
+type scheduledRun struct {
+	FlowID   string
+	Schedule string
+	Time     time.Time
+}
+
+// checkSchedules determines which flows are due, records the run and executes them
+func (o *OpSchedule) checkSchedules(initCtx *base.Context, now time.Time) []scheduledRun {
+	flows := o.GE.GetFlowDescByTag([]string{"schedule"})
+	dueRuns := []scheduledRun{}
+
+	o.lock.Lock()
+	newLastRuns := map[string]map[string]time.Time{}
+	for flowID, fd := range flows {
+		for tag, s := range getSchedules(initCtx, flowID, fd) {
+			last, ok := o.lastRuns[flowID][tag]
+			if !ok {
+				// a new schedule starts counting now
+				last = now
+			}
+			next := s.Next(last)
+			if !next.IsZero() && !next.After(now) {
+				dueRuns = append(dueRuns, scheduledRun{FlowID: flowID, Schedule: tag, Time: next})
+				last = next
+				if !s.Next(next).After(now) {
+					// more than one run was missed (e.g. freeps was not running), only execute once
+					last = now
+				}
+			}
+			if _, ok := newLastRuns[flowID]; !ok {
+				newLastRuns[flowID] = map[string]time.Time{}
+			}
+			newLastRuns[flowID][tag] = last
+		}
+	}
+	var err error
+	if !lastRunsEqual(o.lastRuns, newLastRuns) {
+		o.lastRuns = newLastRuns
+		// persist before executing, so a reload in between does not lead to a second execution
+		err = o.saveState()
+	}
+	o.lock.Unlock()
+	if err != nil {
+		initCtx.GetLogger().Errorf("Cannot persist last runs of schedules: %v", err)
+	}
+
+	for _, r := range dueRuns {
+		ctx := base.CreateContextWithField(initCtx, "component", "schedule", fmt.Sprintf("Schedule \"%v\"", r.Schedule))
+		args := base.NewFunctionArguments(map[string]string{"schedule": r.Schedule, "scheduledTime": r.Time.Format(time.RFC3339)})
+		go o.GE.ExecuteFlow(ctx, r.FlowID, args, base.MakeEmptyOutput())
+	}
+	return dueRuns
+}
+
+func (o *OpSchedule) loop(initCtx *base.Context, ticker *time.Ticker) {
+	for now := range ticker.C {
+		o.checkSchedules(initCtx, now)
+	}
+}
+
+// StartListening starts the loop that checks the schedules
+func (o *OpSchedule) StartListening(ctx *base.Context) {
+	if o.ticker != nil {
+		return
+	}
+	o.ticker = time.NewTicker(o.config.CheckInterval)
+	go o.loop(ctx, o.ticker)
+}
+
+// Shutdown stops the loop
+func (o *OpSchedule) Shutdown(ctx *base.Context) {
+	if o.ticker == nil {
+		return
+	}
+	o.ticker.Stop()
+	o.ticker = nil
+}
+
+// NextRunsArgs are the arguments for GetNextRuns
+type NextRunsArgs struct {
+	FlowID *string
+	Count  *int
+}
+
+// ScheduleInfo describes a single schedule of a flow
+type ScheduleInfo struct {
+	FlowID   string
+	Schedule string
+	LastRun  *time.Time `json:",omitempty"`
+	NextRuns []time.Time
+}
+
+// GetNextRuns returns the last and the next runs for all schedules or the schedules of the given flow
+func (o *OpSchedule) GetNextRuns(ctx *base.Context, input *base.OperatorIO, args NextRunsArgs) *base.OperatorIO {
+	count := 1
+	if args.Count != nil {
+		count = *args.Count
+	}
+	if count < 1 || count > 100 {
+		return base.MakeOutputError(http.StatusBadRequest, "Count must be between 1 and 100")
+	}
+
+	flows := o.GE.GetFlowDescByTag([]string{"schedule"})
+	if args.FlowID != nil {
+		fd, ok := flows[*args.FlowID]
+		if !ok {
+			return base.MakeOutputError(http.StatusNotFound, "Flow \"%v\" has no schedule", *args.FlowID)
+		}
+		flows = map[string]freepsflow.FlowDesc{*args.FlowID: fd}
+	}
+
+	now := time.Now()
+	res := []ScheduleInfo{}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	for flowID, fd := range flows {
+		for tag, s := range getSchedules(ctx, flowID, fd) {
+			info := ScheduleInfo{FlowID: flowID, Schedule: tag, NextRuns: []time.Time{}}
+			t := now
+			if last, ok := o.lastRuns[flowID][tag]; ok {
+				info.LastRun = &last
+				t = last
+			}
+			for i := 0; i < count; i++ {
+				t = s.Next(t)
+				if t.IsZero() {
+					break
+				}
+				info.NextRuns = append(info.NextRuns, t)
+			}
+			res = append(res, info)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].FlowID != res[j].FlowID {
+			return res[i].FlowID < res[j].FlowID
+		}
+		return res[i].Schedule < res[j].Schedule
+	})
+	return base.MakeObjectOutput(res)
+}
@@ -0,0 +1,99 @@
+package opschedule
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+	freepsutils "github.com/hannesrauhe/freeps/connectors/utils"
+	"github.com/hannesrauhe/freeps/freepsflow"
+	"github.com/hannesrauhe/freeps/utils"
+	"github.com/sirupsen/logrus"
+	"gotest.tools/v3/assert"
+)
+
+func TestCronNext(t *testing.T) {
+	start := time.Date(2024, 1, 31, 23, 58, 30, 0, time.UTC)
+	tests := []struct {
+		expr     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2024, 1, 31, 23, 59, 0, 0, time.UTC)},
+		{"*/5 * * * *", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 7 * * 1-5", time.Date(2024, 2, 1, 7, 30, 0, 0, time.UTC)},
+		{"0 12 29 2 *", time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2024, 2, 4, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 2, 4, 0, 0, 0, 0, time.UTC)},
+		{"0 0 15 * 0", time.Date(2024, 2, 4, 0, 0, 0, 0, time.UTC)},
+		{"5,10 1-3/2 * * *", time.Date(2024, 2, 1, 1, 5, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range tests {
+		s, err := parseSchedule("cron:" + tc.expr)
+		assert.NilError(t, err, tc.expr)
+		assert.Equal(t, s.Next(start), tc.expected, tc.expr)
+	}
+
+	for _, invalid := range []string{"cron:* * * *", "cron:60 * * * *", "cron:*/0 * * * *", "cron:a * * * *", "every:1ms", "every:foo", "foo:bar"} {
+		_, err := parseSchedule(invalid)
+		assert.Assert(t, err != nil, invalid)
+	}
+}
+
+func TestScheduleRuns(t *testing.T) {
+	ctx := base.NewBaseContextWithReason(logrus.StandardLogger(), "")
+	tdir := t.TempDir()
+	cr, err := utils.NewConfigReader(logrus.StandardLogger(), path.Join(tdir, "test_config.json"))
+	assert.NilError(t, err)
+	ge := freepsflow.NewFlowEngine(ctx, cr, func() {})
+	ge.AddOperators(base.MakeFreepsOperators(&freepsutils.OpUtils{}, cr, ctx))
+
+	opI, err := (&OpSchedule{CR: cr, GE: ge}).InitCopyOfOperator(ctx, (&OpSchedule{}).GetDefaultConfig(), "schedule")
+	assert.NilError(t, err)
+	op := opI.(*OpSchedule)
+
+	err = ge.AddFlow(ctx, "testflow", freepsflow.FlowDesc{Operations: []freepsflow.FlowOperationDesc{{Operator: "utils", Function: "echo"}}}, false)
+	assert.NilError(t, err)
+	assert.Assert(t, op.SetScheduleTrigger(ctx, base.MakeEmptyOutput(), ScheduleTrigger{FlowID: "testflow"}).IsError())
+	assert.Assert(t, op.SetScheduleTrigger(ctx, base.MakeEmptyOutput(), ScheduleTrigger{FlowID: "testflow", Every: utils.StringPtr("nonsense")}).IsError())
+	out := op.SetScheduleTrigger(ctx, base.MakeEmptyOutput(), ScheduleTrigger{FlowID: "testflow", Every: utils.StringPtr("10m")})
+	assert.Assert(t, !out.IsError(), out.GetString())
+
+	// a new schedule is not executed right away
+	now := time.Now()
+	assert.Equal(t, len(op.checkSchedules(ctx, now)), 0)
+	assert.Equal(t, len(op.checkSchedules(ctx, now.Add(9*time.Minute))), 0)
+	runs := op.checkSchedules(ctx, now.Add(10*time.Minute))
+	assert.Equal(t, len(runs), 1)
+	assert.Equal(t, runs[0].FlowID, "testflow")
+	assert.Equal(t, runs[0].Time, now.Add(10*time.Minute))
+	assert.Equal(t, len(op.checkSchedules(ctx, now.Add(11*time.Minute))), 0)
+
+	// the state is only written if a schedule was executed
+	assert.NilError(t, os.Remove(op.getStateFilePath()))
+	assert.Equal(t, len(op.checkSchedules(ctx, now.Add(12*time.Minute))), 0)
+	_, err = os.Stat(op.getStateFilePath())
+	assert.Assert(t, os.IsNotExist(err))
+	assert.Equal(t, len(op.checkSchedules(ctx, now.Add(20*time.Minute))), 1)
+	_, err = os.Stat(op.getStateFilePath())
+	assert.NilError(t, err)
+
+	// last runs survive a reload, missed runs are only executed once
+	opI, err = (&OpSchedule{CR: cr, GE: ge}).InitCopyOfOperator(ctx, (&OpSchedule{}).GetDefaultConfig(), "schedule")
+	assert.NilError(t, err)
+	op = opI.(*OpSchedule)
+	assert.Equal(t, len(op.checkSchedules(ctx, now.Add(15*time.Minute))), 0)
+	assert.Equal(t, len(op.checkSchedules(ctx, now.Add(2*time.Hour))), 1)
+	assert.Equal(t, len(op.checkSchedules(ctx, now.Add(2*time.Hour+time.Minute))), 0)
+
+	count := 3
+	out = op.GetNextRuns(ctx, base.MakeEmptyOutput(), NextRunsArgs{Count: &count})
+	assert.Assert(t, !out.IsError())
+	infos := out.Output.([]ScheduleInfo)
+	assert.Equal(t, len(infos), 1)
+	assert.Equal(t, infos[0].Schedule, "every:10m")
+	assert.Equal(t, len(infos[0].NextRuns), 3)
+	assert.Equal(t, infos[0].NextRuns[0], now.Add(2*time.Hour+10*time.Minute))
+}
@@ -0,0 +1,81 @@
+package opschedule
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/hannesrauhe/freeps/base"
+)
+
+// FlowIDSuggestions returns suggestions for flow names
+func (o *OpSchedule) FlowIDSuggestions() map[string]string {
+	flowNames := map[string]string{}
+	res := o.GE.GetAllFlowDesc()
+	for id, gd := range res {
+		info, _ := gd.GetCompleteDesc(id, o.GE)
+		_, exists := flowNames[info.DisplayName]
+		if !exists {
+			flowNames[info.DisplayName] = id
+		} else {
+			flowNames[fmt.Sprintf("%v (ID: %v)", info.DisplayName, id)] = id
+		}
+	}
+	return flowNames
+}
+
+func (o *OpSchedule) setTrigger(ctx *base.Context, flowId string, tags ...string) *base.OperatorIO {
+	gd, found := o.GE.GetFlowDesc(flowId)
+	if !found {
+		return base.MakeOutputError(http.StatusInternalServerError, "Couldn't find flow: %v", flowId)
+	}
+
+	gd.AddTags("schedule")
+	gd.AddTags(tags...)
+	err := o.GE.AddFlow(ctx, flowId, *gd, true)
+	if err != nil {
+		return base.MakeOutputError(http.StatusInternalServerError, "Cannot modify flow: %v", err)
+	}
+
+	return base.MakeEmptyOutput()
+}
+
+// ScheduleTrigger are the arguments for SetScheduleTrigger, exactly one of Cron and Every must be given
+type ScheduleTrigger struct {
+	FlowID string
+	Cron   *string
+	Every  *string
+}
+
+// CronSuggestions returns some common cron expressions
+func (arg *ScheduleTrigger) CronSuggestions() map[string]string {
+	return map[string]string{
+		"every 5 minutes":       "*/5 * * * *",
+		"every hour":            "@hourly",
+		"every day at midnight": "@daily",
+		"weekdays at 7:00":      "0 7 * * 1-5",
+		"every sunday":          "@weekly",
+	}
+}
+
+// EverySuggestions returns some common intervals
+func (arg *ScheduleTrigger) EverySuggestions() []string {
+	return []string{"30s", "1m", "5m", "10m", "30m", "1h", "6h", "12h", "24h"}
+}
+
+// SetScheduleTrigger adds a cron expression or an interval to the given flow
+func (o *OpSchedule) SetScheduleTrigger(ctx *base.Context, input *base.OperatorIO, args ScheduleTrigger) *base.OperatorIO {
+	var tag string
+	if args.Cron != nil && args.Every == nil {
+		tag = "cron:" + *args.Cron
+	} else if args.Every != nil && args.Cron == nil {
+		tag = "every:" + *args.Every
+	} else {
+		return base.MakeOutputError(http.StatusBadRequest, "Exactly one of Cron and Every must be given")
+	}
+
+	_, err := parseSchedule(tag)
+	if err != nil {
+		return base.MakeOutputError(http.StatusBadRequest, "Invalid schedule: %v", err)
+	}
+	return o.setTrigger(ctx, args.FlowID, tag)
+}
@@ -0,0 +1,374 @@
+package sensor
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+)
+
+// AlertRule sets an alert while a property of a sensor meets a condition and resets it afterwards
+type AlertRule struct {
+	Name           string // name of the alert
+	AlertCategory  string `json:",omitempty"` // category of the alert, "sensor" if empty
+	Severity       int
+	SensorCategory string
+	SensorName     string
+	PropertyName   string
+	Operator       string        // ">", "<", "==" or "changedSince"
+	Threshold      string        // the value the property is compared to, for "changedSince" the minimal absolute change
+	Duration       time.Duration `json:",omitempty"` // the condition must be met this long before the alert is set, for "changedSince" the time span of the change
+	Hysteresis     float64       `json:",omitempty"` // the alert is reset only if the property is this much below (">") or above ("<") the threshold or the change is this much smaller ("changedSince")
+}
+
+type ruleSample struct {
+	time  time.Time
+	value float64
+}
+
+// alertRuleState is kept in memory for every rule
+type alertRuleState struct {
+	pendingSince time.Time // the condition is met since then, but not long enough to set the alert
+	timer        *time.Timer
+	active       bool
+	samples      []ruleSample // values within Duration for "changedSince"
+}
+
+var alertRuleOperators = []string{">", "<", "==", "changedSince"}
+
+func (r *AlertRule) getAlertCategory() string {
+	if r.AlertCategory == "" {
+		return "sensor"
+	}
+	return r.AlertCategory
+}
+
+func (r *AlertRule) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("Name of the alert is missing")
+	}
+	switch r.Operator {
+	case ">", "<", "changedSince":
+		if math.IsNaN(base.MakePlainOutput(r.Threshold).GetFloat64(true)) {
+			return fmt.Errorf("Threshold \"%v\" is not a number", r.Threshold)
+		}
+	case "==":
+	default:
+		return fmt.Errorf("Operator \"%v\" is unknown, use one of %v", r.Operator, alertRuleOperators)
+	}
+	if r.Operator == "changedSince" && r.Duration <= 0 {
+		return fmt.Errorf("Operator \"changedSince\" requires a Duration")
+	}
+	if r.Hysteresis < 0 {
+		return fmt.Errorf("Hysteresis cannot be negative")
+	}
+	return nil
+}
+
+// check returns whether the rule should be active for the given value, it keeps the current state if the value is within the hysteresis
+func (r *AlertRule) check(value *base.OperatorIO, state *alertRuleState, now time.Time) bool {
+	if r.Operator == "==" {
+		return value.GetString() == r.Threshold
+	}
+	v := value.GetFloat64(true)
+	if math.IsNaN(v) {
+		return state.active
+	}
+	threshold := base.MakePlainOutput(r.Threshold).GetFloat64(true)
+	switch r.Operator {
+	case ">":
+		if state.active {
+			return v >= threshold-r.Hysteresis
+		}
+		return v > threshold
+	case "<":
+		if state.active {
+			return v <= threshold+r.Hysteresis
+		}
+		return v < threshold
+	case "changedSince":
+		samples := []ruleSample{}
+		for _, s := range state.samples {
+			if now.Sub(s.time) <= r.Duration {
+				samples = append(samples, s)
+			}
+		}
+		state.samples = append(samples, ruleSample{time: now, value: v})
+		change := 0.0
+		for _, s := range state.samples {
+			change = math.Max(change, math.Abs(v-s.value))
+		}
+		if state.active {
+			return change >= threshold-r.Hysteresis
+		}
+		return change >= threshold
+	}
+	return false
+}
+
+func (o *OpSensor) getRuleState(name string) *alertRuleState {
+	if o.ruleStates == nil {
+		o.ruleStates = map[string]*alertRuleState{}
+	}
+	state, ok := o.ruleStates[name]
+	if !ok {
+		state = &alertRuleState{}
+		o.ruleStates[name] = state
+	}
+	return state
+}
+
+// alertAction sets or resets an alert, it must be executed after the rulesLock has been released because alert triggers might update sensors
+type alertAction func()
+
+func runAlertActions(actions []alertAction) {
+	for _, a := range actions {
+		if a != nil {
+			a()
+		}
+	}
+}
+
+// evaluateAlertRule returns the action that sets or resets the alert of the rule based on the current value of the property, the caller must hold the rulesLock
+func (o *OpSensor) evaluateAlertRule(ctx *base.Context, r AlertRule, value *base.OperatorIO, now time.Time) alertAction {
+	state := o.getRuleState(r.Name)
+	conditionMet := !value.IsError() && r.check(value, state, now)
+	if !conditionMet {
+		if state.timer != nil {
+			state.timer.Stop()
+			state.timer = nil
+		}
+		state.pendingSince = time.Time{}
+		if state.active {
+			state.active = false
+			return func() { o.GE.ResetSystemAlert(ctx, r.Name, r.getAlertCategory()) }
+		}
+		return nil
+	}
+	if r.Operator == "changedSince" {
+		// the change only leaves the time span with the oldest sample, so the rule is evaluated again then even if the sensor does not send updates
+		o.armRuleTimer(ctx, r, state, state.samples[0].time.Add(r.Duration).Sub(now))
+	}
+	if state.active {
+		return nil
+	}
+	// "changedSince" already looks at the time span of the change
+	if r.Operator != "changedSince" && r.Duration > 0 {
+		if state.pendingSince.IsZero() {
+			state.pendingSince = now
+		}
+		remaining := r.Duration - now.Sub(state.pendingSince)
+		if remaining > 0 {
+			if state.timer == nil {
+				// sensors might not send updates while the value does not change, so the rule is evaluated again after the duration
+				o.armRuleTimer(ctx, r, state, remaining)
+			}
+			return nil
+		}
+	}
+	state.pendingSince = time.Time{}
+	state.active = true
+	err := fmt.Errorf("%v of %v.%v is %v (%v %v)", r.PropertyName, r.SensorCategory, r.SensorName, value.GetString(), r.Operator, r.Threshold)
+	return func() { o.GE.SetSystemAlert(ctx, r.Name, r.getAlertCategory(), r.Severity, err, nil) }
+}
+
+// armRuleTimer evaluates the rule again with the current value of the property after d, it replaces a timer that is already armed; the caller must hold the rulesLock
+func (o *OpSensor) armRuleTimer(ctx *base.Context, r AlertRule, state *alertRuleState, d time.Duration) {
+	if state.timer != nil {
+		state.timer.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(d, func() {
+		o.rulesLock.Lock()
+		var action alertAction
+		// the timer might have been replaced or stopped while it was waiting for the lock
+		if state.timer == timer {
+			state.timer = nil
+			if current, ok := o.getAlertRule(r.Name); ok {
+				action = o.evaluateAlertRule(base.CreateContextWithField(ctx, "component", "sensor", "sensor alert rule"), current, o.getSensorProperty(current.SensorCategory, current.SensorName, current.PropertyName), time.Now())
+			}
+		}
+		o.rulesLock.Unlock()
+		runAlertActions([]alertAction{action})
+	})
+	state.timer = timer
+}
+
+// evaluateAlertRules checks all rules for the changed properties of a sensor
+func (o *OpSensor) evaluateAlertRules(ctx *base.Context, sensorCategory string, sensorName string, changedProperties map[string]interface{}) {
+	o.rulesLock.Lock()
+	actions := []alertAction{}
+	now := time.Now()
+	for _, r := range o.config.AlertRules {
+		if r.SensorCategory != sensorCategory || r.SensorName != sensorName {
+			continue
+		}
+		if v, ok := changedProperties[r.PropertyName]; ok {
+			actions = append(actions, o.evaluateAlertRule(ctx, r, base.MakeOutputGuessType(v), now))
+		}
+	}
+	o.rulesLock.Unlock()
+	runAlertActions(actions)
+}
+
+// getAlertRule returns the rule with the given name, the caller must hold the rulesLock
+func (o *OpSensor) getAlertRule(name string) (AlertRule, bool) {
+	for _, r := range o.config.AlertRules {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return AlertRule{}, false
+}
+
+// removeAlertRule removes the rule and its state and returns the action that resets its alert, the caller must hold the rulesLock
+func (o *OpSensor) removeAlertRule(ctx *base.Context, name string) (bool, alertAction) {
+	for i, r := range o.config.AlertRules {
+		if r.Name != name {
+			continue
+		}
+		o.config.AlertRules = append(o.config.AlertRules[:i:i], o.config.AlertRules[i+1:]...)
+		state, ok := o.ruleStates[name]
+		if !ok {
+			return true, nil
+		}
+		if state.timer != nil {
+			state.timer.Stop()
+			state.timer = nil
+		}
+		delete(o.ruleStates, name)
+		if state.active {
+			return true, func() { o.GE.ResetSystemAlert(ctx, r.Name, r.getAlertCategory()) }
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// SetAlertRuleArgs describes a rule that sets the alert Name while the condition on the property of the sensor is met
+type SetAlertRuleArgs struct {
+	Name           string
+	SensorCategory string
+	SensorName     string
+	PropertyName   string
+	Operator       string
+	Threshold      string
+	Severity       *int // 3 if not set
+	AlertCategory  *string
+	Duration       *time.Duration
+	Hysteresis     *float64
+}
+
+// OperatorSuggestions returns the supported comparisons
+func (a *SetAlertRuleArgs) OperatorSuggestions() []string {
+	return alertRuleOperators
+}
+
+// SeveritySuggestions returns the severities of alerts
+func (a *SetAlertRuleArgs) SeveritySuggestions() []string {
+	return []string{"1", "2", "3", "4", "5"}
+}
+
+// SetAlertRule creates or replaces the alert rule with the given name and stores it in the config
+func (o *OpSensor) SetAlertRule(ctx *base.Context, input *base.OperatorIO, args SetAlertRuleArgs) *base.OperatorIO {
+	r := AlertRule{Name: args.Name, Severity: 3, SensorCategory: args.SensorCategory, SensorName: args.SensorName, PropertyName: args.PropertyName, Operator: args.Operator, Threshold: args.Threshold}
+	if args.Severity != nil {
+		r.Severity = *args.Severity
+	}
+	if args.AlertCategory != nil {
+		r.AlertCategory = *args.AlertCategory
+	}
+	if args.Duration != nil {
+		r.Duration = *args.Duration
+	}
+	if args.Hysteresis != nil {
+		r.Hysteresis = *args.Hysteresis
+	}
+	if err := r.validate(); err != nil {
+		return base.MakeOutputError(http.StatusBadRequest, "Invalid alert rule: %v", err)
+	}
+	if _, err := o.getSensorID(r.SensorCategory, r.SensorName); err != nil {
+		return base.MakeOutputError(http.StatusBadRequest, "Invalid alert rule: %v", err)
+	}
+
+	o.rulesLock.Lock()
+	_, resetOld := o.removeAlertRule(ctx, r.Name)
+	o.config.AlertRules = append(o.config.AlertRules, r)
+	err := o.CR.WriteSection("sensor", o.config, true)
+	var setNew alertAction
+	// the property might already meet the condition
+	value := o.getSensorProperty(r.SensorCategory, r.SensorName, r.PropertyName)
+	if !value.IsError() {
+		setNew = o.evaluateAlertRule(ctx, r, value, time.Now())
+	}
+	o.rulesLock.Unlock()
+	runAlertActions([]alertAction{resetOld, setNew})
+
+	if err != nil {
+		return base.MakeOutputError(http.StatusInternalServerError, "Cannot write config: %v", err)
+	}
+	return base.MakeEmptyOutput()
+}
+
+// AlertRuleNameArgs selects an alert rule
+type AlertRuleNameArgs struct {
+	Name string
+}
+
+// NameSuggestions returns the names of all alert rules
+func (a *AlertRuleNameArgs) NameSuggestions(otherArgs base.FunctionArguments, o *OpSensor) []string {
+	o.rulesLock.Lock()
+	defer o.rulesLock.Unlock()
+	names := []string{}
+	for _, r := range o.config.AlertRules {
+		names = append(names, r.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DeleteAlertRule removes the alert rule from the config and resets its alert
+func (o *OpSensor) DeleteAlertRule(ctx *base.Context, input *base.OperatorIO, args AlertRuleNameArgs) *base.OperatorIO {
+	o.rulesLock.Lock()
+	found, reset := o.removeAlertRule(ctx, args.Name)
+	var err error
+	if found {
+		err = o.CR.WriteSection("sensor", o.config, true)
+	}
+	o.rulesLock.Unlock()
+	runAlertActions([]alertAction{reset})
+
+	if !found {
+		return base.MakeOutputError(http.StatusNotFound, "Alert rule \"%v\" does not exist", args.Name)
+	}
+	if err != nil {
+		return base.MakeOutputError(http.StatusInternalServerError, "Cannot write config: %v", err)
+	}
+	return base.MakeEmptyOutput()
+}
+
+// ReadableAlertRule is an alert rule with its current state
+type ReadableAlertRule struct {
+	AlertRule
+	Active       bool
+	PendingSince time.Time `json:",omitempty"`
+}
+
+// GetAlertRules returns all alert rules and whether their alert is set
+func (o *OpSensor) GetAlertRules(ctx *base.Context, input *base.OperatorIO) *base.OperatorIO {
+	o.rulesLock.Lock()
+	defer o.rulesLock.Unlock()
+	rules := []ReadableAlertRule{}
+	for _, r := range o.config.AlertRules {
+		rr := ReadableAlertRule{AlertRule: r}
+		if state, ok := o.ruleStates[r.Name]; ok {
+			rr.Active = state.active
+			rr.PendingSince = state.pendingSince
+		}
+		rules = append(rules, rr)
+	}
+	return base.MakeObjectOutput(rules)
+}
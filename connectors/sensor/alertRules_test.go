@@ -0,0 +1,94 @@
+package sensor_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+	"github.com/hannesrauhe/freeps/connectors/sensor"
+	"github.com/hannesrauhe/freeps/freepsd/helper"
+	"gotest.tools/v3/assert"
+)
+
+func TestSensorAlertRules(t *testing.T) {
+	ctx, ge, cr := helper.SetupEngineWithCommonOperators(t, nil)
+	op := sensor.GetGlobalSensors()
+
+	isActive := func(name string) bool {
+		return !ge.ExecuteOperatorByName(ctx, "alert", "IsActiveAlert", base.NewFunctionArguments(map[string]string{"Name": name, "Category": "sensor"}), base.MakeEmptyOutput()).IsError()
+	}
+	setTemperature := func(v float64) {
+		res := op.SetSensorProperties(ctx, base.MakeEmptyOutput(), sensor.SensorArgs{SensorName: "livingroom", SensorCategory: "climate"}, base.NewSingleFunctionArgument("temperature", fmt.Sprint(v)))
+		assert.Assert(t, !res.IsError(), res.GetString())
+	}
+
+	setTemperature(20)
+	hysteresis := 1.0
+	res := op.SetAlertRule(ctx, base.MakeEmptyOutput(), sensor.SetAlertRuleArgs{Name: "tooHot", SensorCategory: "climate", SensorName: "livingroom", PropertyName: "temperature", Operator: ">", Threshold: "25", Hysteresis: &hysteresis})
+	assert.Assert(t, !res.IsError(), res.GetString())
+	res = op.SetAlertRule(ctx, base.MakeEmptyOutput(), sensor.SetAlertRuleArgs{Name: "invalid", SensorCategory: "climate", SensorName: "livingroom", PropertyName: "temperature", Operator: ">=", Threshold: "25"})
+	assert.Equal(t, res.HTTPCode, 400)
+	res = op.SetAlertRule(ctx, base.MakeEmptyOutput(), sensor.SetAlertRuleArgs{Name: "invalid", SensorCategory: "climate", SensorName: "livingroom", PropertyName: "temperature", Operator: "<", Threshold: "cold"})
+	assert.Equal(t, res.HTTPCode, 400)
+
+	/* hysteresis */
+	setTemperature(25.5)
+	assert.Assert(t, isActive("tooHot"))
+	setTemperature(24.5)
+	assert.Assert(t, isActive("tooHot"))
+	setTemperature(23.9)
+	assert.Assert(t, !isActive("tooHot"))
+
+	/* the rule is evaluated when it is set */
+	res = op.SetAlertRule(ctx, base.MakeEmptyOutput(), sensor.SetAlertRuleArgs{Name: "notFreezing", SensorCategory: "climate", SensorName: "livingroom", PropertyName: "temperature", Operator: "==", Threshold: "23.9"})
+	assert.Assert(t, !res.IsError(), res.GetString())
+	assert.Assert(t, isActive("notFreezing"))
+	res = op.DeleteAlertRule(ctx, base.MakeEmptyOutput(), sensor.AlertRuleNameArgs{Name: "notFreezing"})
+	assert.Assert(t, !res.IsError(), res.GetString())
+	assert.Assert(t, !isActive("notFreezing"))
+	res = op.DeleteAlertRule(ctx, base.MakeEmptyOutput(), sensor.AlertRuleNameArgs{Name: "notFreezing"})
+	assert.Equal(t, res.HTTPCode, 404)
+
+	/* the condition must be met for the duration, even if the sensor does not send any updates */
+	duration := 50 * time.Millisecond
+	res = op.SetAlertRule(ctx, base.MakeEmptyOutput(), sensor.SetAlertRuleArgs{Name: "tooCold", SensorCategory: "climate", SensorName: "livingroom", PropertyName: "temperature", Operator: "<", Threshold: "18", Duration: &duration})
+	assert.Assert(t, !res.IsError(), res.GetString())
+	setTemperature(17)
+	assert.Assert(t, !isActive("tooCold"))
+	setTemperature(19)
+	time.Sleep(2 * duration)
+	assert.Assert(t, !isActive("tooCold"))
+	setTemperature(16)
+	time.Sleep(2 * duration)
+	assert.Assert(t, isActive("tooCold"))
+
+	/* changedSince */
+	res = op.SetAlertRule(ctx, base.MakeEmptyOutput(), sensor.SetAlertRuleArgs{Name: "windowOpen", SensorCategory: "climate", SensorName: "livingroom", PropertyName: "temperature", Operator: "changedSince", Threshold: "3", Duration: &duration})
+	assert.Assert(t, !res.IsError(), res.GetString())
+	time.Sleep(2 * duration)
+	setTemperature(14)
+	assert.Assert(t, !isActive("windowOpen"))
+	setTemperature(12)
+	assert.Assert(t, !isActive("windowOpen"))
+	setTemperature(10.5)
+	assert.Assert(t, isActive("windowOpen"))
+	/* the alert is reset when the change leaves the time span, even if the sensor does not send any updates */
+	time.Sleep(2 * duration)
+	assert.Assert(t, !isActive("windowOpen"))
+	setTemperature(11)
+	assert.Assert(t, !isActive("windowOpen"))
+
+	rules := []sensor.ReadableAlertRule{}
+	assert.NilError(t, op.GetAlertRules(ctx, base.MakeEmptyOutput()).ParseJSON(&rules))
+	assert.Equal(t, len(rules), 3)
+	assert.Equal(t, rules[1].Name, "tooCold")
+	assert.Assert(t, rules[1].Active)
+
+	/* rules are persisted in the config */
+	cfg := sensor.SensorConfig{}
+	assert.NilError(t, cr.ReadSectionWithDefaults("sensor", &cfg))
+	assert.Equal(t, len(cfg.AlertRules), 3)
+	assert.Equal(t, cfg.AlertRules[0].Hysteresis, 1.0)
+	assert.Equal(t, cfg.AlertRules[0].Severity, 3)
+}
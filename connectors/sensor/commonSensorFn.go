@@ -36,11 +36,64 @@ func (o *OpSensor) getCategoryIndex() (base.FunctionArguments, error) {
 	if v.IsError() {
 		return nil, v.GetError()
 	}
-	categories, ok := v.GetData().Output.(base.FunctionArguments)
-	if !ok {
+	return parseCategoryIndex(v.GetData())
+}
+
+// parseCategoryIndex returns the category index, namespaces that are not kept in memory return it as JSON
+func parseCategoryIndex(io *base.OperatorIO) (base.FunctionArguments, error) {
+	if categories, ok := io.Output.(base.FunctionArguments); ok {
+		return categories, nil
+	}
+	m := map[string][]string{}
+	if err := io.ParseJSON(&m); err != nil {
 		return nil, fmt.Errorf("category index is in an invalid format")
 	}
-	return categories, nil
+	return base.NewFunctionArgumentsFromURLValues(m), nil
+}
+
+// parseSensor returns the property index of a sensor, namespaces that are not kept in memory return it as JSON
+func parseSensor(sensorID string, io *base.OperatorIO) (Sensor, error) {
+	if sensorInformation, ok := io.Output.(Sensor); ok {
+		return sensorInformation, nil
+	}
+	sensorInformation := Sensor{}
+	if err := io.ParseJSON(&sensorInformation); err != nil {
+		return Sensor{}, fmt.Errorf("existing properties for \"%s\" are in an invalid format", sensorID)
+	}
+	return sensorInformation, nil
+}
+
+// updateSensorKeys passes the entries of the keys in the sensor namespace to fn and writes the values returned by fn.
+// The keys are updated all-or-nothing if the namespace supports transactions. Otherwise they are written one after
+// another in the given order while updateLock keeps other sensor updates out.
+func (o *OpSensor) updateSensorKeys(ctx *base.Context, keys []freepsstore.TransactionKey, fn func(entries map[freepsstore.TransactionKey]freepsstore.StoreEntry) (map[freepsstore.TransactionKey]*base.OperatorIO, error)) error {
+	store := freepsstore.GetGlobalStore()
+	if store.SupportsTransactions("_sensors") {
+		return store.Transaction(keys, fn, ctx)
+	}
+
+	o.updateLock.Lock()
+	defer o.updateLock.Unlock()
+	ns := o.getSensorNamespace()
+	entries := map[freepsstore.TransactionKey]freepsstore.StoreEntry{}
+	for _, k := range keys {
+		entries[k] = ns.GetValue(k.Key)
+	}
+	updates, err := fn(entries)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		io := updates[k]
+		if io == nil {
+			continue
+		}
+		e := ns.SetValue(k.Key, io, ctx)
+		if e.IsError() {
+			return fmt.Errorf("Cannot write key \"%v\": %v", k.Key, e.GetError())
+		}
+	}
+	return nil
 }
 
 func (o *OpSensor) getSensorCategories() ([]string, error) {
@@ -57,11 +110,7 @@ func (o *OpSensor) getPropertyIndex(sensorID string) (Sensor, error) {
 	if v.IsError() {
 		return Sensor{}, v.GetError()
 	}
-	sensorInformation, ok := v.GetData().Output.(Sensor)
-	if !ok {
-		return Sensor{}, fmt.Errorf("existing properties for \"%s\" are in an invalid format", sensorID)
-	}
-	return sensorInformation, nil
+	return parseSensor(sensorID, v.GetData())
 }
 
 func (o *OpSensor) setSensorPropertyNoTrigger(ctx *base.Context, input *base.OperatorIO, sensorCategory string, sensorName string, sensorProperty string) (*base.OperatorIO, bool, bool, bool) {
@@ -70,79 +119,74 @@ func (o *OpSensor) setSensorPropertyNoTrigger(ctx *base.Context, input *base.Ope
 		return base.MakeOutputError(http.StatusBadRequest, "%v", err.Error()), false, false, false
 	}
 
-	ns := o.getSensorNamespace()
-	propertyKey := sensorID + "." + sensorProperty
+	propertyKey := freepsstore.TransactionKey{Namespace: "_sensors", Key: sensorID + "." + sensorProperty}
+	sensorKey := freepsstore.TransactionKey{Namespace: "_sensors", Key: sensorID}
+	categoriesKey := freepsstore.TransactionKey{Namespace: "_sensors", Key: "_categories"}
 
 	updatedProperty := false
 	newProperty := false
 	newSensor := false
 
-	ent := ns.UpdateTransaction(propertyKey, func(oldProp freepsstore.StoreEntry) *base.OperatorIO {
+	// the property, the property index of the sensor and the category index are updated together, the indexes are
+	// written after the property so that they never reference a property that does not exist
+	err = o.updateSensorKeys(ctx, []freepsstore.TransactionKey{propertyKey, sensorKey, categoriesKey}, func(entries map[freepsstore.TransactionKey]freepsstore.StoreEntry) (map[freepsstore.TransactionKey]*base.OperatorIO, error) {
+		updatedProperty, newProperty, newSensor = false, false, false
+		updates := map[freepsstore.TransactionKey]*base.OperatorIO{}
+
+		oldProp := entries[propertyKey]
 		if oldProp.IsError() {
 			updatedProperty = true
 			newProperty = true
-			return input
-		}
-		oldP := oldProp.GetData().GetString()
-		if len(oldP) >= base.MAXSTRINGLENGTH {
-			updatedProperty = true
-		} else if oldP != input.GetString() {
-			updatedProperty = true
+		} else {
+			oldP := oldProp.GetData().GetString()
+			if len(oldP) >= base.MAXSTRINGLENGTH || oldP != input.GetString() {
+				updatedProperty = true
+			}
 		}
 		if !updatedProperty {
-			// tell the store that nothing has changed and to not touch the value
-			out := base.MakeEmptyOutput()
-			out.HTTPCode = http.StatusContinue
-			return out
+			// nothing has changed, do not touch the value
+			return updates, nil
 		}
-		return input
-	}, ctx)
-
-	if ent.IsError() {
-		return ent.GetData(), false, false, false
-	}
+		updates[propertyKey] = input
 
-	// update the sensor if the property is new
-	if newProperty {
-		sensorEnt := ns.UpdateTransaction(sensorID, func(v freepsstore.StoreEntry) *base.OperatorIO {
+		// update the sensor if the property is new
+		if newProperty {
 			sensorInformation := Sensor{}
+			v := entries[sensorKey]
 			if v.IsError() {
 				newSensor = true
 				sensorInformation.Properties = []string{sensorProperty}
 			} else {
-				ok := false
-				sensorInformation, ok = v.GetData().Output.(Sensor)
-				if !ok {
-					return base.MakeInternalServerErrorOutput(fmt.Errorf("existing properties for \"%s\" are in an invalid format", sensorID))
-				}
-				if newProperty {
-					sensorInformation.Properties = append(sensorInformation.Properties, sensorProperty)
+				var err error
+				sensorInformation, err = parseSensor(sensorID, v.GetData())
+				if err != nil {
+					return nil, err
 				}
+				// copy the properties, the stored index must stay untouched if the update fails
+				sensorInformation.Properties = append([]string{}, sensorInformation.Properties...)
+				sensorInformation.Properties = append(sensorInformation.Properties, sensorProperty)
 			}
-			return base.MakeObjectOutput(sensorInformation)
-		}, ctx)
-
-		if sensorEnt.IsError() {
-			return sensorEnt.GetData(), false, false, false
+			updates[sensorKey] = base.MakeObjectOutput(sensorInformation)
 		}
-	}
 
-	// update the category index if the sensor is new
-	if newSensor {
-		catEnt := ns.UpdateTransaction("_categories", func(v freepsstore.StoreEntry) *base.OperatorIO {
-			categories, ok := v.GetData().Output.(base.FunctionArguments)
-			if !ok {
-				return base.MakeInternalServerErrorOutput(fmt.Errorf("category index is in an invalid format"))
+		// update the category index if the sensor is new
+		if newSensor {
+			categories, err := parseCategoryIndex(entries[categoriesKey].GetData())
+			if err != nil {
+				return nil, err
 			}
 			if !categories.ContainsValue(sensorCategory, sensorName) {
+				// modify a copy, the stored index must stay untouched if the transaction fails
+				categories = base.NewFunctionArgumentsFromURLValues(categories.GetOriginalCaseMap())
 				categories.Append(sensorCategory, sensorName)
 			}
-			return base.MakeObjectOutput(categories)
-		}, ctx)
-
-		if catEnt.IsError() {
-			return catEnt.GetData(), false, false, false
+			updates[categoriesKey] = base.MakeObjectOutput(categories)
 		}
+		return updates, nil
+	})
+
+	if err != nil {
+		return base.MakeInternalServerErrorOutput(err), false, false, false
 	}
 
 	return base.MakeEmptyOutput(), newSensor, newProperty, updatedProperty
@@ -150,6 +194,7 @@ func (o *OpSensor) setSensorPropertyNoTrigger(ctx *base.Context, input *base.Ope
 
 func (o *OpSensor) setSensorProperties(ctx *base.Context, sensorCategory string, sensorName string, properties map[string]interface{}) *base.OperatorIO {
 	updatedProperties := map[string]interface{}{}
+	unchangedProperties := map[string]interface{}{}
 	for k, v := range properties {
 		out, _, _, updated := o.setSensorPropertyNoTrigger(ctx, base.MakeOutputGuessType(v), sensorCategory, sensorName, k)
 		if out.IsError() {
@@ -157,12 +202,17 @@ func (o *OpSensor) setSensorProperties(ctx *base.Context, sensorCategory string,
 		}
 		if updated {
 			updatedProperties[k] = v
+		} else {
+			unchangedProperties[k] = v
 		}
 	}
 	if len(updatedProperties) > 0 {
 
 		o.recordUpdatesAndTrigger(ctx, sensorCategory, sensorName, updatedProperties)
 	}
+	if len(unchangedProperties) > 0 {
+		o.recordHistory(ctx, sensorCategory, sensorName, unchangedProperties, true)
+	}
 	return base.MakeEmptyOutput()
 }
 
@@ -204,6 +254,8 @@ func (o *OpSensor) getSensorAlias(sensorCategory string, sensorName string) *bas
 
 func (o *OpSensor) recordUpdatesAndTrigger(ctx *base.Context, sensorCategory string, sensorName string, changedProperties map[string]interface{}) {
 	o.executeTriggers(ctx, sensorCategory, sensorName, changedProperties)
+	o.evaluateAlertRules(ctx, sensorCategory, sensorName, changedProperties)
+	o.recordHistory(ctx, sensorCategory, sensorName, changedProperties, false)
 
 	if o.config.InfluxInstancePerCategory == nil || o.config.InfluxPropertiesPerCategory == nil {
 		return
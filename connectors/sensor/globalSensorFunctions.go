@@ -35,6 +35,24 @@ func (op *OpSensor) GetSensorNamesInternal(ctx *base.Context, sensorCategory str
 	return cat.GetValues(sensorCategory), nil
 }
 
+// GetSensorCategoriesInternal returns all sensor categories
+func (op *OpSensor) GetSensorCategoriesInternal(ctx *base.Context) ([]string, error) {
+	return op.getSensorCategories()
+}
+
+// GetSensorPropertyKeysInternal returns the names of all properties of a sensor
+func (op *OpSensor) GetSensorPropertyKeysInternal(ctx *base.Context, sensorCategory string, sensorName string) ([]string, error) {
+	sensorID, err := op.getSensorID(sensorCategory, sensorName)
+	if err != nil {
+		return nil, err
+	}
+	sensorInformation, err := op.getPropertyIndex(sensorID)
+	if err != nil {
+		return nil, err
+	}
+	return sensorInformation.Properties, nil
+}
+
 // GetSensorPropertyInternal returns the value of a sensor property
 func (op *OpSensor) GetSensorPropertyInternal(ctx *base.Context, sensorCategory string, sensorName string, propertyName string) *base.OperatorIO {
 	return op.GetSensorProperty(ctx, base.MakeEmptyOutput(), GetSensorArgs{SensorName: sensorName, SensorCategory: sensorCategory, PropertyName: &propertyName})
@@ -49,6 +67,8 @@ func (op *OpSensor) SetSensorPropertyInternal(ctx *base.Context, sensorCategory
 
 	if updated {
 		op.recordUpdatesAndTrigger(ctx, sensorCategory, sensorName, map[string]interface{}{propertyName: value})
+	} else {
+		op.recordHistory(ctx, sensorCategory, sensorName, map[string]interface{}{propertyName: value}, true)
 	}
 	return nil
 }
@@ -0,0 +1,306 @@
+package sensor
+
+import (
+	"math"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+	freepsstore "github.com/hannesrauhe/freeps/connectors/store"
+)
+
+const sensorHistoryNamespace = "_sensor_history"
+
+// maxRawSamples limits the memory used by properties that are updated very often, older samples are merged into the hourly values earlier
+const maxRawSamples = 10000
+
+// SensorHistorySample is a single value or the aggregation of all values within an hour
+type SensorHistorySample struct {
+	Time  time.Time
+	Min   float64
+	Avg   float64
+	Max   float64
+	Count int
+}
+
+// SensorHistorySamples is a time series of a sensor property, the oldest sample first
+type SensorHistorySamples []SensorHistorySample
+
+// Averages returns the average of every sample, e.g. to draw a sparkline
+func (s SensorHistorySamples) Averages() []float64 {
+	values := make([]float64, len(s))
+	for i, sample := range s {
+		values[i] = sample.Avg
+	}
+	return values
+}
+
+// SensorPropertyHistory keeps the recent values of a property and hourly aggregations of older values
+type SensorPropertyHistory struct {
+	Raw    SensorHistorySamples
+	Hourly SensorHistorySamples
+}
+
+// mergeSamples returns the aggregation of both samples
+func mergeSamples(h SensorHistorySample, s SensorHistorySample) SensorHistorySample {
+	h.Avg = (h.Avg*float64(h.Count) + s.Avg*float64(s.Count)) / float64(h.Count+s.Count)
+	h.Min = math.Min(h.Min, s.Min)
+	h.Max = math.Max(h.Max, s.Max)
+	h.Count += s.Count
+	return h
+}
+
+// addToHourly merges the sample into the hourly samples, samples have to be added in chronological order
+func addToHourly(hourly SensorHistorySamples, s SensorHistorySample) SensorHistorySamples {
+	hour := s.Time.Truncate(time.Hour)
+	if len(hourly) == 0 || !hourly[len(hourly)-1].Time.Equal(hour) {
+		return append(hourly, SensorHistorySample{Time: hour, Min: s.Min, Avg: s.Avg, Max: s.Max, Count: s.Count})
+	}
+	hourly[len(hourly)-1] = mergeSamples(hourly[len(hourly)-1], s)
+	return hourly
+}
+
+// lastTime returns the time of the newest sample
+func (h *SensorPropertyHistory) lastTime() time.Time {
+	if len(h.Raw) > 0 {
+		return h.Raw[len(h.Raw)-1].Time
+	}
+	if len(h.Hourly) > 0 {
+		return h.Hourly[len(h.Hourly)-1].Time
+	}
+	return time.Time{}
+}
+
+// add appends the new value, raw values older than rawDuration are merged into hourly values and hourly values older than maxAge are dropped.
+// Samples that are part of the history are never modified, so readers can keep using a history while a new value is added:
+// the raw samples are only appended and re-sliced, only the last hourly sample is replaced in a copy of the (small) hourly samples.
+func (h *SensorPropertyHistory) add(value float64, now time.Time, rawDuration time.Duration, maxAge time.Duration) {
+	raw := append(h.Raw, SensorHistorySample{Time: now, Min: value, Avg: value, Max: value, Count: 1})
+	merged := 0
+	for merged < len(raw) && (now.Sub(raw[merged].Time) > rawDuration || len(raw)-merged > maxRawSamples) {
+		merged++
+	}
+	hourly := h.Hourly
+	if merged > 0 {
+		hourly = append(SensorHistorySamples{}, h.Hourly...)
+		for _, s := range raw[:merged] {
+			hourly = addToHourly(hourly, s)
+		}
+	}
+	dropped := 0
+	for dropped < len(hourly) && now.Sub(hourly[dropped].Time) > maxAge {
+		dropped++
+	}
+	h.Raw = raw[merged:]
+	h.Hourly = hourly[dropped:]
+}
+
+// samples returns all samples not older than maxAge, the raw samples are aggregated as well if hourly is true
+func (h SensorPropertyHistory) samples(maxAge time.Duration, hourly bool, now time.Time) SensorHistorySamples {
+	ret := SensorHistorySamples{}
+	// an hourly sample is returned if a part of the hour is within maxAge
+	for _, s := range h.Hourly {
+		if now.Sub(s.Time.Add(time.Hour)) <= maxAge {
+			ret = append(ret, s)
+		}
+	}
+	for _, s := range h.Raw {
+		if now.Sub(s.Time) > maxAge {
+			continue
+		}
+		if hourly {
+			ret = addToHourly(ret, s)
+		} else {
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
+
+// getHistoryNamespace returns the namespace of the history, it is kept across restarts unless the store config says otherwise
+func (o *OpSensor) getHistoryNamespace() (freepsstore.StoreNamespace, error) {
+	return freepsstore.GetGlobalStore().GetNamespaceWithDefault(sensorHistoryNamespace, freepsstore.StoreNamespaceConfig{NamespaceType: "memory", PersistInterval: 5 * time.Minute})
+}
+
+func (o *OpSensor) getHistoryDurations() (time.Duration, time.Duration, time.Duration) {
+	rawDuration := o.config.HistoryRawDuration
+	if rawDuration <= 0 {
+		rawDuration = 24 * time.Hour
+	}
+	maxAge := o.config.HistoryDuration
+	if maxAge <= 0 {
+		maxAge = 30 * 24 * time.Hour
+	}
+	repeatInterval := o.config.HistoryRepeatInterval
+	if repeatInterval <= 0 {
+		repeatInterval = time.Hour
+	}
+	return rawDuration, maxAge, repeatInterval
+}
+
+func parsePropertyHistory(e freepsstore.StoreEntry) (SensorPropertyHistory, error) {
+	if h, ok := e.GetData().Output.(SensorPropertyHistory); ok {
+		return h, nil
+	}
+	// the history has been restored from a snapshot
+	h := SensorPropertyHistory{}
+	err := e.ParseJSON(&h)
+	return h, err
+}
+
+// recordHistory adds the numeric values of all properties that are configured to be kept in the history, if the properties
+// have not changed they are only added if the newest sample is older than the repeat interval
+func (o *OpSensor) recordHistory(ctx *base.Context, sensorCategory string, sensorName string, properties map[string]interface{}, unchanged bool) {
+	if o.config.HistoryPropertiesPerCategory == nil {
+		return
+	}
+	historyProperties, ok := o.config.HistoryPropertiesPerCategory[sensorCategory]
+	if !ok {
+		return
+	}
+	sensorID, err := o.getSensorID(sensorCategory, sensorName)
+	if err != nil {
+		return
+	}
+	ns, err := o.getHistoryNamespace()
+	if err != nil {
+		ctx.GetLogger().Errorf("Cannot record sensor history: %v", err)
+		return
+	}
+	rawDuration, maxAge, repeatInterval := o.getHistoryDurations()
+	now := time.Now()
+	for k, v := range properties {
+		if !slices.Contains(historyProperties, "*") && !slices.Contains(historyProperties, k) {
+			continue
+		}
+		value := base.MakeOutputGuessType(v).GetFloat64(true)
+		if math.IsNaN(value) {
+			continue
+		}
+		ns.UpdateTransaction(sensorID+"."+k, func(e freepsstore.StoreEntry) *base.OperatorIO {
+			h := SensorPropertyHistory{}
+			if e != freepsstore.NotFoundEntry {
+				h, _ = parsePropertyHistory(e)
+			}
+			if unchanged && now.Sub(h.lastTime()) < repeatInterval {
+				return &base.OperatorIO{OutputType: base.Empty, HTTPCode: http.StatusContinue}
+			}
+			h.add(value, now, rawDuration, maxAge)
+			return base.MakeObjectOutput(h)
+		}, ctx)
+	}
+}
+
+func (o *OpSensor) getPropertyHistory(sensorCategory string, sensorName string, propertyName string) (SensorPropertyHistory, *base.OperatorIO) {
+	sensorID, err := o.getSensorID(sensorCategory, sensorName)
+	if err != nil {
+		return SensorPropertyHistory{}, base.MakeOutputError(http.StatusBadRequest, "%v", err.Error())
+	}
+	ns, err := o.getHistoryNamespace()
+	if err != nil {
+		return SensorPropertyHistory{}, base.MakeInternalServerErrorOutput(err)
+	}
+	e := ns.GetValue(sensorID + "." + propertyName)
+	if e == freepsstore.NotFoundEntry {
+		return SensorPropertyHistory{}, base.MakeOutputError(http.StatusNotFound, "There is no history for property %v of sensor %v", propertyName, sensorID)
+	}
+	h, err := parsePropertyHistory(e)
+	if err != nil {
+		return SensorPropertyHistory{}, base.MakeOutputError(http.StatusInternalServerError, "History of property %v of sensor %v is invalid: %v", propertyName, sensorID, err)
+	}
+	return h, nil
+}
+
+type GetSensorHistoryArgs struct {
+	SensorName     string
+	SensorCategory string
+	PropertyName   string
+	MaxAge         *time.Duration // 24h if not set
+	Hourly         *bool          // aggregate the recent values hourly as well
+}
+
+func (a *GetSensorHistoryArgs) PropertyNameSuggestions(otherArgs base.FunctionArguments, o *OpSensor) []string {
+	return o.historyPropertySuggestions(otherArgs)
+}
+
+// historyPropertySuggestions returns the properties of a sensor that are kept in the history
+func (o *OpSensor) historyPropertySuggestions(otherArgs base.FunctionArguments) []string {
+	ret := []string{}
+	if o.config.HistoryPropertiesPerCategory == nil {
+		return ret
+	}
+	properties := o.config.HistoryPropertiesPerCategory[otherArgs.Get("SensorCategory")]
+	if !slices.Contains(properties, "*") {
+		return properties
+	}
+	sensorID, err := o.getSensorID(otherArgs.Get("SensorCategory"), otherArgs.Get("SensorName"))
+	if err != nil {
+		return ret
+	}
+	sensorInformation, err := o.getPropertyIndex(sensorID)
+	if err != nil {
+		return ret
+	}
+	return sensorInformation.Properties
+}
+
+// GetHistory returns the recorded values of a property, older values are aggregated hourly
+func (o *OpSensor) GetHistory(ctx *base.Context, input *base.OperatorIO, args GetSensorHistoryArgs) *base.OperatorIO {
+	h, errOutput := o.getPropertyHistory(args.SensorCategory, args.SensorName, args.PropertyName)
+	if errOutput != nil {
+		return errOutput
+	}
+	maxAge := 24 * time.Hour
+	if args.MaxAge != nil {
+		maxAge = *args.MaxAge
+	}
+	return base.MakeObjectOutput(h.samples(maxAge, args.Hourly != nil && *args.Hourly, time.Now()))
+}
+
+type GetSensorStatisticsArgs struct {
+	SensorName     string
+	SensorCategory string
+	PropertyName   string
+	Window         *time.Duration // 24h if not set
+}
+
+func (a *GetSensorStatisticsArgs) PropertyNameSuggestions(otherArgs base.FunctionArguments, o *OpSensor) []string {
+	return o.historyPropertySuggestions(otherArgs)
+}
+
+// SensorStatistics summarizes the values of a property within a time window
+type SensorStatistics struct {
+	Min   float64
+	Avg   float64
+	Max   float64
+	Count int
+	From  time.Time // time of the oldest sample within the window
+	To    time.Time // time of the newest sample
+}
+
+// GetStatistics returns minimum, maximum and average of a property within the window
+func (o *OpSensor) GetStatistics(ctx *base.Context, input *base.OperatorIO, args GetSensorStatisticsArgs) *base.OperatorIO {
+	h, errOutput := o.getPropertyHistory(args.SensorCategory, args.SensorName, args.PropertyName)
+	if errOutput != nil {
+		return errOutput
+	}
+	window := 24 * time.Hour
+	if args.Window != nil {
+		window = *args.Window
+	}
+	samples := h.samples(window, false, time.Now())
+	if len(samples) == 0 {
+		return base.MakeOutputError(http.StatusNotFound, "No values of property %v within %v", args.PropertyName, window)
+	}
+	stats := SensorStatistics{Min: math.Inf(1), Max: math.Inf(-1), From: samples[0].Time, To: samples[len(samples)-1].Time}
+	sum := 0.0
+	for _, s := range samples {
+		stats.Min = math.Min(stats.Min, s.Min)
+		stats.Max = math.Max(stats.Max, s.Max)
+		sum += s.Avg * float64(s.Count)
+		stats.Count += s.Count
+	}
+	stats.Avg = sum / float64(stats.Count)
+	return base.MakeObjectOutput(stats)
+}
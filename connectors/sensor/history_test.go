@@ -0,0 +1,125 @@
+package sensor_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+	"github.com/hannesrauhe/freeps/connectors/sensor"
+	"github.com/hannesrauhe/freeps/freepsd/helper"
+	"gotest.tools/v3/assert"
+)
+
+func TestSensorHistory(t *testing.T) {
+	rawDuration := 50 * time.Millisecond
+	ctx, _, _ := helper.SetupEngineWithCommonOperators(t, map[string]interface{}{"sensor": sensor.SensorConfig{
+		Enabled:                      true,
+		AliasKeys:                    []string{"name"},
+		HistoryPropertiesPerCategory: map[string][]string{"climate": {"temperature", "humidity"}, "power": {"*"}},
+		HistoryRawDuration:           rawDuration,
+	}})
+	op := sensor.GetGlobalSensors()
+
+	set := func(category string, props map[string]string) {
+		res := op.SetSensorProperties(ctx, base.MakeEmptyOutput(), sensor.SensorArgs{SensorName: "s1", SensorCategory: category}, base.NewFunctionArguments(props))
+		assert.Assert(t, !res.IsError(), res.GetString())
+	}
+	getHistory := func(category string, property string, hourly bool) sensor.SensorHistorySamples {
+		samples := sensor.SensorHistorySamples{}
+		res := op.GetHistory(ctx, base.MakeEmptyOutput(), sensor.GetSensorHistoryArgs{SensorName: "s1", SensorCategory: category, PropertyName: property, Hourly: &hourly})
+		assert.Assert(t, !res.IsError(), res.GetString())
+		assert.NilError(t, res.ParseJSON(&samples))
+		return samples
+	}
+
+	set("climate", map[string]string{"temperature": "20", "name": "living room", "humidity": "dry"})
+	set("climate", map[string]string{"temperature": "22"})
+	set("power", map[string]string{"watts": "100", "volts": "230"})
+
+	/* only configured numeric properties are recorded */
+	res := op.GetHistory(ctx, base.MakeEmptyOutput(), sensor.GetSensorHistoryArgs{SensorName: "s1", SensorCategory: "climate", PropertyName: "name"})
+	assert.Equal(t, res.HTTPCode, 404)
+	res = op.GetHistory(ctx, base.MakeEmptyOutput(), sensor.GetSensorHistoryArgs{SensorName: "s1", SensorCategory: "climate", PropertyName: "humidity"})
+	assert.Equal(t, res.HTTPCode, 404)
+	assert.Equal(t, len(getHistory("power", "volts", false)), 1)
+
+	samples := getHistory("climate", "temperature", false)
+	assert.Equal(t, len(samples), 2)
+	assert.Equal(t, samples[1].Avg, 22.0)
+	assert.DeepEqual(t, samples.Averages(), []float64{20, 22})
+
+	/* old values are merged into hourly min/avg/max */
+	time.Sleep(2 * rawDuration)
+	set("climate", map[string]string{"temperature": "27"})
+	samples = getHistory("climate", "temperature", false)
+	last := samples[len(samples)-1]
+	assert.Equal(t, last.Count, 1)
+	assert.Equal(t, last.Avg, 27.0)
+	merged := 0
+	for _, s := range samples[:len(samples)-1] {
+		assert.Equal(t, s.Time, s.Time.Truncate(time.Hour))
+		merged += s.Count
+	}
+	assert.Equal(t, merged, 2)
+
+	hourly := getHistory("climate", "temperature", true)
+	assert.Assert(t, len(hourly) <= 2)
+	total := 0
+	for _, s := range hourly {
+		total += s.Count
+	}
+	assert.Equal(t, total, 3)
+
+	stats := sensor.SensorStatistics{}
+	res = op.GetStatistics(ctx, base.MakeEmptyOutput(), sensor.GetSensorStatisticsArgs{SensorName: "s1", SensorCategory: "climate", PropertyName: "temperature"})
+	assert.NilError(t, res.ParseJSON(&stats))
+	assert.Equal(t, stats.Count, 3)
+	assert.Equal(t, stats.Min, 20.0)
+	assert.Equal(t, stats.Max, 27.0)
+	assert.Equal(t, stats.Avg, 23.0)
+
+	window := 10 * time.Millisecond
+	time.Sleep(2 * window)
+	res = op.GetStatistics(ctx, base.MakeEmptyOutput(), sensor.GetSensorStatisticsArgs{SensorName: "s1", SensorCategory: "power", PropertyName: "watts", Window: &window})
+	assert.Equal(t, res.HTTPCode, 404)
+}
+
+func TestSensorHistoryUnchangedValues(t *testing.T) {
+	repeatInterval := 50 * time.Millisecond
+	ctx, _, _ := helper.SetupEngineWithCommonOperators(t, map[string]interface{}{"sensor": sensor.SensorConfig{
+		Enabled:                      true,
+		HistoryPropertiesPerCategory: map[string][]string{"climate": {"temperature"}},
+		HistoryRepeatInterval:        repeatInterval,
+	}})
+	op := sensor.GetGlobalSensors()
+
+	set := func(value string) {
+		res := op.SetSensorProperties(ctx, base.MakeEmptyOutput(), sensor.SensorArgs{SensorName: "s1", SensorCategory: "climate"}, base.NewSingleFunctionArgument("temperature", value))
+		assert.Assert(t, !res.IsError(), res.GetString())
+	}
+	count := func() int {
+		samples := sensor.SensorHistorySamples{}
+		res := op.GetHistory(ctx, base.MakeEmptyOutput(), sensor.GetSensorHistoryArgs{SensorName: "s1", SensorCategory: "climate", PropertyName: "temperature"})
+		assert.NilError(t, res.ParseJSON(&samples))
+		return len(samples)
+	}
+
+	set("20")
+	set("20")
+	assert.Equal(t, count(), 1)
+
+	/* a value that does not change is recorded again after the repeat interval, so it is weighted like values that change often */
+	time.Sleep(2 * repeatInterval)
+	set("20")
+	assert.Equal(t, count(), 2)
+	set("20")
+	assert.Equal(t, count(), 2)
+	value := "20"
+	res := op.SetSingleSensorProperty(ctx, base.MakeEmptyOutput(), sensor.SetSensorPropertyArgs{SensorName: "s1", SensorCategory: "climate", PropertyName: "temperature", PropertyValue: &value})
+	assert.Assert(t, !res.IsError(), res.GetString())
+	assert.Equal(t, count(), 2)
+	time.Sleep(2 * repeatInterval)
+	res = op.SetSingleSensorProperty(ctx, base.MakeEmptyOutput(), sensor.SetSensorPropertyArgs{SensorName: "s1", SensorCategory: "climate", PropertyName: "temperature", PropertyValue: &value})
+	assert.Assert(t, !res.IsError(), res.GetString())
+	assert.Equal(t, count(), 3)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/hannesrauhe/freeps/base"
 	freepsstore "github.com/hannesrauhe/freeps/connectors/store"
@@ -13,9 +14,12 @@ import (
 
 // OpSensor is an operator to manage sensors of different types in your Smart Home, these sensors can be created by the user or by other operators. The operator provices a set of methods to interact with the sensors.
 type OpSensor struct {
-	CR     *utils.ConfigReader
-	GE     *freepsflow.FlowEngine
-	config *SensorConfig
+	CR         *utils.ConfigReader
+	GE         *freepsflow.FlowEngine
+	config     *SensorConfig
+	rulesLock  sync.Mutex
+	ruleStates map[string]*alertRuleState
+	updateLock sync.Mutex // serializes updates of namespaces that do not support transactions
 }
 
 type Sensor struct {
@@ -26,7 +30,7 @@ var _ base.FreepsOperator = &OpSensor{}
 var _ base.FreepsOperatorWithConfig = &OpSensor{}
 
 func (op *OpSensor) GetDefaultConfig() interface{} {
-	return &SensorConfig{Enabled: true, AliasKeys: []string{"name", "alias"}}
+	return &SensorConfig{Enabled: true, AliasKeys: []string{"name", "alias"}, AlertRules: []AlertRule{}}
 }
 
 func (op *OpSensor) InitCopyOfOperator(ctx *base.Context, config interface{}, name string) (base.FreepsOperatorWithConfig, error) {
@@ -35,7 +39,7 @@ func (op *OpSensor) InitCopyOfOperator(ctx *base.Context, config interface{}, na
 	}
 	opc := config.(*SensorConfig)
 
-	globalSensor = &OpSensor{CR: op.CR, GE: op.GE, config: opc}
+	globalSensor = &OpSensor{CR: op.CR, GE: op.GE, config: opc, ruleStates: map[string]*alertRuleState{}}
 	ns, err := freepsstore.GetGlobalStore().GetNamespace("_sensors")
 	if err != nil {
 		return nil, err
@@ -174,6 +178,8 @@ func (o *OpSensor) SetSingleSensorProperty(ctx *base.Context, input *base.Operat
 
 	if updated {
 		o.recordUpdatesAndTrigger(ctx, args.SensorCategory, args.SensorName, map[string]interface{}{args.PropertyName: input.Output})
+	} else {
+		o.recordHistory(ctx, args.SensorCategory, args.SensorName, map[string]interface{}{args.PropertyName: input.Output}, true)
 	}
 
 	return out
@@ -1,6 +1,7 @@
 package sensor_test
 
 import (
+	"path"
 	"testing"
 	"time"
 
@@ -254,3 +255,34 @@ func TestGetSensorPropertiesByAlias(t *testing.T) {
 	//	  }
 	//	}`)
 }
+
+func TestSensorPropertySettingEmbedded(t *testing.T) {
+	storeConfig := freepsstore.StoreConfig{Namespaces: map[string]freepsstore.StoreNamespaceConfig{
+		"_sensors": {NamespaceType: "embedded", File: path.Join(t.TempDir(), "sensors.jsonl")},
+	}}
+	ctx, _, _ := helper.SetupEngineWithCommonOperators(t, map[string]interface{}{"store": storeConfig})
+	op := sensor.GetGlobalSensors()
+	assert.Assert(t, !freepsstore.GetGlobalStore().SupportsTransactions("_sensors"))
+
+	sensorProperty := "temperature"
+	set := func(name string, value string) {
+		res := op.SetSensorProperties(ctx, base.MakeEmptyOutput(), sensor.SensorArgs{SensorName: name, SensorCategory: "climate"}, base.NewSingleFunctionArgument(sensorProperty, value))
+		assert.Assert(t, !res.IsError(), res.GetString())
+	}
+	set("s1", "20")
+	set("s1", "21")
+	set("s2", "22")
+	res := op.GetSensorProperty(ctx, base.MakeEmptyOutput(), sensor.GetSensorArgs{SensorName: "s1", SensorCategory: "climate", PropertyName: &sensorProperty})
+	assert.Equal(t, res.GetString(), "21")
+
+	/* the property index is read back from the journal as JSON after a restart */
+	ctx, _, _ = helper.SetupEngineWithCommonOperators(t, map[string]interface{}{"store": storeConfig})
+	op = sensor.GetGlobalSensors()
+	res = op.SetSensorProperties(ctx, base.MakeEmptyOutput(), sensor.SensorArgs{SensorName: "s1", SensorCategory: "climate"}, base.NewSingleFunctionArgument("humidity", "40"))
+	assert.Assert(t, !res.IsError(), res.GetString())
+	res = op.GetSensorPropertyKeys(ctx, base.MakeEmptyOutput(), sensor.SensorArgs{SensorName: "s1", SensorCategory: "climate"})
+	assert.Assert(t, !res.IsError(), res.GetString())
+	keys := []string{}
+	assert.NilError(t, res.ParseJSON(&keys))
+	assert.DeepEqual(t, keys, []string{sensorProperty, "humidity"})
+}
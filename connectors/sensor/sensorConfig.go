@@ -1,8 +1,16 @@
 package sensor
 
+import "time"
+
 type SensorConfig struct {
 	Enabled                     bool
 	AliasKeys                   []string
 	InfluxInstancePerCategory   map[string]string
 	InfluxPropertiesPerCategory map[string][]string
+	AlertRules                  []AlertRule // managed with SetAlertRule and DeleteAlertRule
+
+	HistoryPropertiesPerCategory map[string][]string // numeric properties that are kept in the local history, "*" for all properties of a category
+	HistoryRawDuration           time.Duration       `json:",omitempty"` // values are merged into hourly min/avg/max after this, 24h if 0
+	HistoryDuration              time.Duration       `json:",omitempty"` // hourly values are kept this long, 30 days if 0
+	HistoryRepeatInterval        time.Duration       `json:",omitempty"` // unchanged values are recorded again after this, so that averages are not biased towards values that change often; 1h if 0
 }
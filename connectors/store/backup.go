@@ -0,0 +1,192 @@
+package freepsstore
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+)
+
+// storeExportVersion is increased whenever the format of the export changes in an incompatible way
+const storeExportVersion = 1
+
+// StoreExport contains the entries of multiple namespaces including the type, content type and metadata of every value
+type StoreExport struct {
+	Version    int
+	Created    time.Time
+	Namespaces map[string][]persistedRecord
+}
+
+// namespaces that are modified during every flow execution and therefore not exported by default
+var volatileNamespaces = map[string]bool{executionLogNamespace: true, debugNamespace: true, jobsNamespace: true}
+
+// Export returns all entries of the given namespaces, all namespaces but the ones that are modified during every execution if none are given
+func (s *Store) Export(namespaces []string) (StoreExport, error) {
+	exp := StoreExport{Version: storeExportVersion, Created: time.Now(), Namespaces: map[string][]persistedRecord{}}
+	if len(namespaces) == 0 {
+		for _, ns := range s.GetNamespaces() {
+			if !volatileNamespaces[ns] {
+				namespaces = append(namespaces, ns)
+			}
+		}
+		sort.Strings(namespaces)
+	}
+	for _, ns := range namespaces {
+		nsStore, err := s.GetNamespace(ns)
+		if err != nil {
+			return exp, err
+		}
+		records := []persistedRecord{}
+		for _, k := range nsStore.GetKeys() {
+			e := nsStore.GetValue(k)
+			if e == NotFoundEntry || e.IsError() {
+				continue
+			}
+			r, err := entryToRecord(k, e)
+			if err != nil {
+				return exp, fmt.Errorf("Cannot export key \"%v\" in namespace \"%v\": %v", k, ns, err)
+			}
+			records = append(records, r)
+		}
+		exp.Namespaces[ns] = records
+	}
+	return exp, nil
+}
+
+// Import sets all entries of the export in the store, if replace is set all other entries of the imported namespaces are deleted
+// and the triggers of the deleted keys are executed with ctx
+func (s *Store) Import(ctx *base.Context, exp StoreExport, replace bool) (map[string]int, error) {
+	if exp.Version != storeExportVersion {
+		return nil, fmt.Errorf("Unsupported export version %v, expected %v", exp.Version, storeExportVersion)
+	}
+	imported := map[string]int{}
+	for ns, records := range exp.Namespaces {
+		nsStore, err := s.GetNamespace(ns)
+		if err != nil {
+			return imported, err
+		}
+		s.globalLock.Lock()
+		isLog := s.namespaceConfigs[ns].NamespaceType == "log"
+		s.globalLock.Unlock()
+
+		if replace {
+			if isLog {
+				nsStore.Trim(0)
+			} else {
+				keep := map[string]bool{}
+				for _, r := range records {
+					keep[strings.ToLower(r.Key)] = true
+				}
+				for _, k := range nsStore.GetKeys() {
+					if !keep[strings.ToLower(k)] {
+						DeleteValueWithContext(nsStore, k, ctx)
+					}
+				}
+			}
+		}
+
+		imported[ns] = 0
+		for _, r := range records {
+			if r.Deleted {
+				continue
+			}
+			key := r.Key
+			if isLog {
+				// log namespaces generate their own keys, the entries are appended in the order of the export
+				key = ""
+			}
+			e := r.toEntry()
+			res := importEntry(ctx, nsStore, key, e)
+			if res.IsError() {
+				return imported, fmt.Errorf("Cannot import key \"%v\" in namespace \"%v\": %v", r.Key, ns, res.GetError())
+			}
+			imported[ns]++
+		}
+	}
+	return imported, nil
+}
+
+// importEntry sets the entry with its original timestamp if the namespace supports it, otherwise with the current time;
+// the history and the triggers of the namespace are updated as by SetValue
+func importEntry(ctx *base.Context, nsStore StoreNamespace, key string, e StoreEntry) StoreEntry {
+	switch w := nsStore.(type) {
+	case *triggeringStoreNamespace:
+		if !w.hasTriggers() {
+			return importEntry(ctx, w.StoreNamespace, key, e)
+		}
+		old := w.StoreNamespace.GetValue(key)
+		res := importEntry(ctx, w.StoreNamespace, key, e)
+		if res.data == e.data {
+			w.executeTriggers(ctx, key, "set", old, e.data)
+		}
+		return res
+	case *historyStoreNamespace:
+		old := w.previous(key)
+		res := importEntry(ctx, w.StoreNamespace, key, e)
+		if res.data == e.data {
+			w.record(key, res, old)
+		}
+		return res
+	case restoringNamespace:
+		return w.restoreEntry(key, e)
+	}
+	return nsStore.SetValue(key, e.data, e.modifiedBy)
+}
+
+// ExportArgs are the arguments for the Export function
+type ExportArgs struct {
+	Namespaces *string // comma-separated list of namespaces, all namespaces but _execution_log, _debug and _jobs if empty
+}
+
+// Export returns a versioned JSON document with all entries of the selected namespaces that can be imported again
+func (o *OpStore) Export(ctx *base.Context, input *base.OperatorIO, args ExportArgs) *base.OperatorIO {
+	namespaces := []string{}
+	if args.Namespaces != nil && *args.Namespaces != "" {
+		for _, ns := range strings.Split(*args.Namespaces, ",") {
+			namespaces = append(namespaces, strings.TrimSpace(ns))
+		}
+	}
+	exp, err := store.Export(namespaces)
+	if err != nil {
+		return base.MakeOutputError(http.StatusInternalServerError, "Cannot export store: %v", err)
+	}
+	return base.MakeObjectOutput(exp)
+}
+
+// ImportArgs are the arguments for the Import function
+type ImportArgs struct {
+	Mode *string // "merge" keeps entries that are not part of the import, "replace" deletes them; "merge" if empty
+}
+
+// ModeSuggestions returns the import modes
+func (p *ImportArgs) ModeSuggestions() []string {
+	return []string{"merge", "replace"}
+}
+
+// Import sets all entries of an export given as input and returns the number of imported entries per namespace
+func (o *OpStore) Import(ctx *base.Context, input *base.OperatorIO, args ImportArgs) *base.OperatorIO {
+	replace := false
+	if args.Mode != nil {
+		switch *args.Mode {
+		case "", "merge":
+		case "replace":
+			replace = true
+		default:
+			return base.MakeOutputError(http.StatusBadRequest, "Unknown import mode \"%v\"", *args.Mode)
+		}
+	}
+	exp := StoreExport{}
+	err := input.ParseJSON(&exp)
+	if err != nil {
+		return base.MakeOutputError(http.StatusBadRequest, "Cannot parse export: %v", err)
+	}
+	imported, err := store.Import(ctx, exp, replace)
+	if err != nil {
+		return base.MakeOutputError(http.StatusInternalServerError, "%v", err)
+	}
+	ctx.GetLogger().Infof("Imported %v into the store", imported)
+	return base.MakeObjectOutput(imported)
+}
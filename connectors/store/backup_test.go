@@ -0,0 +1,86 @@
+package freepsstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+	"gotest.tools/v3/assert"
+)
+
+func TestExportImport(t *testing.T) {
+	s, ctx := prepareStore(t)
+
+	ns := store.GetNamespaceNoError("backup")
+	ns.SetValue("plain", base.MakePlainOutput("hello"), ctx)
+	ns.SetValue("image", base.MakeByteOutputWithContentType([]byte{0x89, 0x50, 0x4e, 0x47, 0x00, 0xff}, "image/png"), ctx)
+	ns.SetValue("object", base.MakeObjectOutput(map[string]int{"a": 1}), ctx)
+	logNs, err := store.CreateNamespace("backupLog", StoreNamespaceConfig{NamespaceType: "log"})
+	assert.NilError(t, err)
+	logNs.SetValue("", base.MakePlainOutput("first"), ctx)
+	logNs.SetValue("", base.MakePlainOutput("second"), ctx)
+	versioned, err := store.CreateNamespace("backupVersioned", StoreNamespaceConfig{NamespaceType: "memory", HistorySize: 3})
+	assert.NilError(t, err)
+	versioned.SetValue("config", base.MakePlainOutput("v1"), ctx)
+	plainTimestamp := ns.GetValue("plain").GetTimestamp()
+	firstTimestamp := logNs.GetValue("0").GetTimestamp()
+	time.Sleep(10 * time.Millisecond)
+
+	exp := s.Execute(ctx, "export", base.NewSingleFunctionArgument("namespaces", "backup, backupLog, backupVersioned"), base.MakeEmptyOutput())
+	assert.Assert(t, !exp.IsError(), exp.GetString())
+	b, err := exp.GetBytes()
+	assert.NilError(t, err)
+
+	/* import into a fresh store */
+	exportCtx := ctx
+	s, ctx = prepareStore(t)
+	ns = store.GetNamespaceNoError("backup")
+	ns.SetValue("stale", base.MakePlainOutput("stale"), ctx)
+	_, err = store.CreateNamespace("backupLog", StoreNamespaceConfig{NamespaceType: "log"})
+	assert.NilError(t, err)
+	versioned, err = store.CreateNamespace("backupVersioned", StoreNamespaceConfig{NamespaceType: "memory", HistorySize: 3})
+	assert.NilError(t, err)
+
+	out := s.Execute(ctx, "import", base.MakeEmptyFunctionArguments(), base.MakeByteOutput(b))
+	assert.Assert(t, !out.IsError(), out.GetString())
+	imported := map[string]int{}
+	assert.NilError(t, out.ParseJSON(&imported))
+	assert.Equal(t, imported["backup"], 3)
+	assert.Equal(t, imported["backupLog"], 2)
+	assert.Equal(t, ns.Len(), 4)
+
+	image := ns.GetValue("image").GetData()
+	assert.Equal(t, image.ContentType, "image/png")
+	imageBytes, err := image.GetBytes()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, imageBytes, []byte{0x89, 0x50, 0x4e, 0x47, 0x00, 0xff})
+	assert.Equal(t, ns.GetValue("plain").GetData().GetString(), "hello")
+	assert.Equal(t, ns.GetValue("plain").GetModifiedBy(), exportCtx.GetID())
+	assert.Assert(t, ns.GetValue("plain").GetTimestamp().Equal(plainTimestamp))
+	obj := map[string]int{}
+	assert.NilError(t, ns.GetValue("object").ParseJSON(&obj))
+	assert.Equal(t, obj["a"], 1)
+
+	logNs = store.GetNamespaceNoError("backupLog")
+	assert.Equal(t, logNs.GetValue("0").GetData().GetString(), "first")
+	assert.Equal(t, logNs.GetValue("1").GetData().GetString(), "second")
+	assert.Assert(t, logNs.GetValue("0").GetTimestamp().Equal(firstTimestamp))
+
+	/* the imported value is part of the history */
+	history, err := store.GetHistory("backupVersioned", "config")
+	assert.NilError(t, err)
+	assert.Equal(t, len(history), 1)
+	assert.Equal(t, versioned.GetValue("config").GetData().GetString(), "v1")
+
+	/* replace deletes the entries that are not part of the export */
+	out = s.Execute(ctx, "import", base.NewSingleFunctionArgument("mode", "replace"), base.MakeByteOutput(b))
+	assert.Assert(t, !out.IsError(), out.GetString())
+	assert.Equal(t, ns.Len(), 3)
+	assert.Equal(t, ns.GetValue("stale"), NotFoundEntry)
+	assert.Equal(t, logNs.Len(), 2)
+
+	out = s.Execute(ctx, "import", base.NewSingleFunctionArgument("mode", "overwrite"), base.MakeByteOutput(b))
+	assert.Equal(t, out.HTTPCode, 400)
+	out = s.Execute(ctx, "import", base.MakeEmptyFunctionArguments(), base.MakeObjectOutput(StoreExport{Version: 99}))
+	assert.Assert(t, out.IsError())
+}
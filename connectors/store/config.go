@@ -1,8 +1,17 @@
 package freepsstore
 
+import (
+	"time"
+
+	"github.com/hannesrauhe/freeps/freepsflow"
+)
+
 var fileNamespace = "_files"
 var debugNamespace = "_debug"
 var executionLogNamespace = "_execution_log"
+var jobsNamespace = "_jobs"
+var flowNamespace = "_flows"
+var flowVersionsNamespace = "_flow_versions"
 
 // StoreConfig contains all start-parameters for the store
 type StoreConfig struct {
@@ -24,6 +33,19 @@ type StoreNamespaceConfig struct {
 
 	/* log */
 	AutoTrim int `json:",omitempty"`
+
+	/* all types */
+	TTL         time.Duration `json:",omitempty"` // entries older than TTL are removed by the janitor; never if 0
+	MaxEntries  int           `json:",omitempty"` // the least recently modified entries are removed by the janitor if there are more; unlimited if 0
+	MaxBytes    int           `json:",omitempty"` // the least recently modified entries are removed by the janitor if all values are larger in total; unlimited if 0
+	HistorySize int           `json:",omitempty"` // number of versions of every key that are kept in memory; no history if 0
+	Triggers    bool          `json:",omitempty"` // execute the flows tagged with "store" and this namespace whenever a key changes
+
+	/* embedded, memory and log */
+	File string `json:",omitempty"` // journal or snapshot file of the namespace; a file named like the namespace in the store directory next to the config file if empty
+
+	/* memory and log */
+	PersistInterval time.Duration `json:",omitempty"` // write a snapshot to File in this interval and on shutdown and restore it on startup; never if 0
 }
 
 func getDefaultNamespaces() map[string]StoreNamespaceConfig {
@@ -49,5 +71,15 @@ func getDefaultNamespaces() map[string]StoreNamespaceConfig {
 	namespaces[debugNamespace] = StoreNamespaceConfig{
 		NamespaceType: "memory",
 	}
+	// the flows as used by the engine, separate from the drafts of the flowbuilder in the flow namespace
+	namespaces[flowVersionsNamespace] = StoreNamespaceConfig{
+		NamespaceType: "memory",
+		HistorySize:   20,
+	}
+	// the flow engine forgets finished jobs after JobRetention as well
+	namespaces[jobsNamespace] = StoreNamespaceConfig{
+		NamespaceType: "memory",
+		TTL:           freepsflow.JobRetention,
+	}
 	return namespaces
 }
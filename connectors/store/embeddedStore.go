@@ -0,0 +1,361 @@
+package freepsstore
+
+import (
+	"bufio"
+	"encoding/json"
+	"math"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+	"github.com/hannesrauhe/freeps/utils"
+)
+
+// minimum number of superfluous records in the journal before it is compacted
+const embeddedStoreCompactionThreshold = 1000
+
+// embeddedStoreNamespace keeps all entries in memory and appends every modification to a journal file,
+// the journal is replayed on start and rewritten once it contains too many overwritten or deleted records
+type embeddedStoreNamespace struct {
+	mem     *inMemoryStoreNamespace
+	file    string
+	journal *os.File
+	records int
+	ttl     time.Duration
+	nsLock  sync.Mutex
+}
+
+var _ StoreNamespace = &embeddedStoreNamespace{}
+
+func newEmbeddedStoreNamespace(dataDir string, ns string, namespaceConfig StoreNamespaceConfig) (*embeddedStoreNamespace, error) {
+	file := namespaceConfig.File
+	if file == "" {
+		file = path.Join(dataDir, utils.StringToIdentifier(ns)+".db")
+	}
+	err := os.MkdirAll(path.Dir(file), 0777)
+	if err != nil {
+		return nil, err
+	}
+	s := &embeddedStoreNamespace{mem: newInMemoryStoreNamespace(), file: file, ttl: namespaceConfig.TTL}
+	err = s.load()
+	if err != nil {
+		return nil, err
+	}
+	// start with a clean journal, this also gets rid of expired entries
+	err = s.compactUnlocked()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load replays the journal, a corrupt record (e.g. a partially written last line after a power loss) ends the replay
+func (s *embeddedStoreNamespace) load() error {
+	f, err := os.Open(s.file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), math.MaxInt32)
+	for scanner.Scan() {
+		r := persistedRecord{}
+		if json.Unmarshal(scanner.Bytes(), &r) != nil {
+			break
+		}
+		if r.Deleted {
+			s.mem.deleteValueUnlocked(r.Key)
+			continue
+		}
+		lowerCaseKey := strings.ToLower(r.Key)
+		s.mem.entries[lowerCaseKey] = r.toEntry()
+		s.mem.originalCaseKeys[lowerCaseKey] = r.Key
+	}
+	return scanner.Err()
+}
+
+// compactUnlocked rewrites the journal with a single record per entry
+func (s *embeddedStoreNamespace) compactUnlocked() error {
+	s.dropExpiredUnlocked()
+	var sb strings.Builder
+	for lk, e := range s.mem.entries {
+		r, err := entryToRecord(s.mem.originalCaseKeys[lk], e)
+		if err != nil {
+			return err
+		}
+		b, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		sb.Write(b)
+		sb.WriteString("\n")
+	}
+	if s.journal != nil {
+		s.journal.Close()
+		s.journal = nil
+	}
+	err := writeFileAtomic(path.Dir(s.file), s.file, []byte(sb.String()))
+	if err != nil {
+		return err
+	}
+	s.journal, err = os.OpenFile(s.file, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	s.records = len(s.mem.entries)
+	return nil
+}
+
+// appendUnlocked writes a record to the journal and compacts the journal if necessary
+func (s *embeddedStoreNamespace) appendUnlocked(r persistedRecord) error {
+	if s.journal == nil {
+		// a previous compaction failed, try again
+		return s.compactUnlocked()
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = s.journal.Write(append(b, '\n'))
+	if err != nil {
+		return err
+	}
+	s.records++
+	if s.records-len(s.mem.entries) > embeddedStoreCompactionThreshold && s.records > 2*len(s.mem.entries) {
+		return s.compactUnlocked()
+	}
+	return nil
+}
+
+// persistUnlocked writes the entry that has been set in memory to the journal
+func (s *embeddedStoreNamespace) persistUnlocked(key string, e StoreEntry) StoreEntry {
+	r, err := entryToRecord(key, e)
+	if err == nil {
+		err = s.appendUnlocked(r)
+	}
+	if err != nil {
+		return MakeEntryError(http.StatusInternalServerError, "Value was set but could not be persisted: %v", err)
+	}
+	return e
+}
+
+func (s *embeddedStoreNamespace) deleteValueUnlocked(key string) {
+	s.mem.deleteValueUnlocked(key)
+	s.appendUnlocked(persistedRecord{Key: key, Deleted: true, Timestamp: time.Now()})
+}
+
+func (s *embeddedStoreNamespace) isExpired(e StoreEntry, tnow time.Time) bool {
+	return s.ttl > 0 && e != NotFoundEntry && e.timestamp.Add(s.ttl).Before(tnow)
+}
+
+// dropExpiredUnlocked removes entries older than the TTL from memory, they do not need to be journaled because they are expired on replay as well
+func (s *embeddedStoreNamespace) dropExpiredUnlocked() {
+	if s.ttl <= 0 {
+		return
+	}
+	tnow := time.Now()
+	for lk, e := range s.mem.entries {
+		if s.isExpired(e, tnow) {
+			s.mem.deleteValueUnlocked(lk)
+		}
+	}
+}
+
+func (s *embeddedStoreNamespace) getValueUnlocked(key string) StoreEntry {
+	e := s.mem.getValueUnlocked(key)
+	if s.isExpired(e, time.Now()) {
+		s.mem.deleteValueUnlocked(key)
+		return NotFoundEntry
+	}
+	return e
+}
+
+// GetValue from the StoreNamespace
+func (s *embeddedStoreNamespace) GetValue(key string) StoreEntry {
+	s.nsLock.Lock()
+	defer s.nsLock.Unlock()
+	return s.getValueUnlocked(key)
+}
+
+// GetValueBeforeExpiration gets the value from the StoreNamespace, but returns error if older than maxAge
+func (s *embeddedStoreNamespace) GetValueBeforeExpiration(key string, maxAge time.Duration) StoreEntry {
+	e := s.GetValue(key)
+	if e == NotFoundEntry {
+		return e
+	}
+	if e.timestamp.Add(maxAge).Before(time.Now()) {
+		e.data = base.MakeOutputError(http.StatusGone, "Entry is older than %v", maxAge)
+	}
+	return e
+}
+
+// SetValue in the StoreNamespace
+func (s *embeddedStoreNamespace) SetValue(key string, io *base.OperatorIO, modifiedBy *base.Context) StoreEntry {
+	s.nsLock.Lock()
+	defer s.nsLock.Unlock()
+	return s.persistUnlocked(key, s.mem.setValueUnlocked(key, io, modifiedBy))
+}
+
+// restoreEntry sets the entry including its timestamp
+func (s *embeddedStoreNamespace) restoreEntry(key string, e StoreEntry) StoreEntry {
+	s.nsLock.Lock()
+	defer s.nsLock.Unlock()
+	return s.persistUnlocked(key, s.mem.restoreEntryUnlocked(key, e))
+}
+
+// SetAll sets all values in the StoreNamespace
+func (s *embeddedStoreNamespace) SetAll(valueMap map[string]interface{}, modifiedBy *base.Context) *base.OperatorIO {
+	s.nsLock.Lock()
+	defer s.nsLock.Unlock()
+	for k, v := range valueMap {
+		e := s.persistUnlocked(k, s.mem.setValueUnlocked(k, base.MakeObjectOutput(v), modifiedBy))
+		if e.IsError() {
+			return e.GetData()
+		}
+	}
+	return base.MakeEmptyOutput()
+}
+
+// CompareAndSwap sets the value if the string representation of the already stored value is as expected
+func (s *embeddedStoreNamespace) CompareAndSwap(key string, expected string, newValue *base.OperatorIO, modifiedBy *base.Context) StoreEntry {
+	s.nsLock.Lock()
+	defer s.nsLock.Unlock()
+	oldV := s.getValueUnlocked(key)
+	if oldV == NotFoundEntry {
+		return NotFoundEntry
+	}
+	if oldV.data == nil || oldV.data.GetString() != expected {
+		return MakeEntryError(http.StatusConflict, "old value is different from expectation")
+	}
+	return s.persistUnlocked(key, s.mem.setValueUnlocked(key, newValue, modifiedBy))
+}
+
+// UpdateTransaction updates the value in the StoreNamespace by calling the function fn with the current value
+func (s *embeddedStoreNamespace) UpdateTransaction(key string, fn func(StoreEntry) *base.OperatorIO, modifiedBy *base.Context) StoreEntry {
+	s.nsLock.Lock()
+	defer s.nsLock.Unlock()
+	oldEntry := s.getValueUnlocked(key)
+
+	out := fn(oldEntry)
+	if out.IsError() {
+		return MakeEntry(out, modifiedBy)
+	}
+	if out.HTTPCode == http.StatusContinue {
+		return oldEntry
+	}
+	return s.persistUnlocked(key, s.mem.setValueUnlocked(key, out, modifiedBy))
+}
+
+// OverwriteValueIfOlder sets the value only if the key does not exist or has been written before maxAge
+func (s *embeddedStoreNamespace) OverwriteValueIfOlder(key string, io *base.OperatorIO, maxAge time.Duration, modifiedBy *base.Context) StoreEntry {
+	s.nsLock.Lock()
+	defer s.nsLock.Unlock()
+	n := time.Now()
+	md := s.getValueUnlocked(key)
+	if md != NotFoundEntry && md.timestamp.Add(maxAge).After(n) {
+		return MakeEntryError(http.StatusConflict, "%v already exists and is only %v old", key, n.Sub(md.timestamp))
+	}
+	return s.persistUnlocked(key, s.mem.setValueUnlocked(key, io, modifiedBy))
+}
+
+// DeleteValue from the StoreNamespace
+func (s *embeddedStoreNamespace) DeleteValue(key string) {
+	s.nsLock.Lock()
+	defer s.nsLock.Unlock()
+	s.deleteValueUnlocked(key)
+}
+
+// GetKeys returns all keys in the StoreNamespace
+func (s *embeddedStoreNamespace) GetKeys() []string {
+	s.nsLock.Lock()
+	defer s.nsLock.Unlock()
+	s.dropExpiredUnlocked()
+	keys := []string{}
+	for _, k := range s.mem.originalCaseKeys {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Len returns the number of entries in the StoreNamespace
+func (s *embeddedStoreNamespace) Len() int {
+	s.nsLock.Lock()
+	defer s.nsLock.Unlock()
+	s.dropExpiredUnlocked()
+	return len(s.mem.entries)
+}
+
+// GetAllValues from the StoreNamespace
+func (s *embeddedStoreNamespace) GetAllValues(limit int) map[string]*base.OperatorIO {
+	s.nsLock.Lock()
+	defer s.nsLock.Unlock()
+	s.dropExpiredUnlocked()
+	copy := map[string]*base.OperatorIO{}
+	for lk, v := range s.mem.entries {
+		copy[s.mem.originalCaseKeys[lk]] = v.data
+		if limit != 0 && len(copy) >= limit {
+			return copy
+		}
+	}
+	return copy
+}
+
+// GetSearchResultWithMetadata searches through all keys, optionally finds substring in key, value and ID, and returns only records younger than maxAge
+func (s *embeddedStoreNamespace) GetSearchResultWithMetadata(keyPattern, valuePattern, modifiedByPattern string, minAge, maxAge time.Duration) map[string]StoreEntry {
+	s.nsLock.Lock()
+	defer s.nsLock.Unlock()
+	s.dropExpiredUnlocked()
+	tnow := time.Now()
+	copy := map[string]StoreEntry{}
+	for lk, v := range s.mem.entries {
+		if matches(lk, v, keyPattern, valuePattern, modifiedByPattern, minAge, maxAge, tnow) {
+			copy[s.mem.originalCaseKeys[lk]] = v
+		}
+	}
+	return copy
+}
+
+// DeleteOlder deletes records older than maxAge
+func (s *embeddedStoreNamespace) DeleteOlder(maxAge time.Duration) int {
+	s.nsLock.Lock()
+	defer s.nsLock.Unlock()
+	tnow := time.Now()
+	deleteKeys := []string{}
+	for lk, e := range s.mem.entries {
+		if e.timestamp.Add(maxAge).Before(tnow) {
+			deleteKeys = append(deleteKeys, s.mem.originalCaseKeys[lk])
+		}
+	}
+	for _, k := range deleteKeys {
+		s.deleteValueUnlocked(k)
+	}
+	return len(deleteKeys)
+}
+
+// Trim deletes all but the top k records sorted by timestamp
+func (s *embeddedStoreNamespace) Trim(k int) int {
+	s.nsLock.Lock()
+	defer s.nsLock.Unlock()
+	if k >= len(s.mem.entries) {
+		return 0
+	}
+	topK := utils.NewTopKList(k)
+	deleteKeys := make([]string, 0, len(s.mem.entries)-k)
+	for lk, e := range s.mem.entries {
+		cand := topK.Add(lk, e.timestamp)
+		if cand != nil {
+			deleteKeys = append(deleteKeys, *cand)
+		}
+	}
+	for _, lk := range deleteKeys {
+		s.deleteValueUnlocked(s.mem.originalCaseKeys[lk])
+	}
+	return len(deleteKeys)
+}
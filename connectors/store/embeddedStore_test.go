@@ -0,0 +1,90 @@
+package freepsstore
+
+import (
+	"math"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+	"github.com/sirupsen/logrus"
+	"gotest.tools/v3/assert"
+)
+
+func TestEmbeddedStoreNamespace(t *testing.T) {
+	file := path.Join(t.TempDir(), "sub", "test.db")
+	nsConfig := StoreNamespaceConfig{NamespaceType: "embedded", File: file}
+	nsStore, err := newEmbeddedStoreNamespace("", "test", nsConfig)
+	assert.NilError(t, err)
+	ctx := base.NewBaseContextWithReason(logrus.StandardLogger(), "test")
+
+	assert.Equal(t, nsStore.GetValue("missing"), NotFoundEntry)
+	nsStore.SetValue("plain", base.MakePlainOutput("value"), ctx)
+	nsStore.SetValue("Object", base.MakeObjectOutput(map[string]interface{}{"a": 1}), ctx)
+	nsStore.SetValue("int", base.MakeIntegerOutput(42), ctx)
+	nsStore.SetValue("bytes", base.MakeByteOutputWithContentType([]byte{1, 2}, "image/png"), ctx)
+	nsStore.SetValue("error", base.MakeOutputError(http.StatusTeapot, "short and stout"), ctx)
+	nsStore.SetValue("deleted", base.MakePlainOutput("gone"), ctx)
+	nsStore.DeleteValue("deleted")
+	assert.Assert(t, !nsStore.CompareAndSwap("plain", "value", base.MakePlainOutput("new"), ctx).IsError())
+	nsStore.UpdateTransaction("plain", func(old StoreEntry) *base.OperatorIO {
+		return base.MakePlainOutput(old.GetData().GetString() + "er")
+	}, ctx)
+	assert.Equal(t, nsStore.Len(), 5)
+
+	/* everything survives a restart */
+	nsStore, err = newEmbeddedStoreNamespace("", "test", nsConfig)
+	assert.NilError(t, err)
+	assert.Equal(t, nsStore.Len(), 5)
+	e := nsStore.GetValue("plain")
+	assert.Equal(t, e.GetData().GetString(), "newer")
+	assert.Assert(t, e.GetData().IsPlain())
+	assert.Equal(t, e.GetModifiedBy(), ctx.GetID())
+	assert.Equal(t, e.GetReason(), "test")
+	obj := map[string]int{}
+	assert.NilError(t, nsStore.GetValue("object").ParseJSON(&obj))
+	assert.Equal(t, obj["a"], 1)
+	i, err := nsStore.GetValue("int").GetData().GetInt64(false)
+	assert.NilError(t, err)
+	assert.Equal(t, i, int64(42))
+	assert.Equal(t, nsStore.GetValue("bytes").GetData().ContentType, "image/png")
+	assert.Equal(t, nsStore.GetValue("error").GetData().HTTPCode, http.StatusTeapot)
+	assert.Equal(t, nsStore.GetValue("deleted"), NotFoundEntry)
+	res := nsStore.GetSearchResultWithMetadata("obj", "", "", 0, math.MaxInt64)
+	assert.Equal(t, len(res), 1)
+	assert.Assert(t, res["Object"].GetData().IsObject())
+
+	/* a partially written record is ignored */
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NilError(t, err)
+	f.WriteString(`{"Key":"broken","Val`)
+	f.Close()
+	nsStore, err = newEmbeddedStoreNamespace("", "test", nsConfig)
+	assert.NilError(t, err)
+	assert.Equal(t, nsStore.Len(), 5)
+
+	/* the journal is compacted */
+	for n := 0; n < 3*embeddedStoreCompactionThreshold; n++ {
+		nsStore.SetValue("counter", base.MakeIntegerOutput(n), ctx)
+	}
+	assert.Assert(t, nsStore.records <= embeddedStoreCompactionThreshold+6)
+	assert.Equal(t, nsStore.Trim(2), 4)
+	assert.Equal(t, nsStore.DeleteOlder(time.Hour), 0)
+	assert.Equal(t, nsStore.Len(), 2)
+
+	/* expired entries are neither returned nor restored */
+	nsConfig.TTL = 50 * time.Millisecond
+	nsStore, err = newEmbeddedStoreNamespace("", "test", nsConfig)
+	assert.NilError(t, err)
+	nsStore.SetValue("short", base.MakePlainOutput("lived"), ctx)
+	assert.Equal(t, nsStore.GetValue("short").GetData().GetString(), "lived")
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, nsStore.GetValue("short"), NotFoundEntry)
+	nsStore.SetValue("short", base.MakePlainOutput("lived"), ctx)
+	time.Sleep(60 * time.Millisecond)
+	nsStore, err = newEmbeddedStoreNamespace("", "test", nsConfig)
+	assert.NilError(t, err)
+	assert.Equal(t, nsStore.Len(), 0)
+}
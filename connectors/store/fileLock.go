@@ -0,0 +1,18 @@
+//go:build !windows
+
+package freepsstore
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile acquires an exclusive advisory lock on f, blocking until it is available
+func lockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+}
+
+// unlockFile releases the lock acquired by lockFile
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,17 @@
+//go:build windows
+
+package freepsstore
+
+import (
+	"os"
+)
+
+// lockFile is a noop on Windows, the namespace is only protected against concurrent access from the same process
+func lockFile(f *os.File) error {
+	return nil
+}
+
+// unlockFile is a noop on Windows
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -1,18 +1,24 @@
 package freepsstore
 
 import (
+	"encoding/json"
 	"errors"
 	"io/fs"
+	"math"
 	"net/http"
 	"os"
 	"path"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/hannesrauhe/freeps/base"
 	"github.com/hannesrauhe/freeps/utils"
 )
 
+// name of the file that is locked while the namespace is modified, files starting with a dot are not treated as keys
+const fileStoreLockFile = ".freeps.lock"
+
 func newFileStoreNamespace(namespaceConfig StoreNamespaceConfig) (*fileStoreNamespace, error) {
 	var err error
 	dir := namespaceConfig.Directory
@@ -31,124 +37,370 @@ func newFileStoreNamespace(namespaceConfig StoreNamespaceConfig) (*fileStoreName
 	return ns, nil
 }
 
+// fileStoreNamespace keeps every value in a file named like the key, the metadata of the entry is kept in a hidden sidecar file
+// and the modification time of the value file is the timestamp of the entry
 type fileStoreNamespace struct {
-	dir string
+	dir    string
+	nsLock sync.Mutex
+}
+
+// fileMetadata is the content of the sidecar file
+type fileMetadata struct {
+	ModifiedBy  string `json:",omitempty"`
+	Reason      string `json:",omitempty"`
+	ContentType string `json:",omitempty"`
+	OutputType  base.OutputT
+	HTTPCode    int `json:",omitempty"`
 }
 
 func (p *fileStoreNamespace) getFilePath(key string) (string, error) {
-	//TODO(HR): check if key contains anything that does not belong into the filepath
-	if strings.Contains(key, "/") {
+	if key == "" || strings.ContainsAny(key, "/\\") || strings.HasPrefix(key, ".") {
 		return "", errors.New("Invalid key")
 	}
 	return path.Join(p.dir, key), nil
 }
 
-func makeGenericStoreEntry(io *base.OperatorIO) StoreEntry {
-	return StoreEntry{timestamp: time.Now(), modifiedBy: nil, data: io}
+func (p *fileStoreNamespace) getMetadataPath(key string) string {
+	return path.Join(p.dir, "."+key+".meta")
+}
+
+func isFileStoreKey(de fs.DirEntry) bool {
+	return de.Type().IsRegular() && !strings.HasPrefix(de.Name(), ".")
 }
 
 var _ StoreNamespace = &fileStoreNamespace{}
 
-func (p *fileStoreNamespace) CompareAndSwap(key string, expected string, newValue *base.OperatorIO, modifiedBy *base.Context) StoreEntry {
-	return MakeEntryError(http.StatusNotImplemented, "file support not fully implemented yet")
+// lock protects the namespace against concurrent modifications from this and other processes
+func (p *fileStoreNamespace) lock() (func(), error) {
+	p.nsLock.Lock()
+	f, err := os.OpenFile(path.Join(p.dir, fileStoreLockFile), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		p.nsLock.Unlock()
+		return nil, err
+	}
+	err = lockFile(f)
+	if err != nil {
+		f.Close()
+		p.nsLock.Unlock()
+		return nil, err
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+		p.nsLock.Unlock()
+	}, nil
 }
 
-func (p *fileStoreNamespace) DeleteOlder(maxAge time.Duration) int {
-	panic("not implemented") // TODO: Implement
+// writeFileAtomic writes the file to a temporary file first and renames it afterwards, so that readers never see partial content
+func writeFileAtomic(dir string, filePath string, b []byte) error {
+	f, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Sync()
+	}
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filePath)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
 }
 
-func (p *fileStoreNamespace) Trim(numEntries int) int {
-	panic("not implemented") // TODO: Implement
+// outputFromFile recreates the OperatorIO that was written with the given metadata
+func outputFromFile(b []byte, md fileMetadata) *base.OperatorIO {
+	switch md.OutputType {
+	case base.Empty:
+		return base.MakeEmptyOutput()
+	case base.PlainText:
+		return base.MakePlainOutput(string(b))
+	case base.Object:
+		var obj interface{}
+		if err := json.Unmarshal(b, &obj); err != nil {
+			return base.MakeOutputError(http.StatusInternalServerError, "Stored object is not valid JSON: %v", err)
+		}
+		return base.MakeObjectOutput(obj)
+	case base.Integer, base.FloatingPoint, base.Skipped:
+		o, err := base.MakeOutputWithGivenType(string(b), md.OutputType)
+		if err != nil {
+			return base.MakeOutputError(http.StatusInternalServerError, "Stored value is not a number: %v", err)
+		}
+		return o
+	case base.Error:
+		return base.MakeOutputError(md.HTTPCode, "%s", string(b))
+	}
+	if md.ContentType != "" {
+		return base.MakeByteOutputWithContentType(b, md.ContentType)
+	}
+	return base.MakeByteOutput(b)
 }
 
-func (p *fileStoreNamespace) DeleteValue(key string) {
-	path, err := p.getFilePath(key)
+// outputToFile returns the content and the metadata that is needed to recreate the OperatorIO with outputFromFile
+func outputToFile(io *base.OperatorIO, modifiedBy *base.Context) ([]byte, fileMetadata, error) {
+	md := fileMetadata{ContentType: io.ContentType, OutputType: io.OutputType}
+	if modifiedBy != nil {
+		md.ModifiedBy = modifiedBy.GetID()
+		md.Reason = modifiedBy.GetReason()
+	}
+	if io.IsError() {
+		md.HTTPCode = io.HTTPCode
+		return []byte(io.GetError().Error()), md, nil
+	}
+	if io.IsSkipped() {
+		return []byte(io.GetSkipReason()), md, nil
+	}
+	b, err := io.GetBytes()
+	return b, md, err
+}
+
+// readMetadata returns the metadata of the key, files without sidecar (e.g. copied to the directory manually) are treated as plain byte values
+func (p *fileStoreNamespace) readMetadata(key string) fileMetadata {
+	md := fileMetadata{OutputType: base.Byte}
+	b, err := os.ReadFile(p.getMetadataPath(key))
+	if err == nil {
+		json.Unmarshal(b, &md)
+	}
+	return md
+}
+
+func (p *fileStoreNamespace) getValueUnlocked(key string) StoreEntry {
+	filePath, err := p.getFilePath(key)
+	if err != nil {
+		return MakeEntryError(http.StatusBadRequest, "Failed to get file path: %v", err.Error())
+	}
+	info, err := os.Stat(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return NotFoundEntry
+	}
+	if err != nil {
+		return MakeEntryError(http.StatusInternalServerError, "Failed to open file: %v", err.Error())
+	}
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		return MakeEntryError(http.StatusInternalServerError, "Failed to open file: %v", err.Error())
+	}
+	md := p.readMetadata(key)
+	var modifiedBy *base.Context
+	if md.ModifiedBy != "" {
+		modifiedBy = base.RestoreContext(md.ModifiedBy, md.Reason)
+	}
+	return StoreEntry{data: outputFromFile(b, md), timestamp: info.ModTime(), modifiedBy: modifiedBy}
+}
+
+func (p *fileStoreNamespace) setValueUnlocked(key string, io *base.OperatorIO, modifiedBy *base.Context) StoreEntry {
+	filePath, err := p.getFilePath(key)
+	if err != nil {
+		return MakeEntryError(http.StatusBadRequest, "%v", err.Error())
+	}
+	b, md, err := outputToFile(io, modifiedBy)
+	if err != nil {
+		return MakeEntryError(http.StatusInternalServerError, "%v", err.Error())
+	}
+	mdBytes, err := json.Marshal(md)
+	if err != nil {
+		return MakeEntryError(http.StatusInternalServerError, "%v", err.Error())
+	}
+	// the metadata is written first, the value file is the one that makes the key visible
+	err = writeFileAtomic(p.dir, p.getMetadataPath(key), mdBytes)
+	if err != nil {
+		return MakeEntryError(http.StatusInternalServerError, "%v", err.Error())
+	}
+	err = writeFileAtomic(p.dir, filePath, b)
+	if err != nil {
+		return MakeEntryError(http.StatusInternalServerError, "%v", err.Error())
+	}
+	return StoreEntry{data: io, timestamp: time.Now(), modifiedBy: modifiedBy}
+}
+
+func (p *fileStoreNamespace) deleteValueUnlocked(key string) {
+	filePath, err := p.getFilePath(key)
 	if err != nil {
 		return
 	}
-	os.Remove(path)
+	os.Remove(filePath)
+	os.Remove(p.getMetadataPath(key))
 }
 
-func (p *fileStoreNamespace) GetAllValues(limit int) map[string]*base.OperatorIO {
-	panic("not implemented") // TODO: Implement
+// CompareAndSwap sets the value if the string representation of the already stored value is as expected
+func (p *fileStoreNamespace) CompareAndSwap(key string, expected string, newValue *base.OperatorIO, modifiedBy *base.Context) StoreEntry {
+	unlock, err := p.lock()
+	if err != nil {
+		return MakeEntryError(http.StatusInternalServerError, "Cannot lock namespace: %v", err)
+	}
+	defer unlock()
+	oldV := p.getValueUnlocked(key)
+	if oldV == NotFoundEntry {
+		return NotFoundEntry
+	}
+	if oldV.data == nil || oldV.data.GetString() != expected {
+		return MakeEntryError(http.StatusConflict, "old value is different from expectation")
+	}
+	return p.setValueUnlocked(key, newValue, modifiedBy)
 }
 
-func (p *fileStoreNamespace) GetKeys() []string {
-	res := []string{}
+// getFileInfos returns the file info of all keys
+func (p *fileStoreNamespace) getFileInfos() map[string]fs.FileInfo {
+	res := map[string]fs.FileInfo{}
 	dirEntries, err := os.ReadDir(p.dir)
 	if err != nil {
 		return res
 	}
 	for _, d := range dirEntries {
-		if d.Type().IsRegular() {
-			res = append(res, d.Name())
+		if !isFileStoreKey(d) {
+			continue
+		}
+		info, err := d.Info()
+		if err != nil {
+			continue
 		}
+		res[d.Name()] = info
 	}
 	return res
 }
 
-func fileMatches(de fs.DirEntry, keyPattern, valuePattern, modifiedByPattern string, minAge, maxAge time.Duration, tnow time.Time) *fs.FileInfo {
-	if !de.Type().IsRegular() {
-		return nil
+// DeleteOlder deletes files that have not been modified within maxAge
+func (p *fileStoreNamespace) DeleteOlder(maxAge time.Duration) int {
+	unlock, err := p.lock()
+	if err != nil {
+		return 0
 	}
-	i, err := de.Info()
+	defer unlock()
+	tnow := time.Now()
+	deleted := 0
+	for key, info := range p.getFileInfos() {
+		if info.ModTime().Add(maxAge).Before(tnow) {
+			p.deleteValueUnlocked(key)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// Trim deletes all but the k most recently modified files
+func (p *fileStoreNamespace) Trim(k int) int {
+	unlock, err := p.lock()
 	if err != nil {
-		return nil
+		return 0
+	}
+	defer unlock()
+	infos := p.getFileInfos()
+	if k >= len(infos) {
+		return 0
 	}
-	// if minAge != 0 && v.timestamp.Add(minAge).After(tnow) {
-	// 	return false
-	// }
-	// if maxAge != math.MaxInt64 && v.timestamp.Add(maxAge).Before(tnow) {
-	// 	return false
-	// }
-	// if keyPattern != "" && !strings.Contains(k, keyPattern) {
-	// 	return false
-	// }
-	// if valuePattern != "" && !strings.Contains(v.data.GetString(), valuePattern) {
-	// 	return false
-	// }
-	// if modifiedByPattern != "" && !strings.Contains(v.modifiedBy, modifiedByPattern) {
-	// 	return false
-	// }
-	return &i
+	topK := utils.NewTopKList(k)
+	deleteKeys := make([]string, 0, len(infos)-k)
+	for key, info := range infos {
+		cand := topK.Add(key, info.ModTime())
+		if cand != nil {
+			deleteKeys = append(deleteKeys, *cand)
+		}
+	}
+	for _, key := range deleteKeys {
+		p.deleteValueUnlocked(key)
+	}
+	return len(deleteKeys)
 }
 
-func (p *fileStoreNamespace) GetSearchResultWithMetadata(keyPattern string, valuePattern string, modifiedByPattern string, minAge time.Duration, maxAge time.Duration) map[string]StoreEntry {
-	res := map[string]StoreEntry{}
-	dirEntries, err := os.ReadDir(p.dir)
+// DeleteValue deletes the file and its metadata
+func (p *fileStoreNamespace) DeleteValue(key string) {
+	unlock, err := p.lock()
 	if err != nil {
-		return res
+		return
 	}
-	tnow := time.Now()
-	for _, d := range dirEntries {
-		i := fileMatches(d, keyPattern, valuePattern, modifiedByPattern, maxAge, maxAge, tnow)
-		if i == nil {
+	defer unlock()
+	p.deleteValueUnlocked(key)
+}
+
+// GetAllValues reads up to limit files, all files if limit is 0
+func (p *fileStoreNamespace) GetAllValues(limit int) map[string]*base.OperatorIO {
+	res := map[string]*base.OperatorIO{}
+	for _, key := range p.GetKeys() {
+		e := p.GetValue(key)
+		if e == NotFoundEntry {
 			continue
 		}
-		info := *i
-		res[d.Name()] = StoreEntry{data: base.MakeSprintfOutput("File of size: %v", info.Size()), timestamp: info.ModTime()}
+		res[key] = e.data
+		if limit != 0 && len(res) >= limit {
+			break
+		}
 	}
 	return res
 }
 
-func (p *fileStoreNamespace) GetValue(key string) StoreEntry {
-	path, err := p.getFilePath(key)
-	if err != nil {
-		return MakeEntryError(http.StatusBadRequest, "Failed to get file path: %v", err.Error())
+// GetKeys returns the names of all files in the directory
+func (p *fileStoreNamespace) GetKeys() []string {
+	res := []string{}
+	for key := range p.getFileInfos() {
+		res = append(res, key)
 	}
-	b, err := os.ReadFile(path)
-	if err != nil {
-		return MakeEntryError(500, "Failed to open file: %v", err.Error())
+	return res
+}
+
+// GetSearchResultWithMetadata searches through all files, the age filters are applied before a file is read
+func (p *fileStoreNamespace) GetSearchResultWithMetadata(keyPattern string, valuePattern string, modifiedByPattern string, minAge time.Duration, maxAge time.Duration) map[string]StoreEntry {
+	res := map[string]StoreEntry{}
+	tnow := time.Now()
+	lkeyPattern := strings.ToLower(keyPattern)
+	for key, info := range p.getFileInfos() {
+		if minAge != 0 && info.ModTime().Add(minAge).After(tnow) {
+			continue
+		}
+		if maxAge != math.MaxInt64 && info.ModTime().Add(maxAge).Before(tnow) {
+			continue
+		}
+		if keyPattern != "" && !strings.Contains(strings.ToLower(key), lkeyPattern) {
+			continue
+		}
+		e := p.GetValue(key)
+		if e == NotFoundEntry {
+			continue
+		}
+		if matches(strings.ToLower(key), e, keyPattern, valuePattern, modifiedByPattern, minAge, maxAge, tnow) {
+			res[key] = e
+		}
 	}
-	return makeGenericStoreEntry(base.MakeByteOutput(b))
+	return res
 }
 
+// GetValue reads the file and its metadata
+func (p *fileStoreNamespace) GetValue(key string) StoreEntry {
+	p.nsLock.Lock()
+	defer p.nsLock.Unlock()
+	return p.getValueUnlocked(key)
+}
+
+// GetValueBeforeExpiration reads the file, but returns an error if it was modified before maxAge
 func (p *fileStoreNamespace) GetValueBeforeExpiration(key string, maxAge time.Duration) StoreEntry {
-	return p.GetValue(key)
+	e := p.GetValue(key)
+	if e == NotFoundEntry || e.IsError() {
+		return e
+	}
+	if e.timestamp.Add(maxAge).Before(time.Now()) {
+		e.data = base.MakeOutputError(http.StatusGone, "Entry is older than %v", maxAge)
+	}
+	return e
 }
 
+// OverwriteValueIfOlder writes the file only if it does not exist or has been modified before maxAge
 func (p *fileStoreNamespace) OverwriteValueIfOlder(key string, io *base.OperatorIO, maxAge time.Duration, modifiedBy *base.Context) StoreEntry {
-	return MakeEntryError(http.StatusNotImplemented, "file support not fully implemented yet")
+	unlock, err := p.lock()
+	if err != nil {
+		return MakeEntryError(http.StatusInternalServerError, "Cannot lock namespace: %v", err)
+	}
+	defer unlock()
+	n := time.Now()
+	md := p.getValueUnlocked(key)
+	if md != NotFoundEntry && !md.IsError() && md.timestamp.Add(maxAge).After(n) {
+		return MakeEntryError(http.StatusConflict, "%v already exists and is only %v old", key, n.Sub(md.timestamp))
+	}
+	return p.setValueUnlocked(key, io, modifiedBy)
 }
 
 // Len returns the number of keys in the namespace
@@ -156,31 +408,47 @@ func (p *fileStoreNamespace) Len() int {
 	return len(p.GetKeys())
 }
 
+// SetValue writes the value and its metadata to disk
 func (p *fileStoreNamespace) SetValue(key string, io *base.OperatorIO, modifiedBy *base.Context) StoreEntry {
-	path, err := p.getFilePath(key)
+	unlock, err := p.lock()
 	if err != nil {
-		return MakeEntryError(http.StatusInternalServerError, "%v", err.Error())
+		return MakeEntryError(http.StatusInternalServerError, "Cannot lock namespace: %v", err)
 	}
+	defer unlock()
+	return p.setValueUnlocked(key, io, modifiedBy)
+}
 
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+// UpdateTransaction updates the value by calling the function fn with the current value while the namespace is locked
+func (p *fileStoreNamespace) UpdateTransaction(key string, fn func(StoreEntry) *base.OperatorIO, modifiedBy *base.Context) StoreEntry {
+	unlock, err := p.lock()
 	if err != nil {
-		return MakeEntryError(http.StatusInternalServerError, "%v", err.Error())
+		return MakeEntryError(http.StatusInternalServerError, "Cannot lock namespace: %v", err)
 	}
-	b, err := io.GetBytes()
-	if err != nil {
-		return MakeEntryError(http.StatusInternalServerError, "%v", err.Error())
+	defer unlock()
+	oldEntry := p.getValueUnlocked(key)
+
+	out := fn(oldEntry)
+	if out.IsError() {
+		return MakeEntry(out, modifiedBy)
 	}
-	_, err = f.Write(b)
-	if err != nil {
-		return MakeEntryError(http.StatusInternalServerError, "%v", err.Error())
+	if out.HTTPCode == http.StatusContinue {
+		return oldEntry
 	}
-	return makeGenericStoreEntry(io)
-}
-
-func (p *fileStoreNamespace) UpdateTransaction(key string, fn func(StoreEntry) *base.OperatorIO, modifiedBy *base.Context) StoreEntry {
-	return MakeEntryError(http.StatusNotImplemented, "file support not fully implemented yet")
+	return p.setValueUnlocked(key, out, modifiedBy)
 }
 
+// SetAll writes all values as objects
 func (p *fileStoreNamespace) SetAll(valueMap map[string]interface{}, modifiedBy *base.Context) *base.OperatorIO {
-	return base.MakeOutputError(http.StatusNotImplemented, "file support not fully implemented yet")
+	unlock, err := p.lock()
+	if err != nil {
+		return base.MakeOutputError(http.StatusInternalServerError, "Cannot lock namespace: %v", err)
+	}
+	defer unlock()
+	for k, v := range valueMap {
+		e := p.setValueUnlocked(k, base.MakeObjectOutput(v), modifiedBy)
+		if e.IsError() {
+			return e.GetData()
+		}
+	}
+	return base.MakeEmptyOutput()
 }
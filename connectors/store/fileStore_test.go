@@ -0,0 +1,110 @@
+package freepsstore
+
+import (
+	"math"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+	"github.com/sirupsen/logrus"
+	"gotest.tools/v3/assert"
+)
+
+func TestFileStoreNamespace(t *testing.T) {
+	dir := t.TempDir()
+	nsStore, err := newFileStoreNamespace(StoreNamespaceConfig{NamespaceType: "files", Directory: dir})
+	assert.NilError(t, err)
+	ctx := base.NewBaseContextWithReason(logrus.StandardLogger(), "test")
+
+	assert.Equal(t, nsStore.GetValue("missing"), NotFoundEntry)
+	assert.Assert(t, nsStore.SetValue("../escape", base.MakePlainOutput("x"), ctx).IsError())
+	assert.Assert(t, nsStore.SetValue(".hidden", base.MakePlainOutput("x"), ctx).IsError())
+
+	/* values keep their type and metadata */
+	nsStore.SetValue("plain", base.MakePlainOutput("value"), ctx)
+	nsStore.SetValue("object", base.MakeObjectOutput(map[string]interface{}{"a": 1}), ctx)
+	nsStore.SetValue("int", base.MakeIntegerOutput(42), ctx)
+	nsStore.SetValue("bytes", base.MakeByteOutputWithContentType([]byte{1, 2}, "image/png"), ctx)
+	e := nsStore.GetValue("plain")
+	assert.Equal(t, e.GetData().GetString(), "value")
+	assert.Assert(t, e.GetData().IsPlain())
+	assert.Equal(t, e.GetModifiedBy(), ctx.GetID())
+	assert.Equal(t, e.GetReason(), "test")
+	assert.Assert(t, time.Since(e.GetTimestamp()) < time.Minute)
+	obj := map[string]int{}
+	assert.NilError(t, nsStore.GetValue("object").ParseJSON(&obj))
+	assert.Equal(t, obj["a"], 1)
+	i, err := nsStore.GetValue("int").GetData().GetInt64(false)
+	assert.NilError(t, err)
+	assert.Equal(t, i, int64(42))
+	assert.Equal(t, nsStore.GetValue("bytes").GetData().ContentType, "image/png")
+
+	/* files copied into the directory are plain bytes without metadata */
+	assert.NilError(t, os.WriteFile(path.Join(dir, "external"), []byte("ext"), 0644))
+	e = nsStore.GetValue("external")
+	assert.Assert(t, e.GetData().IsByte())
+	assert.Equal(t, e.GetModifiedBy(), "")
+	assert.Equal(t, nsStore.Len(), 5)
+	assert.Equal(t, len(nsStore.GetAllValues(0)), 5)
+	assert.Equal(t, len(nsStore.GetAllValues(2)), 2)
+
+	res := nsStore.GetSearchResultWithMetadata("", "val", "", 0, math.MaxInt64)
+	assert.Equal(t, len(res), 1)
+	assert.Equal(t, res["plain"].GetData().GetString(), "value")
+	res = nsStore.GetSearchResultWithMetadata("", "", ctx.GetID(), 0, math.MaxInt64)
+	assert.Equal(t, len(res), 4)
+
+	/* compare and swap and transactions */
+	assert.Equal(t, nsStore.CompareAndSwap("plain", "other", base.MakePlainOutput("new"), ctx).GetData().HTTPCode, http.StatusConflict)
+	assert.Assert(t, !nsStore.CompareAndSwap("plain", "value", base.MakePlainOutput("new"), ctx).IsError())
+	assert.Equal(t, nsStore.GetValue("plain").GetData().GetString(), "new")
+	assert.Assert(t, nsStore.OverwriteValueIfOlder("plain", base.MakePlainOutput("newer"), time.Hour, ctx).IsError())
+
+	var wg sync.WaitGroup
+	for n := 0; n < 10; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nsStore.UpdateTransaction("counter", func(old StoreEntry) *base.OperatorIO {
+				if old == NotFoundEntry {
+					return base.MakeIntegerOutput(1)
+				}
+				v, _ := old.GetData().GetInt64(false)
+				return base.MakeIntegerOutput(v + 1)
+			}, ctx)
+		}()
+	}
+	wg.Wait()
+	i, _ = nsStore.GetValue("counter").GetData().GetInt64(false)
+	assert.Equal(t, i, int64(10))
+
+	/* age is the modification time of the file */
+	old := time.Now().Add(-2 * time.Hour)
+	assert.NilError(t, os.Chtimes(path.Join(dir, "external"), old, old))
+	assert.Equal(t, nsStore.GetValueBeforeExpiration("external", time.Hour).GetData().HTTPCode, http.StatusGone)
+	assert.Assert(t, !nsStore.OverwriteValueIfOlder("external", base.MakePlainOutput("replaced"), time.Hour, ctx).IsError())
+	assert.NilError(t, os.Chtimes(path.Join(dir, "bytes"), old, old))
+	assert.Equal(t, nsStore.DeleteOlder(time.Hour), 1)
+	assert.Equal(t, nsStore.GetValue("bytes"), NotFoundEntry)
+	_, err = os.Stat(path.Join(dir, ".bytes.meta"))
+	assert.Assert(t, os.IsNotExist(err))
+
+	assert.NilError(t, os.Chtimes(path.Join(dir, "int"), old, old))
+	assert.Equal(t, nsStore.Trim(4), 1)
+	assert.Equal(t, nsStore.GetValue("int"), NotFoundEntry)
+	assert.Equal(t, nsStore.Len(), 4)
+
+	nsStore.DeleteValue("plain")
+	assert.Equal(t, nsStore.GetValue("plain"), NotFoundEntry)
+	assert.Equal(t, nsStore.Len(), 3)
+
+	/* skipped outputs keep their reason */
+	nsStore.SetValue("skipped", base.MakeSkippedOutput("condition %v", "false"), ctx)
+	skipped := nsStore.GetValue("skipped").GetData()
+	assert.Assert(t, skipped.IsSkipped())
+	assert.Equal(t, skipped.GetSkipReason(), "condition false")
+}
@@ -12,7 +12,7 @@ import (
 
 // GetFlowStore returns the flow store
 func GetFlowStore() StoreNamespace {
-	return store.GetNamespaceNoError("_flows")
+	return store.GetNamespaceNoError(flowNamespace)
 }
 
 // GetFlow returns a flow from the store
@@ -30,13 +30,22 @@ func GetFlow(name string) (freepsflow.FlowDesc, error) {
 	return gd, err
 }
 
-// StoreFlow stores a flow in the store
-func StoreFlow(name string, gd freepsflow.FlowDesc, modifiedBy *base.Context) *base.OperatorIO {
+func setFlow(nsStore StoreNamespace, name string, gd freepsflow.FlowDesc, modifiedBy *base.Context) *base.OperatorIO {
 	b, err := json.MarshalIndent(gd, "", "  ")
 	if err != nil {
 		return base.MakeOutputError(500, "Failed to marshal flow: %v", err.Error())
 	}
-	return GetFlowStore().SetValue(name, base.MakeByteOutput(b), modifiedBy).GetData()
+	return nsStore.SetValue(name, base.MakeByteOutput(b), modifiedBy).GetData()
+}
+
+// StoreFlow stores a flow in the store
+func StoreFlow(name string, gd freepsflow.FlowDesc, modifiedBy *base.Context) *base.OperatorIO {
+	return setFlow(GetFlowStore(), name, gd, modifiedBy)
+}
+
+// storeFlowVersion records a flow as it is used by the engine, the history of the namespace keeps the previous versions
+func storeFlowVersion(name string, gd freepsflow.FlowDesc, modifiedBy *base.Context) *base.OperatorIO {
+	return setFlow(store.GetNamespaceNoError(flowVersionsNamespace), name, gd, modifiedBy)
 }
 
 // DeleteFlow deletes a flow from the store
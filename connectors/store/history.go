@@ -0,0 +1,320 @@
+package freepsstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+	"github.com/jeremywohl/flatten"
+)
+
+// keyVersion is a single version in the history of a key
+type keyVersion struct {
+	version int
+	entry   StoreEntry
+}
+
+// ReadableKeyVersion is a version in the history of a key with a readable timestamp
+type ReadableKeyVersion struct {
+	ReadableStoreEntry
+	Version   int
+	Timestamp time.Time
+}
+
+// historyStoreNamespace keeps the last versions of every key that has been modified in the wrapped namespace,
+// the history is only kept in memory
+type historyStoreNamespace struct {
+	StoreNamespace
+	size     int
+	versions map[string][]keyVersion
+	lock     sync.Mutex
+}
+
+var _ StoreNamespace = &historyStoreNamespace{}
+
+func newHistoryStoreNamespace(nsStore StoreNamespace, size int) *historyStoreNamespace {
+	return &historyStoreNamespace{StoreNamespace: nsStore, size: size, versions: map[string][]keyVersion{}}
+}
+
+// previous returns the current value of the key if the key has no history yet, so that the value before the first modification can be recorded
+func (h *historyStoreNamespace) previous(key string) StoreEntry {
+	h.lock.Lock()
+	_, known := h.versions[strings.ToLower(key)]
+	h.lock.Unlock()
+	if known {
+		return NotFoundEntry
+	}
+	return h.StoreNamespace.GetValue(key)
+}
+
+// record adds the entry as the latest version of the key
+func (h *historyStoreNamespace) record(key string, e StoreEntry, previous StoreEntry) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	lk := strings.ToLower(key)
+	versions := h.versions[lk]
+	if len(versions) == 0 && previous != NotFoundEntry && !previous.IsError() {
+		versions = append(versions, keyVersion{version: 1, entry: previous})
+	}
+	next := 1
+	if len(versions) > 0 {
+		next = versions[len(versions)-1].version + 1
+	}
+	versions = append(versions, keyVersion{version: next, entry: e})
+	if len(versions) > h.size {
+		versions = versions[len(versions)-h.size:]
+	}
+	h.versions[lk] = versions
+}
+
+// getHistory returns all known versions of the key, the newest first
+func (h *historyStoreNamespace) getHistory(key string) []ReadableKeyVersion {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	versions := h.versions[strings.ToLower(key)]
+	res := make([]ReadableKeyVersion, 0, len(versions))
+	for i := len(versions) - 1; i >= 0; i-- {
+		v := versions[i]
+		res = append(res, ReadableKeyVersion{ReadableStoreEntry: v.entry.GetHumanReadable(), Version: v.version, Timestamp: v.entry.timestamp})
+	}
+	return res
+}
+
+// getVersion returns a single version of the key
+func (h *historyStoreNamespace) getVersion(key string, version int) (StoreEntry, bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for _, v := range h.versions[strings.ToLower(key)] {
+		if v.version == version {
+			return v.entry, true
+		}
+	}
+	return NotFoundEntry, false
+}
+
+// SetValue in the wrapped StoreNamespace and record the new version
+func (h *historyStoreNamespace) SetValue(key string, io *base.OperatorIO, modifiedBy *base.Context) StoreEntry {
+	old := h.previous(key)
+	e := h.StoreNamespace.SetValue(key, io, modifiedBy)
+	if e.data == io {
+		h.record(key, e, old)
+	}
+	return e
+}
+
+// CompareAndSwap in the wrapped StoreNamespace and record the new version if the value was swapped
+func (h *historyStoreNamespace) CompareAndSwap(key string, expected string, newValue *base.OperatorIO, modifiedBy *base.Context) StoreEntry {
+	old := h.previous(key)
+	e := h.StoreNamespace.CompareAndSwap(key, expected, newValue, modifiedBy)
+	if e.data == newValue {
+		h.record(key, e, old)
+	}
+	return e
+}
+
+// UpdateTransaction in the wrapped StoreNamespace and record the new version if the value was updated
+func (h *historyStoreNamespace) UpdateTransaction(key string, fn func(StoreEntry) *base.OperatorIO, modifiedBy *base.Context) StoreEntry {
+	var old StoreEntry
+	var newValue *base.OperatorIO
+	e := h.StoreNamespace.UpdateTransaction(key, func(o StoreEntry) *base.OperatorIO {
+		old = o
+		newValue = fn(o)
+		return newValue
+	}, modifiedBy)
+	if newValue != nil && e.data == newValue && !newValue.IsError() && newValue.HTTPCode != http.StatusContinue {
+		h.record(key, e, old)
+	}
+	return e
+}
+
+// OverwriteValueIfOlder in the wrapped StoreNamespace and record the new version if the value was overwritten
+func (h *historyStoreNamespace) OverwriteValueIfOlder(key string, io *base.OperatorIO, maxAge time.Duration, modifiedBy *base.Context) StoreEntry {
+	old := h.previous(key)
+	e := h.StoreNamespace.OverwriteValueIfOlder(key, io, maxAge, modifiedBy)
+	if e.data == io {
+		h.record(key, e, old)
+	}
+	return e
+}
+
+// SetAll in the wrapped StoreNamespace and record the new versions
+func (h *historyStoreNamespace) SetAll(valueMap map[string]interface{}, modifiedBy *base.Context) *base.OperatorIO {
+	out := h.StoreNamespace.SetAll(valueMap, modifiedBy)
+	if out.IsError() {
+		return out
+	}
+	for k := range valueMap {
+		h.record(k, h.StoreNamespace.GetValue(k), NotFoundEntry)
+	}
+	return out
+}
+
+// jsonDiff describes the differences between two values, nested objects are flattened with dots
+type jsonDiff struct {
+	Added   map[string]interface{}
+	Removed map[string]interface{}
+	Changed map[string][2]interface{} // old and new value
+}
+
+// flattenValue returns the flattened JSON object of a value or the value itself under an empty key if it is no object
+func flattenValue(io *base.OperatorIO) map[string]interface{} {
+	var v interface{}
+	b, err := io.GetBytes()
+	if err == nil && json.Unmarshal(b, &v) == nil {
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			if f, err := flatten.Flatten(map[string]interface{}{"": v}, "", flatten.DotStyle); err == nil {
+				res := map[string]interface{}{}
+				for k, fv := range f {
+					res[strings.TrimPrefix(k, ".")] = fv
+				}
+				return res
+			}
+		}
+		return map[string]interface{}{"": v}
+	}
+	return map[string]interface{}{"": io.GetString()}
+}
+
+func diffValues(from *base.OperatorIO, to *base.OperatorIO) jsonDiff {
+	d := jsonDiff{Added: map[string]interface{}{}, Removed: map[string]interface{}{}, Changed: map[string][2]interface{}{}}
+	f := flattenValue(from)
+	t := flattenValue(to)
+	for k, fv := range f {
+		tv, exists := t[k]
+		if !exists {
+			d.Removed[k] = fv
+		} else if !reflect.DeepEqual(fv, tv) {
+			d.Changed[k] = [2]interface{}{fv, tv}
+		}
+	}
+	for k, tv := range t {
+		if _, exists := f[k]; !exists {
+			d.Added[k] = tv
+		}
+	}
+	return d
+}
+
+// getHistoryNamespace returns the history of a namespace that has been configured with a HistorySize
+func (s *Store) getHistoryNamespace(ns string) (*historyStoreNamespace, error) {
+	_, err := s.GetNamespace(ns)
+	if err != nil {
+		return nil, err
+	}
+	s.globalLock.Lock()
+	defer s.globalLock.Unlock()
+	h, ok := s.histories[ns]
+	if !ok {
+		return nil, fmt.Errorf("Namespace \"%v\" does not keep a history", ns)
+	}
+	return h, nil
+}
+
+// GetHistory returns all known versions of the key, the newest first
+func (s *Store) GetHistory(ns string, key string) ([]ReadableKeyVersion, error) {
+	h, err := s.getHistoryNamespace(ns)
+	if err != nil {
+		return nil, err
+	}
+	return h.getHistory(key), nil
+}
+
+// HistoryArgs are the arguments for the GetHistory function
+type HistoryArgs struct {
+	Namespace string
+	Key       string
+}
+
+// NamespaceSuggestions returns all namespaces
+func (p *HistoryArgs) NamespaceSuggestions() []string {
+	return store.GetNamespaces()
+}
+
+// KeySuggestions returns the keys of the namespace
+func (p *HistoryArgs) KeySuggestions() []string {
+	nsStore, _ := store.GetNamespace(p.Namespace)
+	if nsStore == nil {
+		return []string{}
+	}
+	return nsStore.GetKeys()
+}
+
+// GetHistory returns the last versions of a key in a namespace with a HistorySize, the newest first
+func (o *OpStore) GetHistory(ctx *base.Context, input *base.OperatorIO, args HistoryArgs) *base.OperatorIO {
+	history, err := store.GetHistory(args.Namespace, args.Key)
+	if err != nil {
+		return base.MakeOutputError(http.StatusBadRequest, "%v", err)
+	}
+	return base.MakeObjectOutput(history)
+}
+
+// DiffArgs are the arguments for the Diff function
+type DiffArgs struct {
+	Namespace string
+	Key       string
+	From      *int // version to compare, the version before To if not set
+	To        *int // version to compare with, the latest version if not set
+}
+
+// Diff returns the added, removed and changed fields between two versions of a key, nested objects are flattened
+func (o *OpStore) Diff(ctx *base.Context, input *base.OperatorIO, args DiffArgs) *base.OperatorIO {
+	h, err := store.getHistoryNamespace(args.Namespace)
+	if err != nil {
+		return base.MakeOutputError(http.StatusBadRequest, "%v", err)
+	}
+	history := h.getHistory(args.Key)
+	if len(history) == 0 {
+		return base.MakeOutputError(http.StatusNotFound, "No history for key \"%v\"", args.Key)
+	}
+	to := history[0].Version
+	if args.To != nil {
+		to = *args.To
+	}
+	from := to - 1
+	if args.From != nil {
+		from = *args.From
+	}
+	fromEntry, ok := h.getVersion(args.Key, from)
+	if !ok {
+		return base.MakeOutputError(http.StatusNotFound, "Version %v of key \"%v\" is not in the history", from, args.Key)
+	}
+	toEntry, ok := h.getVersion(args.Key, to)
+	if !ok {
+		return base.MakeOutputError(http.StatusNotFound, "Version %v of key \"%v\" is not in the history", to, args.Key)
+	}
+	return base.MakeObjectOutput(diffValues(fromEntry.data, toEntry.data))
+}
+
+// RevertArgs are the arguments for the Revert function
+type RevertArgs struct {
+	Namespace string
+	Key       string
+	Version   int
+}
+
+// Revert sets the value of the key to the value of the given version, this adds a new version to the history
+func (o *OpStore) Revert(ctx *base.Context, input *base.OperatorIO, args RevertArgs) *base.OperatorIO {
+	h, err := store.getHistoryNamespace(args.Namespace)
+	if err != nil {
+		return base.MakeOutputError(http.StatusBadRequest, "%v", err)
+	}
+	e, ok := h.getVersion(args.Key, args.Version)
+	if !ok {
+		return base.MakeOutputError(http.StatusNotFound, "Version %v of key \"%v\" is not in the history", args.Version, args.Key)
+	}
+	nsStore, err := store.GetNamespace(args.Namespace)
+	if err != nil {
+		return base.MakeInternalServerErrorOutput(err)
+	}
+	out := nsStore.SetValue(args.Key, e.data, ctx).GetData()
+	if out.IsError() {
+		return out
+	}
+	return base.MakeEmptyOutput()
+}
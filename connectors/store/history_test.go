@@ -0,0 +1,64 @@
+package freepsstore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hannesrauhe/freeps/base"
+	"gotest.tools/v3/assert"
+)
+
+func TestKeyHistory(t *testing.T) {
+	s, ctx := prepareStore(t)
+
+	ns, err := store.CreateNamespace("versioned", StoreNamespaceConfig{NamespaceType: "memory", HistorySize: 3})
+	assert.NilError(t, err)
+	ns.SetValue("config", base.MakeObjectOutput(map[string]interface{}{"a": 1, "b": map[string]interface{}{"c": "x"}}), ctx)
+	ns.SetValue("config", base.MakeObjectOutput(map[string]interface{}{"a": 1, "b": map[string]interface{}{"c": "y"}, "d": true}), ctx)
+	ns.SetValue("config", base.MakeObjectOutput(map[string]interface{}{"a": 2, "b": map[string]interface{}{"c": "y"}}), ctx)
+
+	history, err := store.GetHistory("versioned", "config")
+	assert.NilError(t, err)
+	assert.Equal(t, len(history), 3)
+	assert.Equal(t, history[0].Version, 3)
+	assert.Equal(t, history[2].Version, 1)
+
+	/* the oldest version is dropped */
+	ns.SetValue("config", base.MakeObjectOutput(map[string]interface{}{"a": 3}), ctx)
+	history, err = store.GetHistory("versioned", "config")
+	assert.NilError(t, err)
+	assert.Equal(t, len(history), 3)
+	assert.Equal(t, history[0].Version, 4)
+	assert.Equal(t, history[2].Version, 2)
+
+	out := s.Execute(ctx, "diff", base.NewFunctionArguments(map[string]string{"namespace": "versioned", "key": "config", "from": "2", "to": "3"}), base.MakeEmptyOutput())
+	assert.Assert(t, !out.IsError(), out.GetString())
+	d := jsonDiff{}
+	assert.NilError(t, out.ParseJSON(&d))
+	assert.Equal(t, len(d.Added), 0)
+	assert.Equal(t, d.Removed["d"], true)
+	assert.Equal(t, fmt.Sprint(d.Changed["a"]), "[1 2]")
+	_, changed := d.Changed["b.c"]
+	assert.Assert(t, !changed)
+
+	out = s.Execute(ctx, "diff", base.NewFunctionArguments(map[string]string{"namespace": "versioned", "key": "config", "from": "1"}), base.MakeEmptyOutput())
+	assert.Equal(t, out.HTTPCode, 404)
+
+	out = s.Execute(ctx, "revert", base.NewFunctionArguments(map[string]string{"namespace": "versioned", "key": "config", "version": "2"}), base.MakeEmptyOutput())
+	assert.Assert(t, !out.IsError(), out.GetString())
+	v := map[string]interface{}{}
+	assert.NilError(t, ns.GetValue("config").ParseJSON(&v))
+	assert.Equal(t, v["d"], true)
+	history, _ = store.GetHistory("versioned", "config")
+	assert.Equal(t, history[0].Version, 5)
+
+	/* the value before the namespace was modified for the first time is part of the history */
+	ns.SetValue("other", base.MakePlainOutput("1"), ctx)
+	out = s.Execute(ctx, "getHistory", base.NewFunctionArguments(map[string]string{"namespace": "versioned", "key": "other"}), base.MakeEmptyOutput())
+	assert.Assert(t, !out.IsError(), out.GetString())
+
+	_, err = store.GetHistory("_debug", "config")
+	assert.ErrorContains(t, err, "does not keep a history")
+	out = s.Execute(ctx, "getHistory", base.NewFunctionArguments(map[string]string{"namespace": "_debug", "key": "config"}), base.MakeEmptyOutput())
+	assert.Assert(t, out.IsError())
+}
@@ -2,7 +2,6 @@ package freepsstore
 
 import (
 	"fmt"
-	"time"
 
 	"github.com/hannesrauhe/freeps/base"
 	"github.com/hannesrauhe/freeps/freepsflow"
@@ -11,11 +10,13 @@ import (
 type HookStore struct {
 	executionLogNs StoreNamespace
 	debugNs        StoreNamespace
+	jobsNs         StoreNamespace
 	GE             *freepsflow.FlowEngine
 }
 
 var _ freepsflow.FreepsExecutionHook = &HookStore{}
 var _ freepsflow.FreepsFlowChangedHook = &HookStore{}
+var _ freepsflow.FreepsJobHook = &HookStore{}
 
 // FlowInfo keeps information about a flow execution
 type FlowInfo struct {
@@ -69,6 +70,7 @@ func (h *HookStore) OnExecute(ctx *base.Context, flowName string, mainArgs map[s
 
 // OnFlowChanged analyzes all flows and updates the operator info
 func (h *HookStore) OnFlowChanged(ctx *base.Context, addedFlows []string, removedFlows []string) error {
+	store.invalidateTriggers()
 	if h.debugNs == nil {
 		return fmt.Errorf("missing debug namespace")
 	}
@@ -103,7 +105,7 @@ func (h *HookStore) OnFlowChanged(ctx *base.Context, addedFlows []string, remove
 	for _, flowId := range addedFlows {
 		gd, found := h.GE.GetFlowDesc(flowId)
 		if found {
-			StoreFlow(fmt.Sprintf("%s.%d", flowId, time.Now().Unix()), *gd, ctx)
+			storeFlowVersion(flowId, *gd, ctx)
 		}
 	}
 
@@ -154,3 +156,15 @@ func (h *HookStore) OnExecutionFinished(ctx *base.Context, flowName string, main
 
 	return nil
 }
+
+// OnJobChanged keeps the status of asynchronous flow executions in the jobs namespace
+func (h *HookStore) OnJobChanged(ctx *base.Context, status freepsflow.JobStatus) error {
+	if h.jobsNs == nil {
+		return fmt.Errorf("jobs namespace missing")
+	}
+	out := h.jobsNs.SetValue(status.ID, base.MakeObjectOutput(status), ctx)
+	if out.IsError() {
+		return out.GetError()
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package freepsstore_test
+
+import (
+	"testing"
+
+	"github.com/hannesrauhe/freeps/base"
+	freepsstore "github.com/hannesrauhe/freeps/connectors/store"
+	"github.com/hannesrauhe/freeps/freepsd/helper"
+	"github.com/hannesrauhe/freeps/freepsflow"
+	"gotest.tools/v3/assert"
+)
+
+func TestFlowVersions(t *testing.T) {
+	ctx, ge, _ := helper.SetupEngineWithCommonOperators(t, nil)
+	s := freepsstore.GetGlobalStore()
+
+	/* a draft of the flowbuilder is not touched by the engine */
+	draft := freepsflow.FlowDesc{Operations: []freepsflow.FlowOperationDesc{{Operator: "utils", Function: "echo"}}, Source: "draft"}
+	assert.Assert(t, !freepsstore.StoreFlow("versioned", draft, ctx).IsError())
+
+	for _, fn := range []string{"echo", "echoArguments"} {
+		gd := freepsflow.FlowDesc{Operations: []freepsflow.FlowOperationDesc{{Operator: "utils", Function: fn}}, Source: "test"}
+		assert.NilError(t, ge.AddFlow(ctx, "versioned", gd, true))
+	}
+
+	stored, err := freepsstore.GetFlow("versioned")
+	assert.NilError(t, err)
+	assert.Equal(t, stored.Source, "draft")
+
+	history, err := s.GetHistory("_flow_versions", "versioned")
+	assert.NilError(t, err)
+	assert.Equal(t, len(history), 2)
+	gd := freepsflow.FlowDesc{}
+	assert.NilError(t, s.GetNamespaceNoError("_flow_versions").GetValue("versioned").ParseJSON(&gd))
+	assert.Equal(t, gd.Operations[0].Function, "echoArguments")
+	_, err = s.GetHistory("_flows", "versioned")
+	assert.ErrorContains(t, err, "does not keep a history")
+
+	out := ge.ExecuteOperatorByName(ctx, "store", "getHistory", base.NewFunctionArguments(map[string]string{"namespace": "_flow_versions", "key": "versioned"}), base.MakeEmptyOutput())
+	assert.Assert(t, !out.IsError(), out.GetString())
+}
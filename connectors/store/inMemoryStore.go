@@ -36,11 +36,14 @@ func (s *inMemoryStoreNamespace) getValueUnlocked(key string) StoreEntry {
 }
 
 func (s *inMemoryStoreNamespace) setValueUnlocked(key string, newValue *base.OperatorIO, modifiedBy *base.Context) StoreEntry {
+	return s.restoreEntryUnlocked(key, StoreEntry{newValue, time.Now(), modifiedBy})
+}
+
+func (s *inMemoryStoreNamespace) restoreEntryUnlocked(key string, e StoreEntry) StoreEntry {
 	lowerCaseKey := strings.ToLower(key)
-	x := StoreEntry{newValue, time.Now(), modifiedBy}
-	s.entries[lowerCaseKey] = x
+	s.entries[lowerCaseKey] = e
 	s.originalCaseKeys[lowerCaseKey] = key
-	return x
+	return e
 }
 
 func (s *inMemoryStoreNamespace) deleteValueUnlocked(key string) {
@@ -77,6 +80,13 @@ func (s *inMemoryStoreNamespace) SetValue(key string, io *base.OperatorIO, modif
 	return s.setValueUnlocked(key, io, modifiedBy)
 }
 
+// restoreEntry sets the entry including its timestamp
+func (s *inMemoryStoreNamespace) restoreEntry(key string, e StoreEntry) StoreEntry {
+	s.nsLock.Lock()
+	defer s.nsLock.Unlock()
+	return s.restoreEntryUnlocked(key, e)
+}
+
 // SetAll sets all values in the StoreNamespace
 func (s *inMemoryStoreNamespace) SetAll(valueMap map[string]interface{}, modifiedBy *base.Context) *base.OperatorIO {
 	s.nsLock.Lock()
@@ -185,7 +195,7 @@ func matches(lk string, v StoreEntry, keyPattern, valuePattern, modifiedByPatter
 	if valuePattern != "" && !strings.Contains(v.data.GetString(), valuePattern) {
 		return false
 	}
-	if modifiedByPattern != "" && !strings.Contains(v.modifiedBy.GetID(), modifiedByPattern) {
+	if modifiedByPattern != "" && !strings.Contains(v.GetModifiedBy(), modifiedByPattern) {
 		return false
 	}
 	return true
@@ -251,3 +261,29 @@ func (s *inMemoryStoreNamespace) Trim(k int) int {
 	}
 	return len(deleteKeys)
 }
+
+// snapshot returns all entries of the StoreNamespace
+func (s *inMemoryStoreNamespace) snapshot() (storeSnapshot, error) {
+	s.nsLock.Lock()
+	defer s.nsLock.Unlock()
+	snap := storeSnapshot{Entries: make([]persistedRecord, 0, len(s.entries))}
+	for lk, e := range s.entries {
+		r, err := entryToRecord(s.originalCaseKeys[lk], e)
+		if err != nil {
+			return snap, err
+		}
+		snap.Entries = append(snap.Entries, r)
+	}
+	return snap, nil
+}
+
+// restore adds all entries of the snapshot to the StoreNamespace
+func (s *inMemoryStoreNamespace) restore(snap storeSnapshot) {
+	s.nsLock.Lock()
+	defer s.nsLock.Unlock()
+	for _, r := range snap.Entries {
+		lowerCaseKey := strings.ToLower(r.Key)
+		s.entries[lowerCaseKey] = r.toEntry()
+		s.originalCaseKeys[lowerCaseKey] = r.Key
+	}
+}
@@ -0,0 +1,151 @@
+package freepsstore
+
+import (
+	"sort"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+)
+
+// evictionInterval is the time between two runs of the janitor that enforces the eviction policies of all namespaces
+const evictionInterval = 10 * time.Second
+
+// EvictionStatistics counts the entries of a namespace that have been removed by the janitor
+type EvictionStatistics struct {
+	Expired  int // older than the TTL
+	Trimmed  int // more than MaxEntries
+	Oversize int // more than MaxBytes
+}
+
+// NamespaceInfo describes a namespace and its eviction policies
+type NamespaceInfo struct {
+	NamespaceType string
+	Entries       int           // -1 if the namespace has not been used yet
+	TTL           time.Duration `json:",omitempty"`
+	MaxEntries    int           `json:",omitempty"`
+	MaxBytes      int           `json:",omitempty"`
+	Evictions     EvictionStatistics
+}
+
+func hasEvictionPolicy(config StoreNamespaceConfig) bool {
+	return config.TTL > 0 || config.MaxEntries > 0 || config.MaxBytes > 0
+}
+
+// sizeTrimmingNamespace is implemented by namespaces that can remove the oldest entries beyond a size limit without reading all values
+type sizeTrimmingNamespace interface {
+	trimToSize(maxBytes int) int
+}
+
+// trimToSize removes the oldest entries until the size of all values is below maxBytes
+func trimToSize(nsStore StoreNamespace, maxBytes int) int {
+	if sns, ok := unwrapNamespace(nsStore).(sizeTrimmingNamespace); ok {
+		return sns.trimToSize(maxBytes)
+	}
+	// search results might be limited, so every key is read on its own
+	entries := []StoreEntry{}
+	for _, k := range nsStore.GetKeys() {
+		e := nsStore.GetValue(k)
+		if e == NotFoundEntry || e.IsError() {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].timestamp.After(entries[j].timestamp) })
+	total := 0
+	for keep, e := range entries {
+		size, err := e.GetData().GetSize()
+		if err != nil {
+			size = len(e.GetData().GetString())
+		}
+		total += size
+		if total > maxBytes {
+			return nsStore.Trim(keep)
+		}
+	}
+	return 0
+}
+
+// evict enforces the eviction policies of a single namespace
+func evict(nsStore StoreNamespace, config StoreNamespaceConfig, stats *EvictionStatistics) {
+	if config.TTL > 0 {
+		stats.Expired += nsStore.DeleteOlder(config.TTL)
+	}
+	if config.MaxEntries > 0 && nsStore.Len() > config.MaxEntries {
+		stats.Trimmed += nsStore.Trim(config.MaxEntries)
+	}
+	if config.MaxBytes > 0 {
+		stats.Oversize += trimToSize(nsStore, config.MaxBytes)
+	}
+}
+
+// enforceEvictionPolicies runs the janitor for all namespaces that have an eviction policy
+func (s *Store) enforceEvictionPolicies() {
+	s.globalLock.Lock()
+	due := map[string]StoreNamespaceConfig{}
+	for ns, config := range s.namespaceConfigs {
+		if hasEvictionPolicy(config) {
+			due[ns] = config
+		}
+	}
+	nsStores := make(map[string]StoreNamespace, len(due))
+	for ns := range due {
+		nsStores[ns] = s.namespaces[ns]
+	}
+	s.globalLock.Unlock()
+
+	for ns, config := range due {
+		stats := EvictionStatistics{}
+		evict(nsStores[ns], config, &stats)
+		s.globalLock.Lock()
+		total := s.evictions[ns]
+		total.Expired += stats.Expired
+		total.Trimmed += stats.Trimmed
+		total.Oversize += stats.Oversize
+		s.evictions[ns] = total
+		s.globalLock.Unlock()
+	}
+}
+
+// GetNamespaceInfos returns the type, size, eviction policies and statistics of all namespaces
+func (s *Store) GetNamespaceInfos() map[string]NamespaceInfo {
+	infos := map[string]NamespaceInfo{}
+	for _, ns := range s.GetNamespaces() {
+		s.globalLock.Lock()
+		nsStore, exists := s.namespaces[ns]
+		config, hasConfig := s.namespaceConfigs[ns]
+		if !hasConfig && s.config != nil {
+			config = s.config.Namespaces[ns]
+		}
+		stats := s.evictions[ns]
+		s.globalLock.Unlock()
+
+		info := NamespaceInfo{NamespaceType: config.NamespaceType, Entries: -1, TTL: config.TTL, MaxEntries: config.MaxEntries, MaxBytes: config.MaxBytes, Evictions: stats}
+		if info.NamespaceType == "" {
+			info.NamespaceType = "memory"
+		}
+		if exists {
+			info.Entries = nsStore.Len()
+		}
+		infos[ns] = info
+	}
+	return infos
+}
+
+// janitorLoop writes the snapshots and enforces the eviction policies until stop is closed
+func (s *Store) janitorLoop(ctx *base.Context, stop chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	lastEviction := time.Time{}
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.writeSnapshots(ctx, false)
+			if time.Since(lastEviction) >= evictionInterval {
+				s.enforceEvictionPolicies()
+				lastEviction = time.Now()
+			}
+		}
+	}
+}
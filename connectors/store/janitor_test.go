@@ -0,0 +1,78 @@
+package freepsstore
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+	"github.com/hannesrauhe/freeps/freepsflow"
+	"github.com/sirupsen/logrus"
+	"gotest.tools/v3/assert"
+)
+
+func TestEvictionPolicies(t *testing.T) {
+	s, ctx := prepareStore(t)
+
+	ttlNs, err := store.CreateNamespace("ttl", StoreNamespaceConfig{NamespaceType: "memory", TTL: 50 * time.Millisecond})
+	assert.NilError(t, err)
+	logNs, err := store.CreateNamespace("log", StoreNamespaceConfig{NamespaceType: "log", TTL: 50 * time.Millisecond})
+	assert.NilError(t, err)
+	countNs, err := store.CreateNamespace("count", StoreNamespaceConfig{NamespaceType: "memory", MaxEntries: 3})
+	assert.NilError(t, err)
+	sizeNs, err := store.CreateNamespace("size", StoreNamespaceConfig{NamespaceType: "memory", MaxBytes: 10})
+	assert.NilError(t, err)
+
+	ttlNs.SetValue("old", base.MakePlainOutput("a"), ctx)
+	logNs.SetValue("", base.MakePlainOutput("a"), ctx)
+	time.Sleep(60 * time.Millisecond)
+	ttlNs.SetValue("new", base.MakePlainOutput("b"), ctx)
+	logNs.SetValue("", base.MakePlainOutput("b"), ctx)
+	for i := 0; i < 5; i++ {
+		countNs.SetValue(fmt.Sprint(i), base.MakeIntegerOutput(i), ctx)
+		sizeNs.SetValue(fmt.Sprint(i), base.MakePlainOutput("1234"), ctx)
+		time.Sleep(time.Millisecond)
+	}
+
+	store.enforceEvictionPolicies()
+	assert.Equal(t, ttlNs.GetValue("old"), NotFoundEntry)
+	assert.Equal(t, ttlNs.Len(), 1)
+	assert.Equal(t, logNs.Len(), 1)
+	assert.Equal(t, logNs.GetValue("1").GetData().GetString(), "b")
+	assert.Equal(t, countNs.Len(), 3)
+	assert.Equal(t, countNs.GetValue("0"), NotFoundEntry)
+	assert.Equal(t, countNs.GetValue("4").GetData().GetString(), "4")
+	assert.Equal(t, sizeNs.Len(), 2)
+	assert.Equal(t, sizeNs.GetValue("4").GetData().GetString(), "1234")
+
+	out := s.Execute(ctx, "getNamespaces", base.NewSingleFunctionArgument("details", "true"), base.MakeEmptyOutput())
+	assert.Assert(t, !out.IsError(), out.GetString())
+	infos := map[string]NamespaceInfo{}
+	assert.NilError(t, out.ParseJSON(&infos))
+	assert.Equal(t, infos["ttl"].Evictions.Expired, 1)
+	assert.Equal(t, infos["log"].Evictions.Expired, 1)
+	assert.Equal(t, infos["count"].Evictions.Trimmed, 2)
+	assert.Equal(t, infos["count"].MaxEntries, 3)
+	assert.Equal(t, infos["size"].Evictions.Oversize, 3)
+	assert.Equal(t, infos["size"].Entries, 2)
+	assert.Equal(t, infos["_execution_log"].NamespaceType, "log")
+	assert.Equal(t, infos["_jobs"].TTL, freepsflow.JobRetention)
+
+	out = s.Execute(ctx, "getNamespaces", base.MakeEmptyFunctionArguments(), base.MakeEmptyOutput())
+	names := []string{}
+	assert.NilError(t, out.ParseJSON(&names))
+	assert.Assert(t, len(names) >= 4)
+}
+
+func TestTrimToSizeReadsAllEntries(t *testing.T) {
+	ctx := base.NewBaseContextWithReason(logrus.StandardLogger(), "test")
+	/* searching the log namespace returns at most 201 entries */
+	logNs := &logStoreNamespace{entries: []StoreEntry{}}
+	for i := 0; i < 300; i++ {
+		logNs.SetValue("", base.MakePlainOutput("1234"), ctx)
+	}
+	assert.Equal(t, trimToSize(logNs, 1000), 50)
+	assert.Equal(t, logNs.Len(), 250)
+	assert.Equal(t, logNs.GetValue("50").GetData().GetString(), "1234")
+	assert.Equal(t, logNs.GetValue("49"), NotFoundEntry)
+}
@@ -20,14 +20,17 @@ type logStoreNamespace struct {
 
 var _ StoreNamespace = &logStoreNamespace{}
 
+func (s *logStoreNamespace) appendUnlocked(x StoreEntry) StoreEntry {
+	s.entries = append(s.entries, x)
+	if s.AutoTrim > 0 && len(s.entries)%(s.AutoTrim/10) == 0 {
+		s.trimUnlocked(s.AutoTrim)
+	}
+	return x
+}
+
 func (s *logStoreNamespace) setValueUnlocked(keyStr string, newValue *base.OperatorIO, modifiedBy *base.Context) StoreEntry {
 	if keyStr == "" {
-		x := StoreEntry{newValue, time.Now(), modifiedBy}
-		s.entries = append(s.entries, x)
-		if s.AutoTrim > 0 && len(s.entries)%(s.AutoTrim/10) == 0 {
-			s.trimUnlocked(s.AutoTrim)
-		}
-		return x
+		return s.appendUnlocked(StoreEntry{newValue, time.Now(), modifiedBy})
 	}
 	keyNoOffset, err := strconv.Atoi(keyStr)
 	if err != nil {
@@ -93,6 +96,13 @@ func (s *logStoreNamespace) SetValue(key string, io *base.OperatorIO, modifiedBy
 	return s.setValueUnlocked(key, io, modifiedBy)
 }
 
+// restoreEntry appends the entry including its timestamp, the key is ignored because the log generates its own keys
+func (s *logStoreNamespace) restoreEntry(key string, e StoreEntry) StoreEntry {
+	s.nsLock.Lock()
+	defer s.nsLock.Unlock()
+	return s.appendUnlocked(e)
+}
+
 // SetAll sets all values in the StoreNamespace
 func (s *logStoreNamespace) SetAll(valueMap map[string]interface{}, modifiedBy *base.Context) *base.OperatorIO {
 	s.nsLock.Lock()
@@ -233,7 +243,7 @@ func (s *logStoreNamespace) DeleteOlder(maxAge time.Duration) int {
 	for k, md := range s.entries {
 		ts := md.timestamp
 		if ts.Add(maxAge).Before(tnow) {
-			timeCut = k
+			timeCut = k + 1
 		} else {
 			break
 		}
@@ -250,3 +260,29 @@ func (s *logStoreNamespace) Trim(k int) int {
 
 	return s.trimUnlocked(k)
 }
+
+// snapshot returns all entries of the StoreNamespace
+func (s *logStoreNamespace) snapshot() (storeSnapshot, error) {
+	s.nsLock.Lock()
+	defer s.nsLock.Unlock()
+	snap := storeSnapshot{Offset: s.offset, Entries: make([]persistedRecord, 0, len(s.entries))}
+	for k, e := range s.entries {
+		r, err := entryToRecord(s.getKeyStringUnlocked(k), e)
+		if err != nil {
+			return snap, err
+		}
+		snap.Entries = append(snap.Entries, r)
+	}
+	return snap, nil
+}
+
+// restore replaces all entries of the StoreNamespace with the entries of the snapshot
+func (s *logStoreNamespace) restore(snap storeSnapshot) {
+	s.nsLock.Lock()
+	defer s.nsLock.Unlock()
+	s.offset = snap.Offset
+	s.entries = make([]StoreEntry, 0, len(snap.Entries))
+	for _, r := range snap.Entries {
+		s.entries = append(s.entries, r.toEntry())
+	}
+}
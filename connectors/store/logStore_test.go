@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/hannesrauhe/freeps/base"
 	"github.com/sirupsen/logrus"
@@ -90,6 +91,28 @@ func TestLogExpiration(t *testing.T) {
 	assert.Equal(t, vm["101"].GetString(), "101")
 }
 
+func TestLogDeleteOlder(t *testing.T) {
+	nsStore := logStoreNamespace{entries: []StoreEntry{}, offset: 0, nsLock: sync.Mutex{}}
+	ctx := base.NewBaseContextWithReason(logrus.StandardLogger(), "test")
+	for i := 0; i < 4; i++ {
+		nsStore.SetValue("", base.MakePlainOutput(fmt.Sprintf("%d", i)), ctx)
+	}
+	for i := 0; i < 2; i++ {
+		nsStore.entries[i].timestamp = time.Now().Add(-time.Hour)
+	}
+
+	/* the last expired entry is deleted as well */
+	assert.Equal(t, nsStore.DeleteOlder(time.Minute), 2)
+	assert.Equal(t, nsStore.GetValue("1"), NotFoundEntry)
+	assert.Equal(t, nsStore.GetValue("2").GetData().GetString(), "2")
+	assert.Equal(t, nsStore.Len(), 2)
+	assert.Equal(t, nsStore.DeleteOlder(time.Minute), 0)
+
+	/* keys keep counting after the deletion */
+	nsStore.SetValue("", base.MakePlainOutput("4"), ctx)
+	assert.Equal(t, nsStore.GetValue("4").GetData().GetString(), "4")
+}
+
 func TestAutoTrim(t *testing.T) {
 	nsStore := logStoreNamespace{entries: []StoreEntry{}, offset: 0, nsLock: sync.Mutex{}, AutoTrim: 100}
 
@@ -3,6 +3,7 @@ package freepsstore
 import (
 	"math"
 	"net/http"
+	"path"
 	"time"
 
 	"github.com/hannesrauhe/freeps/base"
@@ -11,12 +12,14 @@ import (
 )
 
 type OpStore struct {
-	CR *utils.ConfigReader
-	GE *freepsflow.FlowEngine
+	CR          *utils.ConfigReader
+	GE          *freepsflow.FlowEngine
+	stopJanitor chan struct{}
 }
 
 var _ base.FreepsOperatorWithConfig = &OpStore{}
 var _ base.FreepsOperatorWithDynamicFunctions = &OpStore{}
+var _ base.FreepsOperatorWithShutdown = &OpStore{}
 
 // GetDefaultConfig returns the default config for the http connector
 func (o *OpStore) GetDefaultConfig() interface{} {
@@ -26,17 +29,51 @@ func (o *OpStore) GetDefaultConfig() interface{} {
 // InitCopyOfOperator creates a copy of the operator
 func (o *OpStore) InitCopyOfOperator(ctx *base.Context, config interface{}, name string) (base.FreepsOperatorWithConfig, error) {
 	store.namespaces = map[string]StoreNamespace{}
+	store.persisted = map[string]*persistedNamespace{}
+	store.namespaceConfigs = map[string]StoreNamespaceConfig{}
+	store.evictions = map[string]EvictionStatistics{}
+	store.histories = map[string]*historyStoreNamespace{}
+	store.ge = o.GE
+	store.invalidateTriggers()
 	store.config = config.(*StoreConfig)
+	store.dataDir = ""
+	if o.CR != nil {
+		store.dataDir = path.Join(o.CR.GetConfigDir(), "store")
+	}
 	if store.config.PostgresConnStr != "" {
 		err := store.initPostgres()
 		if err != nil {
 			ctx.GetLogger().Fatal(err)
 		}
 	}
+	// restore persisted namespaces right away, so that their content is available before anybody writes to them
+	for ns, nsConfig := range store.config.Namespaces {
+		if nsConfig.PersistInterval > 0 {
+			_, err := store.CreateNamespace(ns, nsConfig)
+			if err != nil {
+				ctx.GetLogger().Errorf("%v", err)
+			}
+		}
+	}
 
 	return &OpStore{CR: o.CR, GE: o.GE}, nil
 }
 
+// StartListening starts the janitor that writes the snapshots and enforces the eviction policies of the namespaces
+func (o *OpStore) StartListening(ctx *base.Context) {
+	o.stopJanitor = make(chan struct{})
+	go store.janitorLoop(ctx, o.stopJanitor)
+}
+
+// Shutdown writes the snapshots of all persisted namespaces
+func (o *OpStore) Shutdown(ctx *base.Context) {
+	if o.stopJanitor != nil {
+		close(o.stopJanitor)
+		o.stopJanitor = nil
+	}
+	store.writeSnapshots(ctx, true)
+}
+
 // ExecuteDynamic is a single spaghetti - needs cleanup ... moving to opStoreV2.go
 func (o *OpStore) ExecuteDynamic(ctx *base.Context, fn string, fa base.FunctionArguments, input *base.OperatorIO) *base.OperatorIO {
 	result := map[string]map[string]*base.OperatorIO{}
@@ -78,7 +78,16 @@ func (o *OpStore) OutputSuggestions() []string {
 	return []string{"full", "arguments", "flat", "direct", "bool", "empty", "hierarchy"}
 }
 
-func (o *OpStore) GetNamespaces(ctx *base.Context) *base.OperatorIO {
+// GetNamespacesArgs are the arguments for the GetNamespaces function
+type GetNamespacesArgs struct {
+	Details *bool // return the type, the number of entries, the eviction policies and eviction counts of each namespace
+}
+
+// GetNamespaces returns the names of all namespaces
+func (o *OpStore) GetNamespaces(ctx *base.Context, input *base.OperatorIO, args GetNamespacesArgs) *base.OperatorIO {
+	if args.Details != nil && *args.Details {
+		return base.MakeObjectOutput(store.GetNamespaceInfos())
+	}
 	return base.MakeObjectOutput(store.GetNamespaces())
 }
 
@@ -255,7 +264,7 @@ func (o *OpStore) Delete(ctx *base.Context, input *base.OperatorIO, args StoreGe
 	if err != nil {
 		return base.MakeInternalServerErrorOutput(err)
 	}
-	nsStore.DeleteValue(key)
+	DeleteValueWithContext(nsStore, key, ctx)
 	return base.MakeEmptyOutput()
 }
 
@@ -404,5 +413,10 @@ func (o *OpStore) GetHook() interface{} {
 		// set alert
 	}
 
-	return &HookStore{executionLogNs: eLog, debugNs: debugNs, GE: o.GE}
+	jobsNs, err := store.GetNamespace(jobsNamespace)
+	if err != nil {
+		// set alert
+	}
+
+	return &HookStore{executionLogNs: eLog, debugNs: debugNs, jobsNs: jobsNs, GE: o.GE}
 }
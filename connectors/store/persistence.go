@@ -0,0 +1,137 @@
+package freepsstore
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+	"github.com/hannesrauhe/freeps/utils"
+)
+
+// persistedRecord is a single entry in a snapshot or a line in the journal of the embedded namespace
+type persistedRecord struct {
+	Key       string
+	Deleted   bool `json:",omitempty"`
+	Timestamp time.Time
+	Metadata  fileMetadata `json:",omitempty"`
+	Value     []byte       `json:",omitempty"`
+}
+
+func entryToRecord(key string, e StoreEntry) (persistedRecord, error) {
+	b, md, err := outputToFile(e.data, e.modifiedBy)
+	return persistedRecord{Key: key, Timestamp: e.timestamp, Metadata: md, Value: b}, err
+}
+
+func (r persistedRecord) toEntry() StoreEntry {
+	var modifiedBy *base.Context
+	if r.Metadata.ModifiedBy != "" {
+		modifiedBy = base.RestoreContext(r.Metadata.ModifiedBy, r.Metadata.Reason)
+	}
+	return StoreEntry{data: outputFromFile(r.Value, r.Metadata), timestamp: r.Timestamp, modifiedBy: modifiedBy}
+}
+
+// storeSnapshot is the content of a snapshot file
+type storeSnapshot struct {
+	Offset  int `json:",omitempty"` // offset of the first entry in a log namespace
+	Entries []persistedRecord
+}
+
+// snapshotNamespace is implemented by namespaces that only keep their entries in memory but can be persisted on demand
+type snapshotNamespace interface {
+	StoreNamespace
+	snapshot() (storeSnapshot, error)
+	restore(storeSnapshot)
+}
+
+var _ snapshotNamespace = &inMemoryStoreNamespace{}
+var _ snapshotNamespace = &logStoreNamespace{}
+
+// restoringNamespace is implemented by namespaces that can set an entry with its original timestamp and metadata, e.g. when importing an export
+type restoringNamespace interface {
+	restoreEntry(key string, e StoreEntry) StoreEntry
+}
+
+var _ restoringNamespace = &inMemoryStoreNamespace{}
+var _ restoringNamespace = &logStoreNamespace{}
+var _ restoringNamespace = &embeddedStoreNamespace{}
+
+// persistedNamespace keeps track of a namespace that is periodically written to a snapshot file
+type persistedNamespace struct {
+	ns        snapshotNamespace
+	file      string
+	interval  time.Duration
+	lastWrite time.Time
+	lock      sync.Mutex
+}
+
+// getDataDir returns the directory for files of namespaces that are kept across restarts
+func (s *Store) getDataDir() string {
+	if s.dataDir != "" {
+		return s.dataDir
+	}
+	return path.Join(path.Dir(utils.GetDefaultPath("freeps")), "store")
+}
+
+// enablePersistence restores the namespace from its snapshot file and registers it for periodic snapshots
+func (s *Store) enablePersistence(ns string, nsStore snapshotNamespace, config StoreNamespaceConfig) error {
+	file := config.File
+	if file == "" {
+		file = path.Join(s.getDataDir(), utils.StringToIdentifier(ns)+".snapshot.json")
+	}
+	err := os.MkdirAll(path.Dir(file), 0777)
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(file)
+	if err == nil {
+		snap := storeSnapshot{}
+		err = json.Unmarshal(b, &snap)
+		if err != nil {
+			return err
+		}
+		nsStore.restore(snap)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	s.persisted[ns] = &persistedNamespace{ns: nsStore, file: file, interval: config.PersistInterval, lastWrite: time.Now()}
+	return nil
+}
+
+func (p *persistedNamespace) write() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	snap, err := p.ns.snapshot()
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	p.lastWrite = time.Now()
+	return writeFileAtomic(path.Dir(p.file), p.file, b)
+}
+
+// writeSnapshots writes the snapshots of all persisted namespaces whose interval has passed, or of all persisted namespaces if force is set
+func (s *Store) writeSnapshots(ctx *base.Context, force bool) {
+	s.globalLock.Lock()
+	due := []*persistedNamespace{}
+	for _, p := range s.persisted {
+		p.lock.Lock()
+		if force || time.Since(p.lastWrite) >= p.interval {
+			due = append(due, p)
+		}
+		p.lock.Unlock()
+	}
+	s.globalLock.Unlock()
+
+	for _, p := range due {
+		err := p.write()
+		if err != nil {
+			ctx.GetLogger().Errorf("Cannot write snapshot of store namespace to %v: %v", p.file, err)
+		}
+	}
+}
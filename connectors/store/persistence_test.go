@@ -0,0 +1,66 @@
+package freepsstore
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+	"github.com/hannesrauhe/freeps/utils"
+	"github.com/sirupsen/logrus"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestSnapshotAndRestore(t *testing.T) {
+	ctx := base.NewBaseContextWithReason(logrus.StandardLogger(), "test")
+	tdir := t.TempDir()
+	cr, err := utils.NewConfigReader(logrus.StandardLogger(), path.Join(tdir, "test_config.json"))
+	assert.NilError(t, err)
+	logFile := path.Join(tdir, "log.json")
+	config := StoreConfig{Namespaces: map[string]StoreNamespaceConfig{
+		"mem": {NamespaceType: "memory", PersistInterval: time.Hour},
+		"log": {NamespaceType: "log", PersistInterval: time.Hour, File: logFile},
+	}}
+	assert.NilError(t, cr.WriteSection("store", &config, true))
+
+	s := base.MakeFreepsOperators(&OpStore{CR: cr}, cr, ctx)[0]
+	s.StartListening(ctx)
+	mem := store.GetNamespaceNoError("mem")
+	mem.SetValue("Plain", base.MakePlainOutput("value"), ctx)
+	mem.SetValue("int", base.MakeIntegerOutput(42), ctx)
+	mem.SetValue("object", base.MakeObjectOutput(map[string]int{"a": 1}), ctx)
+	ts := mem.GetValue("plain").GetTimestamp()
+	logNs := store.GetNamespaceNoError("log")
+	logNs.SetValue("", base.MakePlainOutput("first"), ctx)
+	logNs.SetValue("", base.MakePlainOutput("second"), ctx)
+	logNs.Trim(1)
+	s.Shutdown(ctx)
+	_, err = os.Stat(path.Join(tdir, "store", "mem.snapshot.json"))
+	assert.NilError(t, err)
+	_, err = os.Stat(logFile)
+	assert.NilError(t, err)
+
+	/* a new instance of the operator restores the namespaces */
+	base.MakeFreepsOperators(&OpStore{CR: cr}, cr, ctx)
+	mem = store.GetNamespaceNoError("mem")
+	assert.Equal(t, mem.Len(), 3)
+	e := mem.GetValue("plain")
+	assert.Equal(t, e.GetData().GetString(), "value")
+	assert.Assert(t, e.GetTimestamp().Equal(ts))
+	assert.Equal(t, e.GetModifiedBy(), ctx.GetID())
+	assert.Equal(t, e.GetReason(), "test")
+	assert.Assert(t, mem.GetValue("int").GetData().IsInteger())
+	assert.Assert(t, mem.GetValue("object").GetData().IsObject())
+	assert.Assert(t, cmp.Contains(mem.GetKeys(), "Plain"))
+
+	logNs = store.GetNamespaceNoError("log")
+	assert.Equal(t, logNs.Len(), 1)
+	assert.Equal(t, logNs.GetValue("1").GetData().GetString(), "second")
+	assert.Equal(t, logNs.GetValue("0"), NotFoundEntry)
+
+	/* namespaces that cannot be snapshotted are rejected */
+	_, err = store.CreateNamespace("files", StoreNamespaceConfig{NamespaceType: "null", PersistInterval: time.Hour})
+	assert.ErrorContains(t, err, "does not support snapshots")
+}
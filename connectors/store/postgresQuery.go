@@ -0,0 +1,100 @@
+//go:build !nopostgres
+
+package freepsstore
+
+import (
+	"fmt"
+	"strings"
+)
+
+var _ queryNamespace = &postgresStoreNamespace{}
+
+const postgresValueText = "coalesce(value_plain, value_json::text, encode(value_bytes, 'escape'))"
+
+var postgresOperators = map[string]string{"==": "=", "!=": "<>", "<": "<", "<=": "<=", ">": ">", ">=": ">="}
+
+// sqlCondition translates a condition into SQL, the parameters are appended to params
+func sqlCondition(c queryCondition, params *[]any) string {
+	addParam := func(v any) string {
+		*params = append(*params, v)
+		return fmt.Sprintf("$%d", len(*params))
+	}
+
+	column := ""
+	switch c.field {
+	case "key":
+		column = "key"
+	case "modifiedBy":
+		column = "modified_by"
+	case "reason":
+		column = "modified_reason"
+	case "value":
+		column = postgresValueText
+	default:
+		quoted := make([]string, 0, len(c.path))
+		for _, p := range c.path {
+			quoted = append(quoted, `"`+strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(p)+`"`)
+		}
+		column = fmt.Sprintf("(value_json::jsonb #>> %s::text[])", addParam("{"+strings.Join(quoted, ",")+"}"))
+	}
+
+	if c.op == "~" {
+		return fmt.Sprintf("%s ILIKE '%%' || %s || '%%'", column, addParam(fmt.Sprint(c.literal)))
+	}
+	if f, isNumber := c.literal.(float64); isNumber {
+		return fmt.Sprintf("(case when %[1]s ~ '^\\s*-?[0-9]+(\\.[0-9]*)?([eE][-+]?[0-9]+)?\\s*$' then (%[1]s)::numeric end) %[2]s %[3]s", column, postgresOperators[c.op], addParam(f))
+	}
+	return fmt.Sprintf("%s %s %s", column, postgresOperators[c.op], addParam(fmt.Sprint(c.literal)))
+}
+
+// runQuery evaluates the filter, sort order and pagination in the database
+func (p *postgresStoreNamespace) runQuery(q storeQuery) ([]QueryResultEntry, int, bool, error) {
+	if err := p.ensureTable(); err != nil {
+		return nil, 0, false, err
+	}
+	params := []any{}
+	conditions := []string{"1=1"}
+	for _, c := range q.conditions {
+		conditions = append(conditions, sqlCondition(c, &params))
+	}
+	total := 0
+	err := db.QueryRow(fmt.Sprintf("select count(*) from %s where %s", p.table(), strings.Join(conditions, " and ")), params...).Scan(&total)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	direction, comparison := "asc", ">"
+	if q.descending {
+		direction, comparison = "desc", "<"
+	}
+	order := fmt.Sprintf("key %s", direction)
+	if q.sortBy == "timestamp" {
+		order = fmt.Sprintf("modification_time %[1]s, key %[1]s", direction)
+	}
+	if q.after != nil {
+		params = append(params, q.after.Key)
+		if q.sortBy == "timestamp" {
+			params = append(params, q.after.Timestamp)
+			conditions = append(conditions, fmt.Sprintf("(modification_time, key) %s ($%d, $%d)", comparison, len(params), len(params)-1))
+		} else {
+			conditions = append(conditions, fmt.Sprintf("key %s $%d", comparison, len(params)))
+		}
+	}
+	rows, err := db.Query(fmt.Sprintf("select %s from %s where %s order by %s limit %d offset %d", postgresEntryColumns, p.table(), strings.Join(conditions, " and "), order, q.limit+1, q.offset), params...)
+	if err != nil {
+		return nil, total, false, err
+	}
+	entries, keys, err := p.scanEntries(rows)
+	if err != nil {
+		return nil, total, false, err
+	}
+	more := len(keys) > q.limit
+	if more {
+		keys = keys[:q.limit]
+	}
+	res := make([]QueryResultEntry, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, makeQueryResultEntry(k, entries[k]))
+	}
+	return res, total, more, nil
+}
@@ -0,0 +1,19 @@
+//go:build !nopostgres
+
+package freepsstore
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestPostgresQueryConditions(t *testing.T) {
+	conditions, err := parseFilter(`value.sensor.temperature > 20 && key ~ living && reason == "my reason"`)
+	assert.NilError(t, err)
+	params := []any{}
+	assert.Equal(t, sqlCondition(conditions[0], &params), `(case when (value_json::jsonb #>> $1::text[]) ~ '^\s*-?[0-9]+(\.[0-9]*)?([eE][-+]?[0-9]+)?\s*$' then ((value_json::jsonb #>> $1::text[]))::numeric end) > $2`)
+	assert.Equal(t, sqlCondition(conditions[1], &params), `key ILIKE '%' || $3 || '%'`)
+	assert.Equal(t, sqlCondition(conditions[2], &params), `modified_reason = $4`)
+	assert.DeepEqual(t, params, []any{`{"sensor","temperature"}`, float64(20), "living", "my reason"})
+}
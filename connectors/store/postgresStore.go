@@ -9,10 +9,13 @@ import (
 	"math"
 	"net/http"
 	"os"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/hannesrauhe/freeps/base"
 	"github.com/hannesrauhe/freeps/utils"
+	"github.com/sirupsen/logrus"
 
 	_ "github.com/lib/pq"
 )
@@ -36,6 +39,13 @@ func (s *Store) initPostgres() error {
 	return nil
 }
 
+// postgresMigrations are applied in order to every table, the index+1 is the version of the table layout after the migration;
+// "%[1]s" is replaced with the qualified table name
+var postgresMigrations = []string{
+	"create table if not exists %[1]s (key text primary key, output_type text not null, content_type text not null, http_code smallint not null, value_bytes bytea default NULL, value_plain text default NULL, value_json json default NULL, modification_time timestamp with time zone default current_timestamp not null, modified_by text not null);",
+	"alter table %[1]s add column if not exists modified_reason text not null default ''; create index if not exists %[2]s_modification_time on %[1]s (modification_time);",
+}
+
 func newPostgresStoreNamespace(nsName string, nsConfig StoreNamespaceConfig) (*postgresStoreNamespace, error) {
 	hostname, err := os.Hostname()
 	if err != nil {
@@ -46,35 +56,112 @@ func newPostgresStoreNamespace(nsName string, nsConfig StoreNamespaceConfig) (*p
 	if schemaName == "" {
 		schemaName = "freeps_" + utils.StringToIdentifier(hostname)
 	}
-	if _, err = db.Exec("create schema if not exists " + schemaName); err != nil {
-		return nil, err
-	}
 
 	name := utils.StringToIdentifier(nsConfig.TableName)
 	if name == "" {
 		name = utils.StringToIdentifier(nsName)
 	}
-	if _, err := db.Exec(fmt.Sprintf("create table if not exists %s.%s (key text primary key, output_type text not null, content_type text not null, http_code smallint not null, value_bytes bytea default NULL, value_plain text default NULL, value_json json default NULL, modification_time timestamp with time zone default current_timestamp not null, modified_by text not null);", schemaName, name)); err != nil {
-		return nil, err
-	}
 
-	ns := &postgresStoreNamespace{schema: nsConfig.SchemaName, name: nsConfig.TableName}
+	ns := &postgresStoreNamespace{schema: schemaName, name: name}
+	// the table is created on first use if the database is not reachable yet
+	if err := ns.ensureTable(); err != nil {
+		logrus.Warnf("Postgres table %v.%v not ready yet: %v", schemaName, name, err)
+	}
 	return ns, nil
 }
 
 type postgresStoreNamespace struct {
-	qlog   StoreNamespace
-	schema string
-	name   string
+	qlog        StoreNamespace
+	schema      string
+	name        string
+	initialized bool
+	initLock    sync.Mutex
 }
 
 var _ StoreNamespace = &postgresStoreNamespace{}
 
-func (p *postgresStoreNamespace) query(limit int, projection string, filter string, args ...any) (*sql.Rows, error) {
+// postgresExecutor is implemented by sql.DB and sql.Tx
+type postgresExecutor interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+const postgresEntryColumns = "key, http_code, output_type, content_type, value_plain, value_bytes, value_json, modified_by, modified_reason, modification_time"
+
+func (p *postgresStoreNamespace) table() string {
+	return p.schema + "." + p.name
+}
+
+// ensureTable creates the schema and the table and migrates the table to the latest layout
+func (p *postgresStoreNamespace) ensureTable() error {
+	p.initLock.Lock()
+	defer p.initLock.Unlock()
+	if p.initialized {
+		return nil
+	}
+	if db == nil {
+		return fmt.Errorf("Postgres connection has not been established")
+	}
+	if _, err := db.Exec("create schema if not exists " + p.schema); err != nil {
+		return err
+	}
+	if _, err := db.Exec(fmt.Sprintf("create table if not exists %s.freeps_migrations (table_name text primary key, version int not null)", p.schema)); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// serialize migrations of multiple instances
+	if _, err := tx.Exec("select pg_advisory_xact_lock(hashtext($1))", p.table()); err != nil {
+		return err
+	}
+	version := 0
+	err = tx.QueryRow(fmt.Sprintf("select version from %s.freeps_migrations where table_name=$1", p.schema), p.name).Scan(&version)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	for _, migration := range p.migrations(version) {
+		if _, err := tx.Exec(migration); err != nil {
+			return fmt.Errorf("migration of %v to version %v failed: %v", p.table(), version+1, err)
+		}
+		version++
+	}
+	if _, err := tx.Exec(fmt.Sprintf("insert into %s.freeps_migrations (table_name, version) values ($1, $2) on conflict (table_name) do update set version=excluded.version", p.schema), p.name, version); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	p.initialized = true
+	return nil
+}
+
+// migrations returns the statements that migrate the table from the given version to the latest layout
+func (p *postgresStoreNamespace) migrations(version int) []string {
+	statements := []string{}
+	for ; version < len(postgresMigrations); version++ {
+		statements = append(statements, fmt.Sprintf(postgresMigrations[version], p.table(), p.name))
+	}
+	return statements
+}
+
+// queryString builds a select statement ordered by modification time, without limit if limit is 0
+func (p *postgresStoreNamespace) queryString(limit int, projection string, filter string, suffix string) string {
 	if filter == "" {
 		filter = "1=1"
 	}
-	queryString := fmt.Sprintf("select %v from %v.%v where %v order by modification_time desc limit %d", projection, p.schema, p.name, filter, limit)
+	queryString := fmt.Sprintf("select %v from %v where %v order by modification_time desc", projection, p.table(), filter)
+	if limit > 0 {
+		queryString += fmt.Sprintf(" limit %d", limit)
+	}
+	return queryString + suffix
+}
+
+func (p *postgresStoreNamespace) query(ex postgresExecutor, limit int, projection string, filter string, suffix string, args ...any) (*sql.Rows, error) {
+	queryString := p.queryString(limit, projection, filter, suffix)
 
 	// if p.qlog == nil {
 	// 	p.qlog = store.GetNamespace("_postgres_query_log")
@@ -82,7 +169,7 @@ func (p *postgresStoreNamespace) query(limit int, projection string, filter stri
 	// if p.qlog != nil {
 	// 	p.qlog.SetValue(time.Now().Format("2006/01/02 15:04:05.00000"), base.MakePlainOutput("query: %v", queryString), "postgresStoreNamespace.query")
 	// }
-	return db.Query(queryString, args...)
+	return ex.Query(queryString, args...)
 }
 
 func (p *postgresStoreNamespace) entryToOutput(output *base.OperatorIO, valuePlain sql.NullString, valueBytes []byte, valueJSON []byte) {
@@ -92,41 +179,281 @@ func (p *postgresStoreNamespace) entryToOutput(output *base.OperatorIO, valuePla
 	case base.PlainText:
 		if !valuePlain.Valid {
 			*output = *base.MakeOutputError(http.StatusInternalServerError, "getValue: invalid object in db: plain value is NULL")
+			return
 		}
 		output.Output = valuePlain.String
 	case base.Byte:
 		output.Output = valueBytes
+	case base.Skipped:
+		output.Output = valuePlain.String
 	case base.Object:
 		output.Output = map[string]interface{}{}
 		json.Unmarshal(valueJSON, &output.Output)
+	case base.Integer, base.FloatingPoint:
+		o, err := base.MakeOutputWithGivenType(string(valueBytes), output.OutputType)
+		if err != nil {
+			*output = *base.MakeOutputError(http.StatusInternalServerError, "getValue: invalid number in db: %v", err)
+			return
+		}
+		output.Output = o.Output
+	case base.Error:
+		*output = *base.MakeOutputError(output.HTTPCode, "%s", valuePlain.String)
 	default:
 		*output = *base.MakeOutputError(http.StatusInternalServerError, "getValue: invalid object in db: OutputType unkown")
 	}
 }
 
+// scanEntries reads all rows that were selected with postgresEntryColumns
+func (p *postgresStoreNamespace) scanEntries(rows *sql.Rows) (map[string]StoreEntry, []string, error) {
+	defer rows.Close()
+	result := map[string]StoreEntry{}
+	keys := []string{}
+	for rows.Next() {
+		key := ""
+		modifiedBy := ""
+		reason := ""
+		e := StoreEntry{
+			data: &base.OperatorIO{},
+		}
+		var valuePlain sql.NullString
+		var valueBytes, valueJSON []byte
+		if err := rows.Scan(&key, &e.data.HTTPCode, &e.data.OutputType, &e.data.ContentType, &valuePlain, &valueBytes, &valueJSON, &modifiedBy, &reason, &e.timestamp); err != nil {
+			return result, keys, err
+		}
+		p.entryToOutput(e.data, valuePlain, valueBytes, valueJSON)
+		if modifiedBy != "" {
+			e.modifiedBy = base.RestoreContext(modifiedBy, reason)
+		}
+		result[key] = e
+		keys = append(keys, key)
+	}
+	return result, keys, rows.Err()
+}
+
+// getValue reads a single entry, forUpdate locks the row until the transaction ex is finished
+func (p *postgresStoreNamespace) getValue(ex postgresExecutor, key string, forUpdate bool) StoreEntry {
+	suffix := ""
+	if forUpdate {
+		suffix = " for update"
+	}
+	rows, err := p.query(ex, 1, postgresEntryColumns, "key=$1", suffix, key)
+	if err != nil {
+		return MakeEntryError(http.StatusInternalServerError, "getValue: %v", err)
+	}
+	entries, _, err := p.scanEntries(rows)
+	if err != nil {
+		return MakeEntryError(http.StatusInternalServerError, "getValue: %v", err)
+	}
+	e, ok := entries[key]
+	if !ok {
+		return NotFoundEntry
+	}
+	return e
+}
+
+// setValue inserts or replaces the entry
+func (p *postgresStoreNamespace) setValue(ex postgresExecutor, key string, io *base.OperatorIO, modifiedBy *base.Context) StoreEntry {
+	return p.setEntry(ex, key, StoreEntry{timestamp: time.Now(), data: io, modifiedBy: modifiedBy})
+}
+
+// setEntry inserts or replaces the entry including its timestamp
+func (p *postgresStoreNamespace) setEntry(ex postgresExecutor, key string, se StoreEntry) StoreEntry {
+	if key == "" {
+		// log-like usage (e.g. the execution log): every entry gets a new key
+		key = strconv.FormatInt(time.Now().UnixNano(), 10)
+	}
+	io := se.data
+	modifiedBy := se.modifiedBy
+	id := ""
+	reason := ""
+	if modifiedBy != nil {
+		id = modifiedBy.GetID()
+		reason = modifiedBy.GetReason()
+	}
+
+	var valuePlain, valueBytes, valueJSON any
+	if io.IsPlain() || io.IsError() {
+		valuePlain = io.GetString()
+	} else if io.IsSkipped() {
+		valuePlain = io.GetSkipReason()
+	} else if !io.IsEmpty() {
+		b, err := io.GetBytes()
+		if err != nil {
+			return MakeEntryError(http.StatusInternalServerError, "cannot get bytes for insertion in postgres: %v", err)
+		}
+		if io.IsObject() {
+			valueJSON = b
+		} else {
+			valueBytes = b
+		}
+	}
+	_, err := ex.Exec(fmt.Sprintf(`insert into %s ("key", output_type, content_type, http_code, modified_by, modified_reason, modification_time, value_plain, value_bytes, value_json) values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)
+		on conflict ("key") do update set output_type=excluded.output_type, content_type=excluded.content_type, http_code=excluded.http_code, modified_by=excluded.modified_by, modified_reason=excluded.modified_reason,
+		modification_time=excluded.modification_time, value_plain=excluded.value_plain, value_bytes=excluded.value_bytes, value_json=excluded.value_json`, p.table()),
+		key, io.OutputType, io.ContentType, io.HTTPCode, id, reason, se.timestamp, valuePlain, valueBytes, valueJSON)
+	if err != nil {
+		return MakeEntryError(http.StatusInternalServerError, "error when inserting into postgres: %v", err)
+	}
+	return se
+}
+
+// transaction executes fn in a transaction that holds a lock for the key, so that it is also protected if the key does not exist yet
+func (p *postgresStoreNamespace) transaction(key string, fn func(tx *sql.Tx) StoreEntry) StoreEntry {
+	if err := p.ensureTable(); err != nil {
+		return MakeEntryError(http.StatusServiceUnavailable, "postgres table not available: %v", err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return MakeEntryError(http.StatusInternalServerError, "cannot start transaction: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("select pg_advisory_xact_lock(hashtext($1))", p.table()+"/"+key); err != nil {
+		return MakeEntryError(http.StatusInternalServerError, "cannot lock key: %v", err)
+	}
+	e := fn(tx)
+	if e.IsError() {
+		return e
+	}
+	if err := tx.Commit(); err != nil {
+		return MakeEntryError(http.StatusInternalServerError, "cannot commit transaction: %v", err)
+	}
+	return e
+}
+
+// postgresTransaction is a database transaction that holds the locks of all keys of a multi-key transaction
+type postgresTransaction struct {
+	p  *postgresStoreNamespace
+	tx *sql.Tx
+}
+
+var _ transactionalNamespace = &postgresStoreNamespace{}
+
+func (p *postgresStoreNamespace) beginTransaction(keys []string) (namespaceTransaction, error) {
+	if err := p.ensureTable(); err != nil {
+		return nil, err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	// the keys are sorted by the caller, so that concurrent transactions acquire the locks in the same order
+	for _, key := range keys {
+		if _, err := tx.Exec("select pg_advisory_xact_lock(hashtext($1))", p.table()+"/"+key); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("cannot lock key: %v", err)
+		}
+	}
+	return &postgresTransaction{p: p, tx: tx}, nil
+}
+
+func (t *postgresTransaction) get(key string) StoreEntry {
+	return t.p.getValue(t.tx, key, true)
+}
+
+func (t *postgresTransaction) set(key string, io *base.OperatorIO, modifiedBy *base.Context) StoreEntry {
+	return t.p.setValue(t.tx, key, io, modifiedBy)
+}
+
+func (t *postgresTransaction) commit() error {
+	return t.tx.Commit()
+}
+
+func (t *postgresTransaction) rollback() {
+	t.tx.Rollback()
+}
+
+// CompareAndSwap sets the value if the string representation of the already stored value is as expected
 func (p *postgresStoreNamespace) CompareAndSwap(key string, expected string, newValue *base.OperatorIO, modifiedBy *base.Context) StoreEntry {
-	return MakeEntryError(http.StatusNotImplemented, "postgres support not fully implemented yet")
+	return p.transaction(key, func(tx *sql.Tx) StoreEntry {
+		oldV := p.getValue(tx, key, true)
+		if oldV == NotFoundEntry || oldV.IsError() {
+			return oldV
+		}
+		if oldV.data.GetString() != expected {
+			return MakeEntryError(http.StatusConflict, "old value is different from expectation")
+		}
+		return p.setValue(tx, key, newValue, modifiedBy)
+	})
 }
 
+// DeleteOlder deletes records older than maxAge
 func (p *postgresStoreNamespace) DeleteOlder(maxAge time.Duration) int {
-	panic("not implemented") // TODO: Implement
+	if err := p.ensureTable(); err != nil {
+		return 0
+	}
+	res, err := db.Exec(fmt.Sprintf("delete from %s where modification_time < $1", p.table()), time.Now().Add(-maxAge))
+	if err != nil {
+		return 0
+	}
+	n, _ := res.RowsAffected()
+	return int(n)
 }
 
+// Trim deletes all but the k most recently modified records
 func (p *postgresStoreNamespace) Trim(k int) int {
-	panic("not implemented") // TODO: Implement
+	if err := p.ensureTable(); err != nil {
+		return 0
+	}
+	res, err := db.Exec(fmt.Sprintf("delete from %[1]s where key not in (select key from %[1]s order by modification_time desc limit $1)", p.table()), k)
+	if err != nil {
+		return 0
+	}
+	n, _ := res.RowsAffected()
+	return int(n)
+}
+
+// trimToSizeStatement deletes the oldest records once the sum of the value sizes of all newer records exceeds $1
+func (p *postgresStoreNamespace) trimToSizeStatement() string {
+	return fmt.Sprintf(`delete from %[1]s where key in (select key from (select key, sum(coalesce(octet_length(value_plain), 0) + coalesce(octet_length(value_bytes), 0) + coalesce(octet_length(value_json::text), 0))
+		over (order by modification_time desc, key) as total from %[1]s) sizes where total > $1)`, p.table())
 }
 
+var _ sizeTrimmingNamespace = &postgresStoreNamespace{}
+
+// trimToSize deletes the oldest records until the size of all values is below maxBytes
+func (p *postgresStoreNamespace) trimToSize(maxBytes int) int {
+	if err := p.ensureTable(); err != nil {
+		return 0
+	}
+	res, err := db.Exec(p.trimToSizeStatement(), maxBytes)
+	if err != nil {
+		return 0
+	}
+	n, _ := res.RowsAffected()
+	return int(n)
+}
+
+// DeleteValue deletes the record with the given key
 func (p *postgresStoreNamespace) DeleteValue(key string) {
-	panic("not implemented") // TODO: Implement
+	if err := p.ensureTable(); err != nil {
+		return
+	}
+	db.Exec(fmt.Sprintf("delete from %s where key=$1", p.table()), key)
 }
 
+// GetAllValues returns the limit most recently modified values, all values if limit is 0
 func (p *postgresStoreNamespace) GetAllValues(limit int) map[string]*base.OperatorIO {
-	panic("not implemented") // TODO: Implement
+	res := map[string]*base.OperatorIO{}
+	if err := p.ensureTable(); err != nil {
+		return res
+	}
+	rows, err := p.query(db, limit, postgresEntryColumns, "", "")
+	if err != nil {
+		return res
+	}
+	entries, _, _ := p.scanEntries(rows)
+	for k, e := range entries {
+		res[k] = e.data
+	}
+	return res
 }
 
 func (p *postgresStoreNamespace) GetKeys() []string {
 	res := []string{}
-	rows, err := p.query(100, "key", "")
+	if err := p.ensureTable(); err != nil {
+		return res
+	}
+	rows, err := p.query(db, 0, "key", "", "")
 	if err != nil {
 		return res
 	}
@@ -144,156 +471,160 @@ func (p *postgresStoreNamespace) GetKeys() []string {
 	return res
 }
 
+// GetSearchResultWithMetadata searches case-insensitively for substrings in key, value and ID, and returns only records younger than maxAge
 func (p *postgresStoreNamespace) GetSearchResultWithMetadata(keyPattern string, valuePattern string, modifiedByPattern string, minAge time.Duration, maxAge time.Duration) map[string]StoreEntry {
 	result := map[string]StoreEntry{}
+	if err := p.ensureTable(); err != nil {
+		result["error"] = MakeEntryError(http.StatusServiceUnavailable, "postgres table not available: %v", err)
+		return result
+	}
+	filter, filterParts := searchFilter(keyPattern, valuePattern, modifiedByPattern, minAge, maxAge, time.Now())
+	rows, err := p.query(db, 0, postgresEntryColumns, filter, "", filterParts...)
+	if err != nil {
+		result["error"] = MakeEntryError(http.StatusInternalServerError, "getValue: %v", err)
+		return result
+	}
+	result, _, err = p.scanEntries(rows)
+	if err != nil {
+		result["error"] = MakeEntryError(http.StatusInternalServerError, "getValue: %v", err)
+	}
+	return result
+}
+
+// searchFilter builds the where clause and its parameters for GetSearchResultWithMetadata
+func searchFilter(keyPattern string, valuePattern string, modifiedByPattern string, minAge time.Duration, maxAge time.Duration, now time.Time) (string, []any) {
 	filter := ""
 	filterParts := []any{}
-	if keyPattern != "" {
-		filter = "key LIKE $1"
-		filterParts = append(filterParts, keyPattern)
-	}
-	if modifiedByPattern != "" {
+	addFilter := func(condition string, value any) {
 		if filter != "" {
 			filter += " AND "
 		}
-		filter += fmt.Sprintf("modified_by LIKE $%d", len(filterParts)+1)
-		filterParts = append(filterParts, modifiedByPattern)
+		filterParts = append(filterParts, value)
+		filter += fmt.Sprintf(condition, len(filterParts))
 	}
-	if minAge != 0 {
-		if filter != "" {
-			filter += " AND "
-		}
-		filter += fmt.Sprintf("modification_time < now() - interval '%v'", minAge.String())
+	if keyPattern != "" {
+		addFilter("key ILIKE '%%' || $%d || '%%'", keyPattern)
 	}
-	if maxAge != time.Duration(math.MaxInt64) {
-		if filter != "" {
-			filter += " AND "
-		}
-		filter += fmt.Sprintf("modification_time > now() - interval '%v'", maxAge.String())
-	}
-	// TODO(HR): meh
-	// if valuePattern != "" {
-	// 	if len(filterParts) > 0 {
-	// 		filter += " AND "
-	// 	}
-	// 	filter += fmt.Sprintf("value_plain LIKE $%d or value_bytes as string LIKE $%d or value_json as string LIKE $%d", len(filterParts)+1, len(filterParts)+1, len(filterParts)+1)
-	// 	filterParts = append(filterParts, valuePattern)
-	// }
-
-	rows, err := p.query(100, "key, http_code, output_type, content_type, value_plain, value_bytes, value_json, modified_by, modification_time", filter, filterParts...)
-	if err != nil {
-		e := StoreEntry{
-			timestamp: time.Now(),
-			data:      base.MakeOutputError(http.StatusInternalServerError, "getValue: %v", err),
-		}
-		result["error"] = e
-		return result
+	if modifiedByPattern != "" {
+		addFilter("modified_by LIKE '%%' || $%d || '%%'", modifiedByPattern)
 	}
-	defer rows.Close()
-	for rows.Next() {
-		key := ""
-		e := StoreEntry{
-			data: &base.OperatorIO{},
-		}
-		var valuePlain sql.NullString
-		var valueBytes, valueJSON []byte
-		if err := rows.Scan(&key, &e.data.HTTPCode, &e.data.OutputType, &e.data.ContentType, &valuePlain, &valueBytes, &valueJSON, &e.modifiedBy, &e.timestamp); err != nil {
-			result["error"] = StoreEntry{
-				timestamp: time.Now(),
-				data:      base.MakeOutputError(http.StatusInternalServerError, "getValue: %v", err),
-			}
-			return result
-		}
-		p.entryToOutput(e.data, valuePlain, valueBytes, valueJSON)
-		result[key] = e
+	if valuePattern != "" {
+		addFilter("(value_plain LIKE '%%' || $%[1]d || '%%' OR encode(value_bytes, 'escape') LIKE '%%' || $%[1]d || '%%' OR value_json::text LIKE '%%' || $%[1]d || '%%')", valuePattern)
 	}
-	if err := rows.Err(); err != nil {
-		result["error"] = StoreEntry{
-			timestamp: time.Now(),
-			data:      base.MakeOutputError(http.StatusInternalServerError, "getValue: %v", err),
-		}
+	if minAge != 0 {
+		addFilter("modification_time < $%d", now.Add(-minAge))
 	}
-	return result
+	if maxAge != time.Duration(math.MaxInt64) {
+		addFilter("modification_time > $%d", now.Add(-maxAge))
+	}
+	return filter, filterParts
 }
 
 func (p *postgresStoreNamespace) GetValue(key string) StoreEntry {
-	e := StoreEntry{
-		timestamp: time.Now(),
-		data:      &base.OperatorIO{},
-	}
-	rows, err := p.query(1, "http_code, output_type, content_type, value_plain, value_bytes, value_json, modified_by, modification_time", "key=$1", key)
-	if err != nil {
-		e.data = base.MakeOutputError(http.StatusInternalServerError, "getValue: %v", err)
-		return e
-	}
-	defer rows.Close()
-	for rows.Next() {
-		var valuePlain sql.NullString
-		var valueBytes, valueJSON []byte
-		if err := rows.Scan(&e.data.HTTPCode, &e.data.OutputType, &e.data.ContentType, &valuePlain, &valueBytes, &valueJSON, &e.modifiedBy, &e.timestamp); err != nil {
-			return StoreEntry{
-				timestamp: time.Now(),
-				data:      base.MakeOutputError(http.StatusInternalServerError, "getValue: %v", err),
-			}
-		}
-		p.entryToOutput(e.data, valuePlain, valueBytes, valueJSON)
-		return e
-	}
-	if err := rows.Err(); err != nil {
-		e.data = base.MakeOutputError(http.StatusInternalServerError, "getValue: %v", err)
-		return e
+	if err := p.ensureTable(); err != nil {
+		return MakeEntryError(http.StatusServiceUnavailable, "postgres table not available: %v", err)
 	}
-	return NotFoundEntry
+	return p.getValue(db, key, false)
 }
 
+// GetValueBeforeExpiration gets the value, but returns an error if older than maxAge
 func (p *postgresStoreNamespace) GetValueBeforeExpiration(key string, maxAge time.Duration) StoreEntry {
-	return StoreEntry{
-		timestamp: time.Now(),
-		data:      base.MakeOutputError(http.StatusNotImplemented, "postgres support not fully implemented yet"),
+	e := p.GetValue(key)
+	if e == NotFoundEntry || e.IsError() {
+		return e
+	}
+	if e.timestamp.Add(maxAge).Before(time.Now()) {
+		e.data = base.MakeOutputError(http.StatusGone, "Entry is older than %v", maxAge)
 	}
+	return e
 }
 
+// OverwriteValueIfOlder sets the value only if the key does not exist or has been written before maxAge
 func (p *postgresStoreNamespace) OverwriteValueIfOlder(key string, io *base.OperatorIO, maxAge time.Duration, modifiedBy *base.Context) StoreEntry {
-	return MakeEntryError(http.StatusNotImplemented, "postgres support not fully implemented yet")
+	return p.transaction(key, func(tx *sql.Tx) StoreEntry {
+		n := time.Now()
+		md := p.getValue(tx, key, true)
+		if md != NotFoundEntry && md.IsError() {
+			return md
+		}
+		if md != NotFoundEntry && md.timestamp.Add(maxAge).After(n) {
+			return MakeEntryError(http.StatusConflict, "%v already exists and is only %v old", key, n.Sub(md.timestamp))
+		}
+		return p.setValue(tx, key, io, modifiedBy)
+	})
 }
 
+// SetValue inserts or replaces the value, a new key is generated if key is empty
 func (p *postgresStoreNamespace) SetValue(key string, io *base.OperatorIO, modifiedBy *base.Context) StoreEntry {
-	var execErr error
-	se := StoreEntry{timestamp: time.Now(), data: io, modifiedBy: modifiedBy}
-	insertStart := fmt.Sprintf("insert into %s.%s(\"key\", output_type, content_type, http_code, modified_by, modification_time", p.schema, p.name)
-	if io.IsEmpty() {
-		_, execErr = db.Exec(insertStart+") values($1,$2,$3,$4,$5,$6)", key, io.OutputType, io.ContentType, io.HTTPCode, modifiedBy, se.timestamp)
-	} else if io.IsPlain() {
-		_, execErr = db.Exec(insertStart+", value_plain) values($1,$2,$3,$4,$5,$6,$7)", key, io.OutputType, io.ContentType, io.HTTPCode, modifiedBy, se.timestamp, io.GetString())
-	} else {
-		b, err := io.GetBytes()
-		if err != nil {
-			return MakeEntryError(http.StatusInternalServerError, "cannot get bytes for insertion in postgres: %v", err)
-		}
-		if io.IsObject() {
-			_, execErr = db.Exec(insertStart+", value_json) values($1,$2,$3,$4,$5,$6,$7)", key, io.OutputType, io.ContentType, io.HTTPCode, modifiedBy, se.timestamp, b)
-		} else {
-			_, execErr = db.Exec(insertStart+", value_bytes) values($1,$2,$3,$4,$5,$6,$7)", key, io.OutputType, io.ContentType, io.HTTPCode, modifiedBy, se.timestamp, b)
-		}
+	if err := p.ensureTable(); err != nil {
+		return MakeEntryError(http.StatusServiceUnavailable, "postgres table not available: %v", err)
 	}
-	if execErr != nil {
-		return MakeEntryError(http.StatusInternalServerError, "error when inserting into postgres: %v", execErr)
+	return p.setValue(db, key, io, modifiedBy)
+}
+
+var _ restoringNamespace = &postgresStoreNamespace{}
+
+// restoreEntry inserts or replaces the entry including its timestamp
+func (p *postgresStoreNamespace) restoreEntry(key string, e StoreEntry) StoreEntry {
+	if err := p.ensureTable(); err != nil {
+		return MakeEntryError(http.StatusServiceUnavailable, "postgres table not available: %v", err)
 	}
-	return se
+	return p.setEntry(db, key, e)
 }
 
+// SetAll sets all values in a single transaction
 func (p *postgresStoreNamespace) SetAll(valueMap map[string]interface{}, modifiedBy *base.Context) *base.OperatorIO {
-	return base.MakeOutputError(http.StatusNotImplemented, "postgres support not fully implemented yet")
+	if err := p.ensureTable(); err != nil {
+		return base.MakeOutputError(http.StatusServiceUnavailable, "postgres table not available: %v", err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return base.MakeOutputError(http.StatusInternalServerError, "cannot start transaction: %v", err)
+	}
+	defer tx.Rollback()
+	for k, v := range valueMap {
+		e := p.setValue(tx, k, base.MakeObjectOutput(v), modifiedBy)
+		if e.IsError() {
+			return e.GetData()
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return base.MakeOutputError(http.StatusInternalServerError, "cannot commit transaction: %v", err)
+	}
+	return base.MakeEmptyOutput()
 }
 
+// UpdateTransaction updates the value by calling the function fn with the current value, the row is locked with SELECT ... FOR UPDATE
 func (p *postgresStoreNamespace) UpdateTransaction(key string, fn func(StoreEntry) *base.OperatorIO, modifiedBy *base.Context) StoreEntry {
-	return MakeEntryError(http.StatusNotImplemented, "postgres support not fully implemented yet")
+	var unchanged *StoreEntry
+	e := p.transaction(key, func(tx *sql.Tx) StoreEntry {
+		oldEntry := p.getValue(tx, key, true)
+		if oldEntry != NotFoundEntry && oldEntry.IsError() {
+			return oldEntry
+		}
+		out := fn(oldEntry)
+		if out.IsError() {
+			return MakeEntry(out, modifiedBy)
+		}
+		if out.HTTPCode == http.StatusContinue {
+			unchanged = &oldEntry
+			return oldEntry
+		}
+		return p.setValue(tx, key, out, modifiedBy)
+	})
+	if unchanged != nil {
+		return *unchanged
+	}
+	return e
 }
 
 // Len returns the number of entries in the namespace
 func (p *postgresStoreNamespace) Len() int {
+	if err := p.ensureTable(); err != nil {
+		return -1
+	}
 	var count int
-	err := db.QueryRow("select count(*) from " + p.schema + "." + p.name).Scan(&count)
+	err := db.QueryRow("select count(*) from " + p.table()).Scan(&count)
 	if err != nil {
 		return -1
 	}
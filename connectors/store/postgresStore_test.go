@@ -0,0 +1,49 @@
+//go:build !nopostgres
+
+package freepsstore
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestPostgresMigrations(t *testing.T) {
+	p := &postgresStoreNamespace{schema: "freeps_host", name: "ns"}
+	all := p.migrations(0)
+	assert.Equal(t, len(all), len(postgresMigrations))
+	assert.Assert(t, len(all) > 1)
+	assert.Equal(t, all[0], "create table if not exists freeps_host.ns (key text primary key, output_type text not null, content_type text not null, http_code smallint not null, value_bytes bytea default NULL, value_plain text default NULL, value_json json default NULL, modification_time timestamp with time zone default current_timestamp not null, modified_by text not null);")
+	assert.Equal(t, all[1], "alter table freeps_host.ns add column if not exists modified_reason text not null default ''; create index if not exists ns_modification_time on freeps_host.ns (modification_time);")
+
+	/* an up-to-date table is not migrated again */
+	assert.DeepEqual(t, p.migrations(1), all[1:])
+	assert.Equal(t, len(p.migrations(len(postgresMigrations))), 0)
+}
+
+func TestPostgresQueryString(t *testing.T) {
+	p := &postgresStoreNamespace{schema: "freeps_host", name: "ns"}
+	assert.Equal(t, p.queryString(0, "key", "", ""), "select key from freeps_host.ns where 1=1 order by modification_time desc")
+	assert.Equal(t, p.queryString(1, "key", "key=$1", " for update"), "select key from freeps_host.ns where key=$1 order by modification_time desc limit 1 for update")
+}
+
+func TestPostgresSearchFilter(t *testing.T) {
+	now := time.Now()
+	filter, params := searchFilter("", "", "", 0, time.Duration(math.MaxInt64), now)
+	assert.Equal(t, filter, "")
+	assert.Equal(t, len(params), 0)
+
+	filter, params = searchFilter("k", "v", "id", time.Minute, time.Hour, now)
+	assert.Equal(t, filter, "key ILIKE '%' || $1 || '%' AND modified_by LIKE '%' || $2 || '%' AND "+
+		"(value_plain LIKE '%' || $3 || '%' OR encode(value_bytes, 'escape') LIKE '%' || $3 || '%' OR value_json::text LIKE '%' || $3 || '%') AND "+
+		"modification_time < $4 AND modification_time > $5")
+	assert.DeepEqual(t, params, []any{"k", "id", "v", now.Add(-time.Minute), now.Add(-time.Hour)})
+}
+
+func TestPostgresTrimToSizeStatement(t *testing.T) {
+	p := &postgresStoreNamespace{schema: "freeps_host", name: "ns"}
+	assert.Equal(t, p.trimToSizeStatement(), `delete from freeps_host.ns where key in (select key from (select key, sum(coalesce(octet_length(value_plain), 0) + coalesce(octet_length(value_bytes), 0) + coalesce(octet_length(value_json::text), 0))
+		over (order by modification_time desc, key) as total from freeps_host.ns) sizes where total > $1)`)
+}
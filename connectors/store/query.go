@@ -0,0 +1,389 @@
+package freepsstore
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+)
+
+// queryCondition is a single comparison of a filter expression like `value.temperature > 20`
+type queryCondition struct {
+	field   string // key, modifiedBy, reason, value or value.<path>
+	path    []string
+	op      string // ==, !=, <, <=, >, >= or ~ (contains, case-insensitive)
+	literal interface{}
+}
+
+// queryCursor is the position of the last entry of a page
+type queryCursor struct {
+	Key       string
+	Timestamp time.Time
+}
+
+// storeQuery selects, sorts and pages the entries of a namespace
+type storeQuery struct {
+	conditions []queryCondition
+	sortBy     string // key or timestamp
+	descending bool
+	offset     int
+	limit      int
+	after      *queryCursor
+}
+
+// QueryResultEntry is a single entry in the result of a query
+type QueryResultEntry struct {
+	Key        string
+	Timestamp  time.Time
+	ModifiedBy string `json:",omitempty"`
+	Reason     string `json:",omitempty"`
+	Value      interface{}
+}
+
+// QueryResult is a page of the entries that match a query
+type QueryResult struct {
+	Entries []QueryResultEntry
+	Total   int    // number of entries that match the filter, regardless of the page
+	Cursor  string `json:",omitempty"` // pass to the next query to get the next page; empty if this is the last page
+}
+
+// queryNamespace is implemented by namespaces that can evaluate queries themselves
+type queryNamespace interface {
+	runQuery(q storeQuery) ([]QueryResultEntry, int, bool, error)
+}
+
+var conditionRegexp = regexp.MustCompile(`^\s*([A-Za-z0-9_.\-]+)\s*(==|!=|<=|>=|<|>|~|=)\s*(.*?)\s*$`)
+
+// parseFilter parses conditions separated by "&&", a literal is a number, true, false or a string that can be quoted
+func parseFilter(filter string) ([]queryCondition, error) {
+	conditions := []queryCondition{}
+	if strings.TrimSpace(filter) == "" {
+		return conditions, nil
+	}
+	for _, part := range strings.Split(filter, "&&") {
+		m := conditionRegexp.FindStringSubmatch(part)
+		if m == nil {
+			return nil, fmt.Errorf("Invalid condition \"%v\"", strings.TrimSpace(part))
+		}
+		c := queryCondition{field: m[1], op: m[2]}
+		if c.op == "=" {
+			c.op = "=="
+		}
+		switch {
+		case c.field == "key" || c.field == "modifiedBy" || c.field == "reason" || c.field == "value":
+		case strings.HasPrefix(c.field, "value.") && len(c.field) > len("value."):
+			c.path = strings.Split(strings.TrimPrefix(c.field, "value."), ".")
+		default:
+			return nil, fmt.Errorf("Unknown field \"%v\", use key, modifiedBy, reason, value or value.<path>", c.field)
+		}
+		lit := m[3]
+		if len(lit) >= 2 && (lit[0] == '"' || lit[0] == '\'') && lit[len(lit)-1] == lit[0] {
+			c.literal = lit[1 : len(lit)-1]
+		} else if lit == "true" || lit == "false" {
+			c.literal = lit == "true"
+		} else if f, err := strconv.ParseFloat(lit, 64); err == nil {
+			c.literal = f
+		} else {
+			c.literal = lit
+		}
+		if _, isBool := c.literal.(bool); isBool && c.op != "==" && c.op != "!=" {
+			return nil, fmt.Errorf("Booleans can only be compared with == and !=")
+		}
+		conditions = append(conditions, c)
+	}
+	return conditions, nil
+}
+
+// entryValue returns the value of an entry as it would be parsed from JSON, or as a string if it is no JSON
+func entryValue(e StoreEntry) interface{} {
+	if e.data == nil || e.data.IsEmpty() {
+		return nil
+	}
+	var v interface{}
+	b, err := e.data.GetBytes()
+	if err == nil && json.Unmarshal(b, &v) == nil {
+		return v
+	}
+	return e.data.GetString()
+}
+
+// lookupPath returns the field of a nested object, array elements are selected by their index
+func lookupPath(v interface{}, path []string) (interface{}, bool) {
+	for _, p := range path {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = t[p]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(p)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil, false
+			}
+			v = t[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// getField returns a field of an entry as used in filters and projections
+func getField(key string, e StoreEntry, field string) (interface{}, bool) {
+	switch field {
+	case "key":
+		return key, true
+	case "modifiedBy":
+		return e.GetModifiedBy(), true
+	case "reason":
+		return e.GetReason(), true
+	case "value":
+		return entryValue(e), true
+	}
+	if strings.HasPrefix(field, "value.") {
+		return lookupPath(entryValue(e), strings.Split(strings.TrimPrefix(field, "value."), "."))
+	}
+	return nil, false
+}
+
+func compareOrdered[T float64 | string](a T, b T, op string) bool {
+	switch op {
+	case "==":
+		return a == b
+	case "!=":
+		return a != b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+	return false
+}
+
+// matches returns true if the entry fulfills the condition, a missing field never does
+func (c queryCondition) matches(key string, e StoreEntry) bool {
+	v, found := getField(key, e, c.field)
+	if !found || v == nil {
+		return false
+	}
+	switch lit := c.literal.(type) {
+	case bool:
+		b, ok := v.(bool)
+		return ok && (b == lit) == (c.op == "==")
+	case float64:
+		if c.op == "~" {
+			break
+		}
+		switch f := v.(type) {
+		case float64:
+			return compareOrdered(f, lit, c.op)
+		case string:
+			if parsed, err := strconv.ParseFloat(f, 64); err == nil {
+				return compareOrdered(parsed, lit, c.op)
+			}
+		}
+		return false
+	}
+	s, ok := v.(string)
+	if !ok {
+		b, _ := json.Marshal(v)
+		s = string(b)
+	}
+	litStr := fmt.Sprint(c.literal)
+	if c.op == "~" {
+		return strings.Contains(strings.ToLower(s), strings.ToLower(litStr))
+	}
+	return compareOrdered(s, litStr, c.op)
+}
+
+// before returns true if the entry a comes before the entry b in the sort order of the query
+func (q storeQuery) before(aKey string, aTime time.Time, bKey string, bTime time.Time) bool {
+	less := aKey < bKey
+	if q.sortBy == "timestamp" && !aTime.Equal(bTime) {
+		less = aTime.Before(bTime)
+	}
+	if q.descending {
+		return !less && aKey != bKey
+	}
+	return less
+}
+
+func makeQueryResultEntry(key string, e StoreEntry) QueryResultEntry {
+	return QueryResultEntry{Key: key, Timestamp: e.timestamp, ModifiedBy: e.GetModifiedBy(), Reason: e.GetReason(), Value: entryValue(e)}
+}
+
+// queryEntries evaluates the query on all entries of any namespace
+func queryEntries(nsStore StoreNamespace, q storeQuery) ([]QueryResultEntry, int, bool) {
+	type keyEntry struct {
+		key string
+		e   StoreEntry
+	}
+	matches := []keyEntry{}
+	for _, k := range nsStore.GetKeys() {
+		e := nsStore.GetValue(k)
+		if e == NotFoundEntry || e.IsError() {
+			continue
+		}
+		match := true
+		for _, c := range q.conditions {
+			if !c.matches(k, e) {
+				match = false
+				break
+			}
+		}
+		if match {
+			matches = append(matches, keyEntry{k, e})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return q.before(matches[i].key, matches[i].e.timestamp, matches[j].key, matches[j].e.timestamp)
+	})
+	total := len(matches)
+	if q.after != nil {
+		first := sort.Search(len(matches), func(i int) bool {
+			return q.before(q.after.Key, q.after.Timestamp, matches[i].key, matches[i].e.timestamp)
+		})
+		matches = matches[first:]
+	}
+	if q.offset >= len(matches) {
+		return []QueryResultEntry{}, total, false
+	}
+	matches = matches[q.offset:]
+	more := len(matches) > q.limit
+	if more {
+		matches = matches[:q.limit]
+	}
+	res := make([]QueryResultEntry, 0, len(matches))
+	for _, m := range matches {
+		res = append(res, makeQueryResultEntry(m.key, m.e))
+	}
+	return res, total, more
+}
+
+// unwrapNamespace returns the namespace that actually stores the entries
+func unwrapNamespace(nsStore StoreNamespace) StoreNamespace {
+	for {
+		switch w := nsStore.(type) {
+		case *triggeringStoreNamespace:
+			nsStore = w.StoreNamespace
+		case *historyStoreNamespace:
+			nsStore = w.StoreNamespace
+		default:
+			return nsStore
+		}
+	}
+}
+
+// QueryArgs are the arguments for the Query function
+type QueryArgs struct {
+	Namespace string
+	Filter    *string // conditions separated by "&&", e.g. `value.temperature > 20 && key ~ living`
+	Sort      *string // key or timestamp, prefixed with "-" for descending order; key if empty
+	Limit     *int    // 100 if not set
+	Offset    *int    // number of entries to skip after the cursor
+	Cursor    *string // cursor of the previous page
+	Fields    *string // comma-separated list of fields (key, modifiedBy, reason, value or value.<path>), the values of the result only contain these fields if set
+}
+
+// NamespaceSuggestions returns all namespaces
+func (p *QueryArgs) NamespaceSuggestions() []string {
+	return store.GetNamespaces()
+}
+
+// SortSuggestions returns the sort orders
+func (p *QueryArgs) SortSuggestions() []string {
+	return []string{"key", "-key", "timestamp", "-timestamp"}
+}
+
+// Query returns the entries of a namespace that match the filter, sorted and paginated; the filter is evaluated by the database for postgres namespaces
+func (o *OpStore) Query(ctx *base.Context, input *base.OperatorIO, args QueryArgs) *base.OperatorIO {
+	q := storeQuery{sortBy: "key", limit: 100}
+	var err error
+	if args.Filter != nil {
+		q.conditions, err = parseFilter(*args.Filter)
+		if err != nil {
+			return base.MakeOutputError(http.StatusBadRequest, "Invalid filter: %v", err)
+		}
+	}
+	if args.Sort != nil && *args.Sort != "" {
+		q.descending = strings.HasPrefix(*args.Sort, "-")
+		q.sortBy = strings.TrimPrefix(*args.Sort, "-")
+		if q.sortBy != "key" && q.sortBy != "timestamp" {
+			return base.MakeOutputError(http.StatusBadRequest, "Cannot sort by \"%v\"", q.sortBy)
+		}
+	}
+	if args.Limit != nil {
+		q.limit = *args.Limit
+	}
+	if args.Offset != nil {
+		q.offset = *args.Offset
+	}
+	if q.limit <= 0 || q.offset < 0 {
+		return base.MakeOutputError(http.StatusBadRequest, "Limit must be positive and offset must not be negative")
+	}
+	if args.Cursor != nil && *args.Cursor != "" {
+		b, err := base64.RawURLEncoding.DecodeString(*args.Cursor)
+		q.after = &queryCursor{}
+		if err == nil {
+			err = json.Unmarshal(b, q.after)
+		}
+		if err != nil {
+			return base.MakeOutputError(http.StatusBadRequest, "Invalid cursor: %v", err)
+		}
+	}
+
+	nsStore, err := store.GetNamespace(args.Namespace)
+	if err != nil {
+		return base.MakeOutputError(http.StatusBadRequest, "%v", err)
+	}
+	res := QueryResult{}
+	more := false
+	if qns, ok := unwrapNamespace(nsStore).(queryNamespace); ok {
+		res.Entries, res.Total, more, err = qns.runQuery(q)
+		if err != nil {
+			return base.MakeOutputError(http.StatusInternalServerError, "Query failed: %v", err)
+		}
+	} else {
+		res.Entries, res.Total, more = queryEntries(nsStore, q)
+	}
+
+	if more && len(res.Entries) > 0 {
+		last := res.Entries[len(res.Entries)-1]
+		b, _ := json.Marshal(queryCursor{Key: last.Key, Timestamp: last.Timestamp})
+		res.Cursor = base64.RawURLEncoding.EncodeToString(b)
+	}
+	if args.Fields != nil && *args.Fields != "" {
+		fields := strings.Split(*args.Fields, ",")
+		for i, r := range res.Entries {
+			e := StoreEntry{data: base.MakeObjectOutput(r.Value), timestamp: r.Timestamp}
+			projection := map[string]interface{}{}
+			for _, f := range fields {
+				f = strings.TrimSpace(f)
+				switch f {
+				case "modifiedBy":
+					projection[f] = r.ModifiedBy
+				case "reason":
+					projection[f] = r.Reason
+				default:
+					if v, found := getField(r.Key, e, f); found {
+						projection[f] = v
+					}
+				}
+			}
+			res.Entries[i].Value = projection
+		}
+	}
+	return base.MakeObjectOutput(res)
+}
@@ -0,0 +1,83 @@
+package freepsstore
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+	"gotest.tools/v3/assert"
+)
+
+func TestQuery(t *testing.T) {
+	s, ctx := prepareStore(t)
+
+	ns := store.GetNamespaceNoError("rooms")
+	for i, room := range []string{"kitchen", "living", "bath", "bedroom", "office"} {
+		ns.SetValue(room, base.MakeObjectOutput(map[string]interface{}{"temperature": 18 + i, "sensor": map[string]interface{}{"battery": i%2 == 0}}), ctx)
+		time.Sleep(time.Millisecond)
+	}
+	ns.SetValue("plain", base.MakePlainOutput("22"), ctx)
+
+	query := func(args map[string]string) QueryResult {
+		args["namespace"] = "rooms"
+		out := s.Execute(ctx, "query", base.NewFunctionArguments(args), base.MakeEmptyOutput())
+		assert.Assert(t, !out.IsError(), out.GetString())
+		res := QueryResult{}
+		assert.NilError(t, out.ParseJSON(&res))
+		return res
+	}
+	keys := func(res QueryResult) string {
+		k := []string{}
+		for _, e := range res.Entries {
+			k = append(k, e.Key)
+		}
+		return fmt.Sprint(k)
+	}
+
+	res := query(map[string]string{"filter": "value.temperature > 19"})
+	assert.Equal(t, keys(res), "[bath bedroom office]")
+	assert.Equal(t, res.Total, 3)
+	assert.Equal(t, res.Cursor, "")
+
+	res = query(map[string]string{"filter": "value.temperature >= 19 && value.sensor.battery == true", "sort": "-key"})
+	assert.Equal(t, keys(res), "[office bath]")
+	res = query(map[string]string{"filter": "value == 22"})
+	assert.Equal(t, keys(res), "[plain]")
+	res = query(map[string]string{"filter": "key ~ ROOM"})
+	assert.Equal(t, keys(res), "[bedroom]")
+	res = query(map[string]string{"filter": "modifiedBy == '" + ctx.GetID() + "' && value.temperature != 18"})
+	assert.Equal(t, res.Total, 4)
+
+	/* pages sorted by the time of modification */
+	res = query(map[string]string{"filter": "value.temperature < 100", "sort": "-timestamp", "limit": "2"})
+	assert.Equal(t, keys(res), "[office bedroom]")
+	assert.Equal(t, res.Total, 5)
+	assert.Assert(t, res.Cursor != "")
+	res = query(map[string]string{"filter": "value.temperature < 100", "sort": "-timestamp", "limit": "2", "cursor": res.Cursor})
+	assert.Equal(t, keys(res), "[bath living]")
+	res = query(map[string]string{"filter": "value.temperature < 100", "sort": "-timestamp", "limit": "2", "cursor": res.Cursor})
+	assert.Equal(t, keys(res), "[kitchen]")
+	assert.Equal(t, res.Cursor, "")
+	res = query(map[string]string{"sort": "timestamp", "limit": "2", "offset": "1"})
+	assert.Equal(t, keys(res), "[living bath]")
+
+	res = query(map[string]string{"filter": "key == kitchen", "fields": "value.temperature, key, value.unknown"})
+	assert.DeepEqual(t, res.Entries[0].Value, map[string]interface{}{"value.temperature": float64(18), "key": "kitchen"})
+
+	/* log namespaces */
+	logNs, err := store.CreateNamespace("roomLog", StoreNamespaceConfig{NamespaceType: "log"})
+	assert.NilError(t, err)
+	for i := 0; i < 12; i++ {
+		logNs.SetValue("", base.MakeObjectOutput(map[string]int{"value": i}), ctx)
+	}
+	out := s.Execute(ctx, "query", base.NewFunctionArguments(map[string]string{"namespace": "roomLog", "filter": "value.value >= 9", "sort": "-key"}), base.MakeEmptyOutput())
+	assert.NilError(t, out.ParseJSON(&res))
+	assert.Equal(t, keys(res), "[11 10 09]")
+
+	for _, invalid := range []map[string]string{{"filter": "temperature > 1"}, {"filter": "value.a"}, {"filter": "value.b > true"}, {"sort": "value"}, {"limit": "0"}, {"cursor": "!"}} {
+		invalid["namespace"] = "rooms"
+		out = s.Execute(ctx, "query", base.NewFunctionArguments(invalid), base.MakeEmptyOutput())
+		assert.Equal(t, out.HTTPCode, 400, invalid)
+	}
+}
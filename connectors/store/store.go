@@ -8,10 +8,11 @@ import (
 	"time"
 
 	"github.com/hannesrauhe/freeps/base"
+	"github.com/hannesrauhe/freeps/freepsflow"
 )
 
 // process-global store used by the Hook and the Operator
-var store = Store{namespaces: map[string]StoreNamespace{}}
+var store = Store{namespaces: map[string]StoreNamespace{}, persisted: map[string]*persistedNamespace{}, namespaceConfigs: map[string]StoreNamespaceConfig{}, evictions: map[string]EvictionStatistics{}, histories: map[string]*historyStoreNamespace{}}
 
 // StoreEntry contains data and metadata of a single entry
 type StoreEntry struct {
@@ -112,14 +113,16 @@ func (v StoreEntry) GetError() error {
 	}
 }
 
-// StoreNamespace defines all functions to retrieve and modify data in the store
+// StoreNamespace defines all functions to retrieve and modify data in the store;
+// if the namespace has triggers, the modifying functions execute the triggered flows synchronously before they return,
+// so a slow flow blocks the writer. DeleteValue, DeleteOlder and Trim never execute triggers, use DeleteValueWithContext to delete a key with triggers
 type StoreNamespace interface {
 	CompareAndSwap(key string, expected string, newValue *base.OperatorIO, modifiedBy *base.Context) StoreEntry
 	DeleteOlder(maxAge time.Duration) int
 	Trim(k int) int
 	DeleteValue(key string)
 	GetAllValues(limit int) map[string]*base.OperatorIO
-	GetKeys() []string
+	GetKeys() []string // all keys without a limit
 	Len() int
 	GetSearchResultWithMetadata(keyPattern string, valuePattern string, modifiedByPattern string, minAge time.Duration, maxAge time.Duration) map[string]StoreEntry
 	GetValue(key string) StoreEntry
@@ -135,6 +138,17 @@ type Store struct {
 	namespaces map[string]StoreNamespace
 	globalLock sync.Mutex
 	config     *StoreConfig
+	dataDir    string
+	persisted  map[string]*persistedNamespace
+
+	namespaceConfigs map[string]StoreNamespaceConfig
+	evictions        map[string]EvictionStatistics
+	histories        map[string]*historyStoreNamespace
+
+	ge                  *freepsflow.FlowEngine // executes the flows triggered by changes
+	triggerLock         sync.Mutex
+	triggeredNamespaces map[string]bool // namespaces watched by flows, nil if the flows changed since it was built
+	triggerGeneration   int
 }
 
 // CreateNamespace creates a new namespace in the store with the given name and config
@@ -157,6 +171,8 @@ func (s *Store) CreateNamespace(ns string, config StoreNamespaceConfig) (StoreNa
 		nsStore, err = newPostgresStoreNamespace(ns, config)
 	case "memory":
 		nsStore = newInMemoryStoreNamespace()
+	case "embedded":
+		nsStore, err = newEmbeddedStoreNamespace(s.getDataDir(), ns, config)
 	case "log":
 		nsStore = &logStoreNamespace{entries: []StoreEntry{}, offset: 0, nsLock: sync.Mutex{}, AutoTrim: config.AutoTrim}
 	case "null":
@@ -164,11 +180,27 @@ func (s *Store) CreateNamespace(ns string, config StoreNamespaceConfig) (StoreNa
 	default:
 		return nil, fmt.Errorf("Cannot create store namespace \"%v\", type \"%v\" is unknown", ns, config.NamespaceType)
 	}
+	if err == nil && config.PersistInterval > 0 {
+		snapshotNs, ok := nsStore.(snapshotNamespace)
+		if !ok {
+			return nil, fmt.Errorf("Cannot create store namespace \"%v\": type \"%v\" does not support snapshots", ns, config.NamespaceType)
+		}
+		err = s.enablePersistence(ns, snapshotNs, config)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("Cannot create store namespace \"%v\" of type \"%v\": %v", ns, config.NamespaceType, err)
 	}
 
+	if config.HistorySize > 0 {
+		h := newHistoryStoreNamespace(nsStore, config.HistorySize)
+		s.histories[ns] = h
+		nsStore = h
+	}
+	if config.Triggers {
+		nsStore = &triggeringStoreNamespace{StoreNamespace: nsStore, name: ns, s: s}
+	}
 	s.namespaces[ns] = nsStore
+	s.namespaceConfigs[ns] = config
 	return nsStore, nil
 }
 
@@ -183,6 +215,11 @@ func (s *Store) GetNamespaceNoError(ns string) StoreNamespace {
 
 // GetNamespaceNoError from the store, create InMemoryNamespace if it does not exist
 func (s *Store) GetNamespace(ns string) (StoreNamespace, error) {
+	return s.GetNamespaceWithDefault(ns, StoreNamespaceConfig{NamespaceType: "memory"})
+}
+
+// GetNamespaceWithDefault from the store, create it with defaultConfig if it does not exist and there is no config for it
+func (s *Store) GetNamespaceWithDefault(ns string, defaultConfig StoreNamespaceConfig) (StoreNamespace, error) {
 	// create new namespace on the fly from config is there is one
 	hasConfig := false
 	var namespaceConfig StoreNamespaceConfig
@@ -190,7 +227,7 @@ func (s *Store) GetNamespace(ns string) (StoreNamespace, error) {
 		namespaceConfig, hasConfig = s.config.Namespaces[ns]
 	}
 	if !hasConfig || namespaceConfig.NamespaceType == "" {
-		namespaceConfig = StoreNamespaceConfig{NamespaceType: "memory"}
+		namespaceConfig = defaultConfig
 	}
 	nsStore, err := s.CreateNamespace(ns, namespaceConfig)
 	if nsStore == nil {
@@ -199,6 +236,19 @@ func (s *Store) GetNamespace(ns string) (StoreNamespace, error) {
 	return nsStore, nil
 }
 
+// getNamespaceConfig returns the config of an existing namespace or the config the namespace will be created with
+func (s *Store) getNamespaceConfig(ns string) StoreNamespaceConfig {
+	s.globalLock.Lock()
+	defer s.globalLock.Unlock()
+	if config, ok := s.namespaceConfigs[ns]; ok {
+		return config
+	}
+	if s.config == nil {
+		return StoreNamespaceConfig{}
+	}
+	return s.config.Namespaces[ns]
+}
+
 // GetNamespaces returns all namespaces
 func (s *Store) GetNamespaces() []string {
 	s.globalLock.Lock()
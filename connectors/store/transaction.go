@@ -0,0 +1,285 @@
+package freepsstore
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/hannesrauhe/freeps/base"
+	"github.com/sirupsen/logrus"
+)
+
+// TransactionKey identifies a key in a namespace of the store
+type TransactionKey struct {
+	Namespace string
+	Key       string
+}
+
+// namespaceTransaction holds the locks for the keys of a single namespace until it is committed or rolled back
+type namespaceTransaction interface {
+	get(key string) StoreEntry
+	set(key string, io *base.OperatorIO, modifiedBy *base.Context) StoreEntry
+	commit() error
+	rollback()
+}
+
+// transactionalNamespace is implemented by namespaces that can modify multiple keys atomically
+type transactionalNamespace interface {
+	beginTransaction(keys []string) (namespaceTransaction, error)
+}
+
+var _ transactionalNamespace = &inMemoryStoreNamespace{}
+
+// inMemoryTransaction holds the lock of the namespace and remembers the entries it has overwritten
+type inMemoryTransaction struct {
+	ns   *inMemoryStoreNamespace
+	undo map[string]inMemoryUndoEntry
+}
+
+type inMemoryUndoEntry struct {
+	entry       StoreEntry
+	originalKey string
+}
+
+func (s *inMemoryStoreNamespace) beginTransaction(keys []string) (namespaceTransaction, error) {
+	s.nsLock.Lock()
+	return &inMemoryTransaction{ns: s, undo: map[string]inMemoryUndoEntry{}}, nil
+}
+
+func (t *inMemoryTransaction) get(key string) StoreEntry {
+	return t.ns.getValueUnlocked(key)
+}
+
+func (t *inMemoryTransaction) set(key string, io *base.OperatorIO, modifiedBy *base.Context) StoreEntry {
+	lk := strings.ToLower(key)
+	if _, exists := t.undo[lk]; !exists {
+		t.undo[lk] = inMemoryUndoEntry{entry: t.ns.getValueUnlocked(key), originalKey: t.ns.originalCaseKeys[lk]}
+	}
+	return t.ns.setValueUnlocked(key, io, modifiedBy)
+}
+
+func (t *inMemoryTransaction) commit() error {
+	t.ns.nsLock.Unlock()
+	return nil
+}
+
+func (t *inMemoryTransaction) rollback() {
+	for lk, u := range t.undo {
+		if u.entry == NotFoundEntry {
+			t.ns.deleteValueUnlocked(lk)
+		} else {
+			t.ns.entries[lk] = u.entry
+			t.ns.originalCaseKeys[lk] = u.originalKey
+		}
+	}
+	t.ns.nsLock.Unlock()
+}
+
+// notifyWrappers records the modification in the history and executes the triggers of the namespace after a transaction
+func notifyWrappers(nsStore StoreNamespace, key string, oldEntry StoreEntry, newEntry StoreEntry, modifiedBy *base.Context) {
+	for {
+		switch w := nsStore.(type) {
+		case *triggeringStoreNamespace:
+			if w.hasTriggers() {
+				w.executeTriggers(modifiedBy, key, "transaction", oldEntry, newEntry.data)
+			}
+			nsStore = w.StoreNamespace
+		case *historyStoreNamespace:
+			if oldEntry.IsError() {
+				oldEntry = NotFoundEntry
+			}
+			w.record(key, newEntry, oldEntry)
+			nsStore = w.StoreNamespace
+		default:
+			return
+		}
+	}
+}
+
+// SupportsTransactions returns true if the namespace can be part of a Transaction
+func (s *Store) SupportsTransactions(ns string) bool {
+	nsStore, err := s.GetNamespace(ns)
+	if err != nil {
+		return false
+	}
+	_, ok := unwrapNamespace(nsStore).(transactionalNamespace)
+	return ok
+}
+
+// Transaction reads all keys and passes their entries to fn, the values returned by fn are written all-or-nothing.
+// fn may only return values for the given keys, a nil value leaves the key unchanged; nothing is written if fn returns an error.
+// The keys are locked while fn is executed, so fn must not access the store itself.
+// Only memory and postgres namespaces support transactions, at most one of the namespaces may be a postgres namespace,
+// because the commits of two databases cannot be made atomic.
+func (s *Store) Transaction(keys []TransactionKey, fn func(entries map[TransactionKey]StoreEntry) (map[TransactionKey]*base.OperatorIO, error), modifiedBy *base.Context) error {
+	keysByNamespace := map[string][]string{}
+	for _, k := range keys {
+		keysByNamespace[k.Namespace] = append(keysByNamespace[k.Namespace], k.Key)
+	}
+	// namespaces and keys are always locked in the same order to prevent deadlocks
+	namespaces := make([]string, 0, len(keysByNamespace))
+	for ns := range keysByNamespace {
+		namespaces = append(namespaces, ns)
+		sort.Strings(keysByNamespace[ns])
+	}
+	sort.Strings(namespaces)
+
+	nsStores := map[string]StoreNamespace{}
+	tnsStores := map[string]transactionalNamespace{}
+	// the commit of a memory namespace cannot fail, the other namespace is committed first so that all can be rolled back if it fails
+	commitOrder := []string{}
+	for _, ns := range namespaces {
+		nsStore, err := s.GetNamespace(ns)
+		if err != nil {
+			return err
+		}
+		tns, ok := unwrapNamespace(nsStore).(transactionalNamespace)
+		if !ok {
+			return fmt.Errorf("Namespace \"%v\" does not support transactions", ns)
+		}
+		if _, isMemory := tns.(*inMemoryStoreNamespace); !isMemory {
+			if len(commitOrder) > 0 {
+				return fmt.Errorf("Namespaces \"%v\" and \"%v\" cannot be part of the same transaction, at most one may be stored outside of memory", commitOrder[0], ns)
+			}
+			commitOrder = append(commitOrder, ns)
+		}
+		nsStores[ns] = nsStore
+		tnsStores[ns] = tns
+	}
+	for _, ns := range namespaces {
+		if len(commitOrder) == 0 || commitOrder[0] != ns {
+			commitOrder = append(commitOrder, ns)
+		}
+	}
+
+	txs := map[string]namespaceTransaction{}
+	rollback := func() {
+		for _, tx := range txs {
+			tx.rollback()
+		}
+	}
+	for _, ns := range namespaces {
+		tx, err := tnsStores[ns].beginTransaction(keysByNamespace[ns])
+		if err != nil {
+			rollback()
+			return fmt.Errorf("Cannot start transaction in namespace \"%v\": %v", ns, err)
+		}
+		txs[ns] = tx
+	}
+
+	entries := map[TransactionKey]StoreEntry{}
+	for _, k := range keys {
+		e := txs[k.Namespace].get(k.Key)
+		if e != NotFoundEntry && e.IsError() {
+			rollback()
+			return fmt.Errorf("Cannot read key \"%v\" in namespace \"%v\": %v", k.Key, k.Namespace, e.GetError())
+		}
+		entries[k] = e
+	}
+
+	updates, err := fn(entries)
+	if err != nil {
+		rollback()
+		return err
+	}
+	written := map[TransactionKey]StoreEntry{}
+	for k, io := range updates {
+		if io == nil {
+			continue
+		}
+		if _, read := entries[k]; !read {
+			rollback()
+			return fmt.Errorf("Key \"%v\" in namespace \"%v\" is not part of the transaction", k.Key, k.Namespace)
+		}
+		e := txs[k.Namespace].set(k.Key, io, modifiedBy)
+		if e.IsError() {
+			rollback()
+			return fmt.Errorf("Cannot write key \"%v\" in namespace \"%v\": %v", k.Key, k.Namespace, e.GetError())
+		}
+		written[k] = e
+	}
+
+	// only the first commit can fail, the namespaces that have not been committed yet are rolled back then
+	for i, ns := range commitOrder {
+		if err := txs[ns].commit(); err != nil {
+			for _, other := range commitOrder[i+1:] {
+				txs[other].rollback()
+			}
+			return fmt.Errorf("Cannot commit transaction in namespace \"%v\": %v", ns, err)
+		}
+	}
+
+	if modifiedBy == nil {
+		modifiedBy = base.NewBaseContextWithReason(logrus.StandardLogger(), "store transaction")
+	}
+	for k, e := range written {
+		notifyWrappers(nsStores[k.Namespace], k.Key, entries[k], e, modifiedBy)
+	}
+	return nil
+}
+
+// TransactionInput describes the conditions and modifications of a transaction
+type TransactionInput struct {
+	Expect map[string]map[string]interface{} // namespace -> key -> expected value, null if the key must not exist
+	Set    map[string]map[string]interface{} // namespace -> key -> new value
+}
+
+// Transaction sets all values in the input atomically if all expected values match, it fails with 409 otherwise.
+// Only memory and postgres namespaces can be modified in a transaction, at most one of them may be a postgres namespace.
+func (o *OpStore) Transaction(ctx *base.Context, input *base.OperatorIO) *base.OperatorIO {
+	ti := TransactionInput{}
+	if err := input.ParseJSON(&ti); err != nil {
+		return base.MakeOutputError(http.StatusBadRequest, "Cannot parse transaction: %v", err)
+	}
+	keys := []TransactionKey{}
+	known := map[TransactionKey]bool{}
+	for _, m := range []map[string]map[string]interface{}{ti.Expect, ti.Set} {
+		for ns, values := range m {
+			for key := range values {
+				k := TransactionKey{Namespace: ns, Key: key}
+				if !known[k] {
+					known[k] = true
+					keys = append(keys, k)
+				}
+			}
+		}
+	}
+	if len(ti.Set) == 0 {
+		return base.MakeOutputError(http.StatusBadRequest, "Transaction does not set any value")
+	}
+
+	conflict := false
+	result := map[string]map[string]*base.OperatorIO{}
+	err := store.Transaction(keys, func(entries map[TransactionKey]StoreEntry) (map[TransactionKey]*base.OperatorIO, error) {
+		for ns, values := range ti.Expect {
+			for key, expected := range values {
+				e := entries[TransactionKey{Namespace: ns, Key: key}]
+				if expected == nil && e == NotFoundEntry {
+					continue
+				}
+				if expected == nil || e == NotFoundEntry || e.GetData().GetString() != base.MakeOutputGuessType(expected).GetString() {
+					conflict = true
+					return nil, fmt.Errorf("Value of key \"%v\" in namespace \"%v\" is different from expectation", key, ns)
+				}
+			}
+		}
+		updates := map[TransactionKey]*base.OperatorIO{}
+		for ns, values := range ti.Set {
+			result[ns] = map[string]*base.OperatorIO{}
+			for key, v := range values {
+				io := base.MakeOutputGuessType(v)
+				updates[TransactionKey{Namespace: ns, Key: key}] = io
+				result[ns][key] = io
+			}
+		}
+		return updates, nil
+	}, ctx)
+	if conflict {
+		return base.MakeOutputError(http.StatusConflict, "%v", err)
+	}
+	if err != nil {
+		return base.MakeOutputError(http.StatusInternalServerError, "%v", err)
+	}
+	return base.MakeObjectOutput(result)
+}
@@ -0,0 +1,136 @@
+package freepsstore
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/hannesrauhe/freeps/base"
+	"gotest.tools/v3/assert"
+)
+
+func TestTransaction(t *testing.T) {
+	s, ctx := prepareStore(t)
+
+	accounts := store.GetNamespaceNoError("accounts")
+	_, err := store.CreateNamespace("audit", StoreNamespaceConfig{NamespaceType: "memory", HistorySize: 5})
+	assert.NilError(t, err)
+	accounts.SetValue("alice", base.MakeIntegerOutput(100), ctx)
+	accounts.SetValue("bob", base.MakeIntegerOutput(0), ctx)
+
+	alice := TransactionKey{Namespace: "accounts", Key: "alice"}
+	bob := TransactionKey{Namespace: "accounts", Key: "bob"}
+	transfers := TransactionKey{Namespace: "audit", Key: "transfers"}
+	transfer := func(from TransactionKey, to TransactionKey, amount int64) error {
+		return store.Transaction([]TransactionKey{from, to, transfers}, func(entries map[TransactionKey]StoreEntry) (map[TransactionKey]*base.OperatorIO, error) {
+			fromBalance, _ := entries[from].GetData().GetInt64(true)
+			if fromBalance < amount {
+				return nil, fmt.Errorf("insufficient funds")
+			}
+			toBalance, _ := entries[to].GetData().GetInt64(true)
+			count, _ := entries[transfers].GetData().GetInt64(true)
+			return map[TransactionKey]*base.OperatorIO{
+				from:      base.MakeIntegerOutput(fromBalance - amount),
+				to:        base.MakeIntegerOutput(toBalance + amount),
+				transfers: base.MakeIntegerOutput(count + 1),
+			}, nil
+		}, ctx)
+	}
+
+	assert.NilError(t, transfer(alice, bob, 30))
+	assert.Equal(t, accounts.GetValue("alice").GetData().GetString(), "70")
+	assert.Equal(t, accounts.GetValue("bob").GetData().GetString(), "30")
+	history, err := store.GetHistory("audit", "transfers")
+	assert.NilError(t, err)
+	assert.Equal(t, len(history), 1)
+
+	/* nothing is written if fn fails or tries to write a key that was not read */
+	assert.ErrorContains(t, transfer(bob, alice, 31), "insufficient funds")
+	err = store.Transaction([]TransactionKey{alice}, func(entries map[TransactionKey]StoreEntry) (map[TransactionKey]*base.OperatorIO, error) {
+		return map[TransactionKey]*base.OperatorIO{alice: base.MakeIntegerOutput(0), bob: base.MakeIntegerOutput(0)}, nil
+	}, ctx)
+	assert.ErrorContains(t, err, "not part of the transaction")
+	assert.Equal(t, accounts.GetValue("alice").GetData().GetString(), "70")
+	assert.Equal(t, accounts.GetValue("bob").GetData().GetString(), "30")
+
+	/* concurrent transactions in both directions do not lose updates */
+	wg := sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() { defer wg.Done(); assert.Check(t, transfer(alice, bob, 1) == nil) }()
+		go func() { defer wg.Done(); assert.Check(t, transfer(bob, alice, 1) == nil) }()
+	}
+	wg.Wait()
+	assert.Equal(t, accounts.GetValue("alice").GetData().GetString(), "70")
+	assert.Equal(t, accounts.GetValue("bob").GetData().GetString(), "30")
+	assert.Equal(t, store.GetNamespaceNoError("audit").GetValue("transfers").GetData().GetString(), "101")
+
+	_, err = store.CreateNamespace("transactionLog", StoreNamespaceConfig{NamespaceType: "log"})
+	assert.NilError(t, err)
+	err = store.Transaction([]TransactionKey{alice, {Namespace: "transactionLog", Key: "0"}}, func(entries map[TransactionKey]StoreEntry) (map[TransactionKey]*base.OperatorIO, error) {
+		return nil, nil
+	}, ctx)
+	assert.ErrorContains(t, err, "does not support transactions")
+	/* the locks of the other namespaces have been released */
+	accounts.SetValue("carol", base.MakeIntegerOutput(0), ctx)
+	assert.Assert(t, store.SupportsTransactions("accounts"))
+	assert.Assert(t, !store.SupportsTransactions("transactionLog"))
+
+	/* a failing commit does not leave the memory namespaces modified */
+	store.globalLock.Lock()
+	store.namespaces["database1"] = &failingCommitNamespace{newInMemoryStoreNamespace()}
+	store.namespaces["database2"] = &failingCommitNamespace{newInMemoryStoreNamespace()}
+	store.globalLock.Unlock()
+	setAll := func(keys ...TransactionKey) error {
+		return store.Transaction(keys, func(entries map[TransactionKey]StoreEntry) (map[TransactionKey]*base.OperatorIO, error) {
+			updates := map[TransactionKey]*base.OperatorIO{}
+			for _, k := range keys {
+				updates[k] = base.MakeIntegerOutput(0)
+			}
+			return updates, nil
+		}, ctx)
+	}
+	assert.ErrorContains(t, setAll(alice, TransactionKey{Namespace: "database1", Key: "alice"}), "commit failed")
+	assert.Equal(t, accounts.GetValue("alice").GetData().GetString(), "70")
+	assert.ErrorContains(t, setAll(TransactionKey{Namespace: "database1", Key: "alice"}, TransactionKey{Namespace: "database2", Key: "alice"}), "cannot be part of the same transaction")
+	accounts.SetValue("carol", base.MakeIntegerOutput(0), ctx)
+
+	/* flow-level transaction */
+	out := s.Execute(ctx, "transaction", base.MakeEmptyFunctionArguments(), base.MakeObjectOutput(TransactionInput{
+		Expect: map[string]map[string]interface{}{"accounts": {"alice": 70, "dave": nil}},
+		Set:    map[string]map[string]interface{}{"accounts": {"alice": 60, "dave": 10}},
+	}))
+	assert.Assert(t, !out.IsError(), out.GetString())
+	assert.Equal(t, accounts.GetValue("alice").GetData().GetString(), "60")
+	assert.Equal(t, accounts.GetValue("dave").GetData().GetString(), "10")
+
+	out = s.Execute(ctx, "transaction", base.MakeEmptyFunctionArguments(), base.MakeObjectOutput(TransactionInput{
+		Expect: map[string]map[string]interface{}{"accounts": {"alice": 70}},
+		Set:    map[string]map[string]interface{}{"accounts": {"alice": 50}, "audit": {"transfers": 0}},
+	}))
+	assert.Equal(t, out.HTTPCode, 409)
+	assert.Equal(t, accounts.GetValue("alice").GetData().GetString(), "60")
+	assert.Equal(t, store.GetNamespaceNoError("audit").GetValue("transfers").GetData().GetString(), "101")
+
+	out = s.Execute(ctx, "transaction", base.MakeEmptyFunctionArguments(), base.MakePlainOutput("{}"))
+	assert.Equal(t, out.HTTPCode, 400)
+}
+
+// failingCommitNamespace is a transactional namespace that is not kept in memory and cannot commit
+type failingCommitNamespace struct {
+	*inMemoryStoreNamespace
+}
+
+type failingCommitTransaction struct {
+	namespaceTransaction
+}
+
+func (f *failingCommitNamespace) beginTransaction(keys []string) (namespaceTransaction, error) {
+	tx, err := f.inMemoryStoreNamespace.beginTransaction(keys)
+	return &failingCommitTransaction{tx}, err
+}
+
+func (t *failingCommitTransaction) commit() error {
+	t.rollback()
+	return fmt.Errorf("commit failed")
+}
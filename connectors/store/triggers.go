@@ -0,0 +1,232 @@
+package freepsstore
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+	"github.com/sirupsen/logrus"
+)
+
+// storeTriggerChainKey is the key of the context value that holds all keys whose triggers are currently executed in this execution tree
+type storeTriggerChainKey struct{}
+
+// triggeringStoreNamespace executes the flows tagged with "store" after a value in the wrapped namespace has been changed
+type triggeringStoreNamespace struct {
+	StoreNamespace
+	name string
+	s    *Store
+}
+
+var _ StoreNamespace = &triggeringStoreNamespace{}
+
+// hasTriggers returns true if any flow might be triggered by a change in this namespace
+func (t *triggeringStoreNamespace) hasTriggers() bool {
+	triggered := t.s.getTriggeredNamespaces()
+	return triggered[t.name] || triggered["*"]
+}
+
+// getTriggeredNamespaces returns the namespaces that flows tagged with "store" are watching, "*" if a flow watches all namespaces
+func (s *Store) getTriggeredNamespaces() map[string]bool {
+	if s.ge == nil {
+		return map[string]bool{}
+	}
+	s.triggerLock.Lock()
+	triggered := s.triggeredNamespaces
+	generation := s.triggerGeneration
+	s.triggerLock.Unlock()
+	if triggered != nil {
+		return triggered
+	}
+
+	triggered = map[string]bool{}
+	for _, ns := range s.ge.GetTagValues("storeNamespace", "store") {
+		triggered[ns] = true
+	}
+	s.triggerLock.Lock()
+	// the flows might have changed in the meantime, the next call will look at them again
+	if generation == s.triggerGeneration {
+		s.triggeredNamespaces = triggered
+	}
+	s.triggerLock.Unlock()
+	return triggered
+}
+
+// invalidateTriggers makes the next write look at the tags of the flows again, it has to be called whenever flows change
+func (s *Store) invalidateTriggers() {
+	s.triggerLock.Lock()
+	s.triggeredNamespaces = nil
+	s.triggerGeneration++
+	s.triggerLock.Unlock()
+}
+
+// executeTriggers executes all flows that are tagged with the namespace and a pattern that matches the key
+func (t *triggeringStoreNamespace) executeTriggers(ctx *base.Context, key string, operation string, oldEntry StoreEntry, newValue *base.OperatorIO) {
+	if ctx == nil {
+		ctx = base.NewBaseContextWithReason(logrus.StandardLogger(), "store trigger")
+	}
+	// loop protection: a flow that is triggered by a key must not trigger the flows of this key again
+	chainKey := t.name + "/" + key
+	chain, _ := ctx.Value(storeTriggerChainKey{}).(map[string]bool)
+	if chain[chainKey] {
+		ctx.GetLogger().Debugf("Not executing store triggers for %v again", chainKey)
+		return
+	}
+	newChain := map[string]bool{chainKey: true}
+	for k := range chain {
+		newChain[k] = true
+	}
+	ctx = ctx.ChildContextWithValue(storeTriggerChainKey{}, newChain)
+
+	keySelectTags := []string{"storeKey:*", "storeKey:" + key}
+	for _, pattern := range t.s.ge.GetTagValues("storeKey", "store") {
+		if match, _ := path.Match(pattern, key); match {
+			keySelectTags = append(keySelectTags, "storeKey:"+pattern)
+		}
+	}
+	tagGroups := [][]string{{"store"}, {"storeNamespace:" + t.name, "storeNamespace:*"}, keySelectTags}
+
+	oldValue := ""
+	if oldEntry != NotFoundEntry && oldEntry.data != nil {
+		oldValue = oldEntry.data.GetString()
+	}
+	args := base.NewFunctionArguments(map[string]string{"namespace": t.name, "key": key, "operation": operation, "oldValue": oldValue, "newValue": newValue.GetString()})
+	t.s.ge.ExecuteFlowByTagsExtended(ctx, tagGroups, args, newValue)
+}
+
+// SetValue in the wrapped StoreNamespace and execute the triggers
+func (t *triggeringStoreNamespace) SetValue(key string, io *base.OperatorIO, modifiedBy *base.Context) StoreEntry {
+	if !t.hasTriggers() {
+		return t.StoreNamespace.SetValue(key, io, modifiedBy)
+	}
+	oldEntry := t.StoreNamespace.GetValue(key)
+	e := t.StoreNamespace.SetValue(key, io, modifiedBy)
+	if e.data == io {
+		t.executeTriggers(modifiedBy, key, "set", oldEntry, io)
+	}
+	return e
+}
+
+// CompareAndSwap in the wrapped StoreNamespace and execute the triggers if the value was swapped
+func (t *triggeringStoreNamespace) CompareAndSwap(key string, expected string, newValue *base.OperatorIO, modifiedBy *base.Context) StoreEntry {
+	if !t.hasTriggers() {
+		return t.StoreNamespace.CompareAndSwap(key, expected, newValue, modifiedBy)
+	}
+	e := t.StoreNamespace.CompareAndSwap(key, expected, newValue, modifiedBy)
+	if e.data == newValue {
+		t.executeTriggers(modifiedBy, key, "compareAndSwap", MakeEntry(base.MakePlainOutput(expected), nil), newValue)
+	}
+	return e
+}
+
+// UpdateTransaction in the wrapped StoreNamespace and execute the triggers if the value was updated
+func (t *triggeringStoreNamespace) UpdateTransaction(key string, fn func(StoreEntry) *base.OperatorIO, modifiedBy *base.Context) StoreEntry {
+	if !t.hasTriggers() {
+		return t.StoreNamespace.UpdateTransaction(key, fn, modifiedBy)
+	}
+	var oldEntry StoreEntry
+	var newValue *base.OperatorIO
+	e := t.StoreNamespace.UpdateTransaction(key, func(old StoreEntry) *base.OperatorIO {
+		oldEntry = old
+		newValue = fn(old)
+		return newValue
+	}, modifiedBy)
+	if newValue != nil && e.data == newValue && !newValue.IsError() && newValue.HTTPCode != http.StatusContinue {
+		t.executeTriggers(modifiedBy, key, "updateTransaction", oldEntry, newValue)
+	}
+	return e
+}
+
+// OverwriteValueIfOlder in the wrapped StoreNamespace and execute the triggers if the value was overwritten
+func (t *triggeringStoreNamespace) OverwriteValueIfOlder(key string, io *base.OperatorIO, maxAge time.Duration, modifiedBy *base.Context) StoreEntry {
+	if !t.hasTriggers() {
+		return t.StoreNamespace.OverwriteValueIfOlder(key, io, maxAge, modifiedBy)
+	}
+	oldEntry := t.StoreNamespace.GetValue(key)
+	e := t.StoreNamespace.OverwriteValueIfOlder(key, io, maxAge, modifiedBy)
+	if e.data == io {
+		t.executeTriggers(modifiedBy, key, "set", oldEntry, io)
+	}
+	return e
+}
+
+// DeleteValueWithContext deletes the key from the wrapped StoreNamespace and executes the triggers if the key existed
+func (t *triggeringStoreNamespace) DeleteValueWithContext(key string, modifiedBy *base.Context) {
+	if !t.hasTriggers() {
+		t.StoreNamespace.DeleteValue(key)
+		return
+	}
+	oldEntry := t.StoreNamespace.GetValue(key)
+	t.StoreNamespace.DeleteValue(key)
+	if oldEntry != NotFoundEntry {
+		t.executeTriggers(modifiedBy, key, "delete", oldEntry, base.MakeEmptyOutput())
+	}
+}
+
+// DeleteValueWithContext deletes the key from the namespace, unlike DeleteValue it executes the triggers of the namespace
+// because the context of the caller allows to detect flows that trigger themselves
+func DeleteValueWithContext(nsStore StoreNamespace, key string, modifiedBy *base.Context) {
+	if t, ok := nsStore.(*triggeringStoreNamespace); ok {
+		t.DeleteValueWithContext(key, modifiedBy)
+		return
+	}
+	nsStore.DeleteValue(key)
+}
+
+// FlowIDSuggestions returns suggestions for flow names
+func (o *OpStore) FlowIDSuggestions() map[string]string {
+	flowNames := map[string]string{}
+	res := o.GE.GetAllFlowDesc()
+	for id, gd := range res {
+		info, _ := gd.GetCompleteDesc(id, o.GE)
+		_, exists := flowNames[info.DisplayName]
+		if !exists {
+			flowNames[info.DisplayName] = id
+		} else {
+			flowNames[fmt.Sprintf("%v (ID: %v)", info.DisplayName, id)] = id
+		}
+	}
+	return flowNames
+}
+
+// SetStoreTriggerArgs are the arguments for the SetStoreTrigger function
+type SetStoreTriggerArgs struct {
+	FlowID    string
+	Namespace *string // all namespaces if empty
+	Key       *string // glob pattern as in path.Match, all keys if empty
+}
+
+// SetStoreTrigger adds the tags to the flow that make it execute whenever a matching key is changed in the store
+func (o *OpStore) SetStoreTrigger(ctx *base.Context, input *base.OperatorIO, args SetStoreTriggerArgs) *base.OperatorIO {
+	tags := []string{"store"}
+	if args.Namespace != nil && *args.Namespace != "" {
+		if !store.getNamespaceConfig(*args.Namespace).Triggers {
+			return base.MakeOutputError(http.StatusBadRequest, "Namespace \"%v\" does not execute triggers, enable Triggers in its config", *args.Namespace)
+		}
+		tags = append(tags, "storeNamespace:"+*args.Namespace)
+	} else {
+		tags = append(tags, "storeNamespace:*")
+	}
+
+	if args.Key != nil && *args.Key != "" {
+		if _, err := path.Match(*args.Key, ""); err != nil {
+			return base.MakeOutputError(http.StatusBadRequest, "Invalid key pattern \"%v\": %v", *args.Key, err)
+		}
+		tags = append(tags, "storeKey:"+*args.Key)
+	} else {
+		tags = append(tags, "storeKey:*")
+	}
+
+	gd, found := o.GE.GetFlowDesc(args.FlowID)
+	if !found {
+		return base.MakeOutputError(http.StatusNotFound, "Couldn't find flow: %v", args.FlowID)
+	}
+	gd.AddTags(tags...)
+	err := o.GE.AddFlow(ctx, args.FlowID, *gd, true)
+	if err != nil {
+		return base.MakeOutputError(http.StatusInternalServerError, "Cannot modify flow: %v", err)
+	}
+	return base.MakeEmptyOutput()
+}
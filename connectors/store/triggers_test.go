@@ -0,0 +1,118 @@
+package freepsstore_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/hannesrauhe/freeps/base"
+	freepsstore "github.com/hannesrauhe/freeps/connectors/store"
+	"github.com/hannesrauhe/freeps/freepsd/helper"
+	"github.com/hannesrauhe/freeps/freepsflow"
+	"gotest.tools/v3/assert"
+)
+
+func TestStoreTriggers(t *testing.T) {
+	ctx, ge, _ := helper.SetupEngineWithCommonOperators(t, nil)
+	op := ge.GetOperator("store")
+
+	/* stores the arguments it was called with */
+	err := ge.AddFlow(ctx, "onTemperature", freepsflow.FlowDesc{Operations: []freepsflow.FlowOperationDesc{
+		{Operator: "utils", Function: "echoArguments", UseMainArgs: true},
+		{Operator: "store", Function: "set", InputFrom: "#0", Arguments: map[string]string{"namespace": "triggered", "key": "onTemperature"}},
+	}}, false)
+	assert.NilError(t, err)
+	/* modifies the key that triggered it */
+	err = ge.AddFlow(ctx, "onCounter", freepsflow.FlowDesc{Operations: []freepsflow.FlowOperationDesc{
+		{Operator: "store", Function: "increment", Arguments: map[string]string{"namespace": "triggered", "key": "onCounter"}},
+		{Operator: "store", Function: "increment", Arguments: map[string]string{"namespace": "watched", "key": "counter"}},
+	}}, false)
+	assert.NilError(t, err)
+
+	s := freepsstore.GetGlobalStore()
+	/* namespaces execute triggers only if they are configured to */
+	out := op.Execute(ctx, "setStoreTrigger", base.NewFunctionArguments(map[string]string{"flowID": "onTemperature", "namespace": "watched", "key": "temp*"}), base.MakeEmptyOutput())
+	assert.Equal(t, out.GetStatusCode(), http.StatusBadRequest)
+	watched, err := s.CreateNamespace("watched", freepsstore.StoreNamespaceConfig{NamespaceType: "memory", Triggers: true})
+	assert.NilError(t, err)
+	triggered := s.GetNamespaceNoError("triggered")
+	/* the flows are looked at again after they changed */
+	watched.SetValue("temperature", base.MakePlainOutput("19"), ctx)
+	out = op.Execute(ctx, "setStoreTrigger", base.NewFunctionArguments(map[string]string{"flowID": "onTemperature", "namespace": "watched", "key": "temp*"}), base.MakeEmptyOutput())
+	assert.Assert(t, !out.IsError(), out.GetString())
+	out = op.Execute(ctx, "setStoreTrigger", base.NewFunctionArguments(map[string]string{"flowID": "onCounter", "namespace": "watched", "key": "counter"}), base.MakeEmptyOutput())
+	assert.Assert(t, !out.IsError(), out.GetString())
+	out = op.Execute(ctx, "setStoreTrigger", base.NewFunctionArguments(map[string]string{"flowID": "onCounter", "namespace": "_execution_log"}), base.MakeEmptyOutput())
+	assert.Assert(t, out.IsError())
+	out = op.Execute(ctx, "setStoreTrigger", base.NewFunctionArguments(map[string]string{"flowID": "unknown"}), base.MakeEmptyOutput())
+	assert.Assert(t, out.IsError())
+
+	/* only matching keys trigger the flow */
+	watched.SetValue("humidity", base.MakePlainOutput("50"), ctx)
+	assert.Equal(t, triggered.Len(), 0)
+	watched.SetValue("temperature", base.MakePlainOutput("20"), ctx)
+	watched.SetValue("temperature", base.MakePlainOutput("21"), ctx)
+	args := map[string]string{}
+	assert.NilError(t, triggered.GetValue("onTemperature").ParseJSON(&args))
+	assert.Equal(t, args["namespace"], "watched")
+	assert.Equal(t, args["key"], "temperature")
+	assert.Equal(t, args["operation"], "set")
+	assert.Equal(t, args["oldValue"], "20")
+	assert.Equal(t, args["newValue"], "21")
+
+	watched.UpdateTransaction("temperature", func(old freepsstore.StoreEntry) *base.OperatorIO {
+		return base.MakePlainOutput("22")
+	}, ctx)
+	assert.NilError(t, triggered.GetValue("onTemperature").ParseJSON(&args))
+	assert.Equal(t, args["operation"], "updateTransaction")
+	assert.Equal(t, args["oldValue"], "21")
+	assert.Equal(t, args["newValue"], "22")
+
+	watched.CompareAndSwap("temperature", "22", base.MakePlainOutput("23"), ctx)
+	assert.NilError(t, triggered.GetValue("onTemperature").ParseJSON(&args))
+	assert.Equal(t, args["operation"], "compareAndSwap")
+	assert.Equal(t, args["newValue"], "23")
+
+	/* deleting without the context of the caller does not execute triggers, because loops could not be detected */
+	watched.SetValue("temperature2", base.MakePlainOutput("1"), ctx)
+	watched.DeleteValue("temperature2")
+	assert.NilError(t, triggered.GetValue("onTemperature").ParseJSON(&args))
+	assert.Equal(t, args["operation"], "set")
+	out = op.Execute(ctx, "del", base.NewFunctionArguments(map[string]string{"namespace": "watched", "key": "temperature"}), base.MakeEmptyOutput())
+	assert.Assert(t, !out.IsError(), out.GetString())
+	assert.NilError(t, triggered.GetValue("onTemperature").ParseJSON(&args))
+	assert.Equal(t, args["operation"], "delete")
+	assert.Equal(t, args["oldValue"], "23")
+
+	/* the flow that modifies its own key is only triggered once */
+	watched.SetValue("counter", base.MakeIntegerOutput(0), ctx)
+	assert.Equal(t, triggered.GetValue("onCounter").GetData().GetString(), "1")
+	assert.Equal(t, watched.GetValue("counter").GetData().GetString(), "1")
+	watched.SetValue("counter", base.MakeIntegerOutput(10), ctx)
+	assert.Equal(t, triggered.GetValue("onCounter").GetData().GetString(), "2")
+	assert.Equal(t, watched.GetValue("counter").GetData().GetString(), "11")
+
+	/* the flow that deletes its own key is only triggered once */
+	err = ge.AddFlow(ctx, "onDelete", freepsflow.FlowDesc{Operations: []freepsflow.FlowOperationDesc{
+		{Operator: "store", Function: "increment", Arguments: map[string]string{"namespace": "triggered", "key": "onDelete"}},
+		{Operator: "store", Function: "set", Arguments: map[string]string{"namespace": "watched", "key": "deleteMe", "value": "again"}},
+		{Operator: "store", Function: "del", Arguments: map[string]string{"namespace": "watched", "key": "deleteMe"}},
+	}}, false)
+	assert.NilError(t, err)
+	out = op.Execute(ctx, "setStoreTrigger", base.NewFunctionArguments(map[string]string{"flowID": "onDelete", "namespace": "watched", "key": "deleteMe"}), base.MakeEmptyOutput())
+	assert.Assert(t, !out.IsError(), out.GetString())
+	watched.SetValue("deleteMe", base.MakePlainOutput("x"), ctx)
+	assert.Equal(t, triggered.GetValue("onDelete").GetData().GetString(), "1")
+	assert.Equal(t, watched.GetValue("deleteMe"), freepsstore.NotFoundEntry)
+
+	/* keys deleted by an import in replace mode execute the triggers */
+	exp, err := s.Export([]string{"watched"})
+	assert.NilError(t, err)
+	watched.SetValue("temperature3", base.MakePlainOutput("24"), ctx)
+	_, err = s.Import(ctx, exp, true)
+	assert.NilError(t, err)
+	assert.Equal(t, watched.GetValue("temperature3"), freepsstore.NotFoundEntry)
+	assert.NilError(t, triggered.GetValue("onTemperature").ParseJSON(&args))
+	assert.Equal(t, args["key"], "temperature3")
+	assert.Equal(t, args["operation"], "delete")
+	assert.Equal(t, args["oldValue"], "24")
+}
@@ -2,13 +2,29 @@ package optime
 
 import (
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/hannesrauhe/freeps/base"
+	"github.com/hannesrauhe/freeps/freepsflow"
 	"github.com/keep94/sunrise"
 )
 
+// OpTime provides functions around the current time and executes flows tagged with sun events like "sun:sunrise+15m"
 type OpTime struct {
+	GE       *freepsflow.FlowEngine
+	config   *TimeConfig
+	ticker   *time.Ticker
+	nextRuns map[string]time.Time // sun event tag value -> next execution
+	lock     sync.Mutex
+}
+
+// TimeConfig is the config for the time operator
+type TimeConfig struct {
+	// Location is used to compute the time of sun events for triggers
+	Location GeoLocation
+	// SunTriggersEnabled starts a loop that executes flows tagged with "sun:<event>"
+	SunTriggersEnabled bool
 }
 
 // SunriseOutput is a struct to hold the sunrise information
@@ -22,7 +38,21 @@ type SunriseOutput struct {
 	Until     time.Duration
 }
 
-var _ base.FreepsOperator = &OpTime{}
+var _ base.FreepsOperatorWithConfig = &OpTime{}
+var _ base.FreepsOperatorWithShutdown = &OpTime{}
+
+var sunTriggerCheckInterval = 10 * time.Second
+
+// GetDefaultConfig returns the default config for the time operator
+func (o *OpTime) GetDefaultConfig() interface{} {
+	return &TimeConfig{Location: GeoLocation{}, SunTriggersEnabled: false}
+}
+
+// InitCopyOfOperator creates a copy of the operator
+func (o *OpTime) InitCopyOfOperator(ctx *base.Context, config interface{}, name string) (base.FreepsOperatorWithConfig, error) {
+	opc := config.(*TimeConfig)
+	return &OpTime{GE: o.GE, config: opc, nextRuns: map[string]time.Time{}}, nil
+}
 
 // GeoLocation is a struct to hold latitude and longitude
 type GeoLocation struct {
@@ -0,0 +1,120 @@
+package optime
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// sunEventElevations maps the supported events to the elevation of the sun in degrees, dawn and dusk are the begin and end of civil twilight
+var sunEventElevations = map[string]float64{
+	"dawn":    -6,
+	"sunrise": -0.83,
+	"noon":    0,
+	"sunset":  -0.83,
+	"dusk":    -6,
+}
+
+// sunEvent is a point in time relative to the position of the sun, e.g. "sunset-30m"
+type sunEvent struct {
+	Event  string
+	Offset time.Duration
+}
+
+func (s sunEvent) String() string {
+	if s.Offset == 0 {
+		return s.Event
+	}
+	sign := "+"
+	offset := s.Offset
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	// "15m" instead of "15m0s"
+	str := offset.String()
+	if strings.HasSuffix(str, "m0s") {
+		str = strings.TrimSuffix(str, "0s")
+	}
+	if strings.HasSuffix(str, "h0m") {
+		str = strings.TrimSuffix(str, "0m")
+	}
+	return s.Event + sign + str
+}
+
+// parseSunEvent parses strings like "sunrise", "sunrise+15m" or "sunset-1h30m"
+func parseSunEvent(str string) (sunEvent, error) {
+	str = strings.ToLower(strings.TrimSpace(str))
+	s := sunEvent{Event: str}
+	i := strings.IndexAny(str, "+-")
+	if i >= 0 {
+		s.Event = str[:i]
+		d, err := time.ParseDuration(str[i+1:])
+		if err != nil {
+			return s, fmt.Errorf("Invalid offset in \"%v\": %v", str, err)
+		}
+		if str[i] == '-' {
+			d = -d
+		}
+		s.Offset = d
+	}
+	if _, ok := sunEventElevations[s.Event]; !ok {
+		return s, fmt.Errorf("Unknown sun event \"%v\", must be one of dawn, sunrise, noon, sunset, dusk", s.Event)
+	}
+	return s, nil
+}
+
+const (
+	julianEpoch = 2451545.0
+	unixEpoch   = int64(946728000)
+)
+
+func degSin(degrees float64) float64 { return math.Sin(degrees * math.Pi / 180.0) }
+func degCos(degrees float64) float64 { return math.Cos(degrees * math.Pi / 180.0) }
+func degAsin(x float64) float64      { return math.Asin(x) * 180.0 / math.Pi }
+func degAcos(x float64) float64      { return math.Acos(x) * 180.0 / math.Pi }
+
+// sunEventOnDay computes the time of the event (without offset) on the calendar day of t in the location of t,
+// returns false if the sun does not reach the elevation on that day (polar day or night)
+func sunEventOnDay(g GeoLocation, event string, t time.Time) (time.Time, bool) {
+	noon := time.Date(t.Year(), t.Month(), t.Day(), 12, 0, 0, 0, t.Location())
+	jd := float64(noon.Unix()-unixEpoch)/86400.0 + julianEpoch
+	jstar := math.Floor(jd-0.0009+g.Longitude/360.0+0.5) + 0.0009 - g.Longitude/360.0
+
+	ma := math.Mod(357.5291+0.98560028*(jstar-julianEpoch), 360)
+	center := 1.9148*degSin(ma) + 0.02*degSin(2.0*ma) + 0.0003*degSin(3.0*ma)
+	el := math.Mod(ma+102.9372+center+180.0, 360)
+	solarNoon := jstar + 0.0053*degSin(ma) - 0.0069*degSin(2.0*el)
+	declination := degAsin(degSin(el) * degSin(23.45))
+
+	eventJD := solarNoon
+	if event != "noon" {
+		x := (degSin(sunEventElevations[event]) - degSin(g.Latitude)*degSin(declination)) / (degCos(g.Latitude) * degCos(declination))
+		if x < -1 || x > 1 {
+			return time.Time{}, false
+		}
+		hourAngle := degAcos(x) / 360.0
+		if event == "dawn" || event == "sunrise" {
+			eventJD -= hourAngle
+		} else {
+			eventJD += hourAngle
+		}
+	}
+	return time.Unix(unixEpoch+int64((eventJD-julianEpoch)*86400.0), 0).In(t.Location()), true
+}
+
+// next returns the first occurrence of the event after t, or false if it does not occur within the next year
+func (s sunEvent) next(g GeoLocation, t time.Time) (time.Time, bool) {
+	for day := -1; day <= 366; day++ {
+		et, ok := sunEventOnDay(g, s.Event, t.AddDate(0, 0, day))
+		if !ok {
+			continue
+		}
+		et = et.Add(s.Offset)
+		if et.After(t) {
+			return et, true
+		}
+	}
+	return time.Time{}, false
+}
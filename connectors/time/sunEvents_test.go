@@ -0,0 +1,89 @@
+package optime
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+	freepsutils "github.com/hannesrauhe/freeps/connectors/utils"
+	"github.com/hannesrauhe/freeps/freepsflow"
+	"github.com/hannesrauhe/freeps/utils"
+	"github.com/keep94/sunrise"
+	"github.com/sirupsen/logrus"
+	"gotest.tools/v3/assert"
+)
+
+var berlin = GeoLocation{Latitude: 52.52, Longitude: 13.405}
+
+func TestSunEvents(t *testing.T) {
+	loc := time.FixedZone("CEST", 2*60*60)
+	day := time.Date(2024, 6, 21, 8, 0, 0, 0, loc)
+
+	var s sunrise.Sunrise
+	s.Around(berlin.Latitude, berlin.Longitude, day)
+	rise, ok := sunEventOnDay(berlin, "sunrise", day)
+	assert.Assert(t, ok)
+	assert.Assert(t, rise.Sub(s.Sunrise()).Abs() < time.Minute, "%v vs %v", rise, s.Sunrise())
+	set, ok := sunEventOnDay(berlin, "sunset", day)
+	assert.Assert(t, ok)
+	assert.Assert(t, set.Sub(s.Sunset()).Abs() < time.Minute, "%v vs %v", set, s.Sunset())
+
+	prev := time.Time{}
+	for _, ev := range []string{"dawn", "sunrise", "noon", "sunset", "dusk"} {
+		et, ok := sunEventOnDay(berlin, ev, day)
+		assert.Assert(t, ok)
+		assert.Assert(t, et.After(prev), ev)
+		assert.Equal(t, et.Day(), day.Day(), ev)
+		prev = et
+	}
+
+	// no dusk during polar day
+	_, ok = sunEventOnDay(GeoLocation{Latitude: 78.2, Longitude: 15.6}, "dusk", day)
+	assert.Assert(t, !ok)
+
+	ev, err := parseSunEvent("Sunset-1h30m")
+	assert.NilError(t, err)
+	assert.Equal(t, ev, sunEvent{Event: "sunset", Offset: -90 * time.Minute})
+	assert.Equal(t, ev.String(), "sunset-1h30m")
+	next, ok := ev.next(berlin, day)
+	assert.Assert(t, ok)
+	assert.Equal(t, next, set.Add(-90*time.Minute))
+	next, ok = ev.next(berlin, set)
+	assert.Assert(t, ok)
+	assert.Equal(t, next.Day(), 22)
+
+	for _, invalid := range []string{"sunrize", "sunrise+", "sunrise+15x", ""} {
+		_, err = parseSunEvent(invalid)
+		assert.Assert(t, err != nil, invalid)
+	}
+}
+
+func TestSunTriggers(t *testing.T) {
+	ctx := base.NewBaseContextWithReason(logrus.StandardLogger(), "")
+	cr, err := utils.NewConfigReader(logrus.StandardLogger(), path.Join(t.TempDir(), "test_config.json"))
+	assert.NilError(t, err)
+	ge := freepsflow.NewFlowEngine(ctx, cr, func() {})
+	ge.AddOperators(base.MakeFreepsOperators(&freepsutils.OpUtils{}, cr, ctx))
+
+	opI, err := (&OpTime{GE: ge}).InitCopyOfOperator(ctx, &TimeConfig{Location: berlin, SunTriggersEnabled: true}, "time")
+	assert.NilError(t, err)
+	op := opI.(*OpTime)
+
+	err = ge.AddFlow(ctx, "testflow", freepsflow.FlowDesc{Operations: []freepsflow.FlowOperationDesc{{Operator: "utils", Function: "echo"}}}, false)
+	assert.NilError(t, err)
+	assert.Assert(t, op.SetSunTrigger(ctx, base.MakeEmptyOutput(), SunTrigger{FlowID: "testflow", Event: "moonrise"}).IsError())
+	offset := 15 * time.Minute
+	out := op.SetSunTrigger(ctx, base.MakeEmptyOutput(), SunTrigger{FlowID: "testflow", Event: "sunrise", Offset: &offset})
+	assert.Assert(t, !out.IsError(), out.GetString())
+	fd, _ := ge.GetFlowDesc("testflow")
+	assert.Assert(t, fd.HasAtLeastOneTag([]string{"sun:sunrise+15m"}))
+
+	day := time.Date(2024, 6, 21, 0, 0, 0, 0, time.Local)
+	rise, _ := sunEventOnDay(berlin, "sunrise", day)
+	assert.Equal(t, len(op.checkSunTriggers(ctx, day)), 0)
+	assert.Equal(t, len(op.checkSunTriggers(ctx, rise)), 0)
+	assert.Equal(t, len(op.checkSunTriggers(ctx, rise.Add(offset))), 1)
+	assert.Equal(t, len(op.checkSunTriggers(ctx, rise.Add(offset+time.Minute))), 0)
+	assert.Equal(t, op.nextRuns["sunrise+15m"].Day(), rise.AddDate(0, 0, 1).Day())
+}
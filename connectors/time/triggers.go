@@ -0,0 +1,147 @@
+package optime
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+)
+
+// FlowIDSuggestions returns suggestions for flow names
+func (o *OpTime) FlowIDSuggestions() map[string]string {
+	flowNames := map[string]string{}
+	if o.GE == nil {
+		return flowNames
+	}
+	res := o.GE.GetAllFlowDesc()
+	for id, gd := range res {
+		info, _ := gd.GetCompleteDesc(id, o.GE)
+		_, exists := flowNames[info.DisplayName]
+		if !exists {
+			flowNames[info.DisplayName] = id
+		} else {
+			flowNames[fmt.Sprintf("%v (ID: %v)", info.DisplayName, id)] = id
+		}
+	}
+	return flowNames
+}
+
+// SunTrigger are the arguments for SetSunTrigger
+type SunTrigger struct {
+	FlowID string
+	Event  string
+	Offset *time.Duration
+}
+
+// EventSuggestions returns the supported sun events
+func (arg *SunTrigger) EventSuggestions() []string {
+	return []string{"dawn", "sunrise", "noon", "sunset", "dusk"}
+}
+
+// SetSunTrigger executes the given flow every day at the time of the sun event plus the offset
+func (o *OpTime) SetSunTrigger(ctx *base.Context, input *base.OperatorIO, args SunTrigger) *base.OperatorIO {
+	ev, err := parseSunEvent(args.Event)
+	if err != nil {
+		return base.MakeOutputError(http.StatusBadRequest, "%v", err)
+	}
+	if args.Offset != nil {
+		ev.Offset = *args.Offset
+	}
+
+	gd, found := o.GE.GetFlowDesc(args.FlowID)
+	if !found {
+		return base.MakeOutputError(http.StatusInternalServerError, "Couldn't find flow: %v", args.FlowID)
+	}
+	gd.AddTags("sun:" + ev.String())
+	err = o.GE.AddFlow(ctx, args.FlowID, *gd, true)
+	if err != nil {
+		return base.MakeOutputError(http.StatusInternalServerError, "Cannot modify flow: %v", err)
+	}
+	return base.MakeEmptyOutput()
+}
+
+// SunEventTime is the next execution of a sun trigger
+type SunEventTime struct {
+	Trigger string
+	NextRun time.Time
+}
+
+// GetNextSunTriggers returns the next execution times of all sun triggers
+func (o *OpTime) GetNextSunTriggers(ctx *base.Context, input *base.OperatorIO) *base.OperatorIO {
+	res := []SunEventTime{}
+	now := time.Now()
+	for _, tagValue := range o.GE.GetTagValues("sun") {
+		ev, err := parseSunEvent(tagValue)
+		if err != nil {
+			continue
+		}
+		next, ok := ev.next(o.config.Location, now)
+		if ok {
+			res = append(res, SunEventTime{Trigger: "sun:" + tagValue, NextRun: next})
+		}
+	}
+	return base.MakeObjectOutput(res)
+}
+
+// checkSunTriggers executes flows for all sun events that have passed since the last check
+func (o *OpTime) checkSunTriggers(initCtx *base.Context, now time.Time) []string {
+	executed := []string{}
+	o.lock.Lock()
+	newNextRuns := map[string]time.Time{}
+	for _, tagValue := range o.GE.GetTagValues("sun") {
+		ev, err := parseSunEvent(tagValue)
+		if err != nil {
+			initCtx.GetLogger().Errorf("Invalid sun trigger \"%v\": %v", tagValue, err)
+			continue
+		}
+		next, ok := o.nextRuns[tagValue]
+		if !ok {
+			next, ok = ev.next(o.config.Location, now)
+			if !ok {
+				continue
+			}
+		}
+		if !next.After(now) {
+			executed = append(executed, tagValue)
+			next, ok = ev.next(o.config.Location, now)
+			if !ok {
+				continue
+			}
+		}
+		newNextRuns[tagValue] = next
+	}
+	o.nextRuns = newNextRuns
+	o.lock.Unlock()
+
+	for _, tagValue := range executed {
+		ctx := base.CreateContextWithField(initCtx, "component", "time", fmt.Sprintf("Sun event \"%v\"", tagValue))
+		args := base.NewFunctionArguments(map[string]string{"sunEvent": tagValue})
+		go o.GE.ExecuteFlowByTags(ctx, []string{"sun:" + tagValue}, args, base.MakeEmptyOutput())
+	}
+	return executed
+}
+
+func (o *OpTime) loop(initCtx *base.Context, ticker *time.Ticker) {
+	for now := range ticker.C {
+		o.checkSunTriggers(initCtx, now)
+	}
+}
+
+// StartListening starts the loop that executes sun triggers if enabled
+func (o *OpTime) StartListening(ctx *base.Context) {
+	if o.ticker != nil || o.config == nil || !o.config.SunTriggersEnabled {
+		return
+	}
+	o.ticker = time.NewTicker(sunTriggerCheckInterval)
+	go o.loop(ctx, o.ticker)
+}
+
+// Shutdown stops the loop
+func (o *OpTime) Shutdown(ctx *base.Context) {
+	if o.ticker == nil {
+		return
+	}
+	o.ticker.Stop()
+	o.ticker = nil
+}
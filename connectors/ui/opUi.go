@@ -242,6 +242,26 @@ func (o *OpUI) buildPartialFlow(formInput map[string]string) *freepsflow.FlowDes
 			gopd.ExecuteOnSuccessOf = v
 		} else if k == "executeOnFailOf" {
 			gopd.ExecuteOnFailOf = v
+		} else if k == "forEach" {
+			gopd.ForEach = v
+		} else if k == "condition" {
+			gopd.Condition = v
+		} else if k == "switchValue" || k == "switchDefault" || utils.StringStartsWith(k, "switchCase.") {
+			if gopd.Switch == nil {
+				gopd.Switch = &freepsflow.SwitchDesc{Cases: map[string]string{}}
+			}
+			if gopd.Switch.Cases == nil {
+				gopd.Switch.Cases = map[string]string{}
+			}
+			if k == "switchValue" {
+				gopd.Switch.Value = v
+			} else if k == "switchDefault" {
+				gopd.Switch.Default = v
+			} else if v == "" {
+				delete(gopd.Switch.Cases, k[len("switchCase."):])
+			} else {
+				gopd.Switch.Cases[k[len("switchCase."):]] = v
+			}
 		} else if k == "useMainArgs" {
 			gopd.UseMainArgs = utils.ParseBool(v)
 		} else if k == "opName" && len(v) > 0 && !utils.StringStartsWith(v, "#") {
@@ -254,6 +274,13 @@ func (o *OpUI) buildPartialFlow(formInput map[string]string) *freepsflow.FlowDes
 		}
 	}
 
+	if caseValue := formInput["newSwitchCaseValue"]; caseValue != "" && formInput["newSwitchCaseTarget"] != "" && gopd.Switch != nil {
+		gopd.Switch.Cases[caseValue] = formInput["newSwitchCaseTarget"]
+	}
+	if v, ok := formInput["switchValue"]; ok && v == "" {
+		gopd.Switch = nil
+	}
+
 	/* modify operation list: adding and deleting */
 
 	if newOp, ok := formInput["newOp"]; ok {
@@ -94,6 +94,13 @@ func (o *OpUI) createTemplateFuncMap(ctx *base.Context) template.FuncMap {
 			v := ns.GetValue(key)
 			return v.GetData().GetString()
 		},
+		"store_GetHistory": func(namespace string, key string) []freepsstore.ReadableKeyVersion {
+			history, err := freepsstore.GetGlobalStore().GetHistory(namespace, key)
+			if err != nil {
+				return nil
+			}
+			return history
+		},
 		"ge_GetOperators": func() []string {
 			return o.ge.GetOperators()
 		},
@@ -143,6 +150,13 @@ func (o *OpUI) createTemplateFuncMap(ctx *base.Context) template.FuncMap {
 			}
 			return o.ge.ExecuteOperatorByName(ctx, op, fn, fa, base.MakeEmptyOutput())
 		},
+		"trace_GetTraces": func() []freepsflow.TraceSummary {
+			return o.ge.GetTraces()
+		},
+		"trace_GetWaterfall": func(traceID string) []waterfallRow {
+			spans, _ := o.ge.GetTrace(traceID)
+			return buildWaterfall(spans)
+		},
 		"flow_GetTagMap": func() map[string][]string {
 			return o.ge.GetTagMap()
 		},
@@ -184,6 +198,83 @@ func (o *OpUI) createTemplateFuncMap(ctx *base.Context) template.FuncMap {
 			}
 			return argSugg
 		},
+		"sparkline": func(values []float64) template.HTML {
+			return buildSparkline(values, 120, 24)
+		},
 	}
 	return funcMap
 }
+
+// buildSparkline draws the values as a small inline SVG line that is scaled to the range of the values
+func buildSparkline(values []float64, width int, height int) template.HTML {
+	if len(values) < 2 {
+		return ""
+	}
+	min, max := values[0], values[0]
+	for _, v := range values {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+	points := make([]string, len(values))
+	for i, v := range values {
+		y := float64(height) / 2
+		if max > min {
+			y = float64(height) - (v-min)/(max-min)*float64(height)
+		}
+		points[i] = fmt.Sprintf("%.1f,%.1f", float64(i)*float64(width)/float64(len(values)-1), y)
+	}
+	return template.HTML(fmt.Sprintf(`<svg width="%d" height="%d" viewBox="-1 -1 %d %d"><title>%v - %v</title><polyline fill="none" stroke="currentColor" stroke-width="1" points="%s"/></svg>`, width, height, width+2, height+2, min, max, strings.Join(points, " ")))
+}
+
+// waterfallRow is a span with its position in the waterfall diagram of a trace
+type waterfallRow struct {
+	freepsflow.Span
+	Depth         int
+	OffsetPercent float64
+	WidthPercent  float64
+}
+
+// buildWaterfall orders the spans depth-first below their parents and computes the position of the bars relative to the whole trace
+func buildWaterfall(spans []freepsflow.Span) []waterfallRow {
+	rows := []waterfallRow{}
+	if len(spans) == 0 {
+		return rows
+	}
+	start := spans[0].Start
+	end := spans[0].End
+	children := map[string][]freepsflow.Span{}
+	known := map[string]bool{}
+	for _, s := range spans {
+		known[s.SpanID] = true
+		if s.Start.Before(start) {
+			start = s.Start
+		}
+		if s.End.After(end) {
+			end = s.End
+		}
+	}
+	for _, s := range spans {
+		parent := s.ParentSpanID
+		if !known[parent] {
+			parent = ""
+		}
+		children[parent] = append(children[parent], s)
+	}
+	total := float64(end.Sub(start))
+	if total <= 0 {
+		total = 1
+	}
+
+	var addRows func(parent string, depth int)
+	addRows = func(parent string, depth int) {
+		for _, s := range children[parent] {
+			offset := float64(s.Start.Sub(start)) / total * 100
+			// keep very short spans visible
+			width := math.Min(math.Max(float64(s.Duration())/total*100, 0.5), 100-offset)
+			rows = append(rows, waterfallRow{Span: s, Depth: depth, OffsetPercent: offset, WidthPercent: width})
+			addRows(s.SpanID, depth+1)
+		}
+	}
+	addRows("", 0)
+	return rows
+}
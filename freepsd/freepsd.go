@@ -20,6 +20,7 @@ import (
 	"github.com/hannesrauhe/freeps/connectors/mqtt"
 	"github.com/hannesrauhe/freeps/connectors/muteme"
 	"github.com/hannesrauhe/freeps/connectors/pixeldisplay"
+	opschedule "github.com/hannesrauhe/freeps/connectors/schedule"
 	"github.com/hannesrauhe/freeps/connectors/sensor"
 	"github.com/hannesrauhe/freeps/connectors/smtp"
 	freepsstore "github.com/hannesrauhe/freeps/connectors/store"
@@ -106,7 +107,8 @@ func mainLoop() bool {
 		&telegram.OpTelegram{GE: ge},
 		&pixeldisplay.OpPixelDisplay{},
 		&opconfig.OpConfig{CR: cr, GE: ge},
-		&optime.OpTime{},
+		&optime.OpTime{GE: ge},
+		&opschedule.OpSchedule{CR: cr, GE: ge},
 		&fritz.OpFritz{CR: cr, GE: ge},
 		&mqtt.OpMQTT{CR: cr, GE: ge},
 		&weather.OpWeather{},
@@ -23,13 +23,13 @@ func SetupEngineWithCommonOperators(t *testing.T, configSections map[string]inte
 	cr, err := utils.NewConfigReader(logrus.StandardLogger(), path.Join(tdir, "test_config.json"))
 	assert.NilError(t, err)
 	ctx := base.NewBaseContextWithReason(logrus.StandardLogger(), "")
-	ge := freepsflow.NewFlowEngine(ctx, cr, func() {})
-
 	if configSections != nil {
 		for sectionName, configSection := range configSections {
 			cr.WriteSection(sectionName, configSection, false)
 		}
 	}
+	ge := freepsflow.NewFlowEngine(ctx, cr, func() {})
+
 	availableOperators := []base.FreepsOperator{
 		&freepsstore.OpStore{CR: cr, GE: ge}, // must be first so that other operators can use the store
 		&opalert.OpAlert{CR: cr, GE: ge},     // must be second so that other operators can use alerts
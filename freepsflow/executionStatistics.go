@@ -0,0 +1,105 @@
+package freepsflow
+
+import (
+	"strings"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+)
+
+// LatencyBuckets are the upper bounds in seconds of the latency histograms of flows and operations
+var LatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// OperationKey identifies the function of an operator in the execution statistics, both names are lower case
+type OperationKey struct {
+	Operator string
+	Function string
+}
+
+// ExecutionStatistics counts the executions of a flow or operation and their latency
+type ExecutionStatistics struct {
+	Executions     uint64
+	ErrorsByCode   map[int]uint64
+	LatencyBuckets []uint64 // cumulative number of executions that finished within the corresponding LatencyBuckets bound
+	LatencySum     float64  // total time spent in all executions in seconds
+}
+
+func newExecutionStatistics() *ExecutionStatistics {
+	return &ExecutionStatistics{ErrorsByCode: map[int]uint64{}, LatencyBuckets: make([]uint64, len(LatencyBuckets))}
+}
+
+func (s *ExecutionStatistics) record(output *base.OperatorIO, duration time.Duration) {
+	s.Executions++
+	if output.IsError() {
+		s.ErrorsByCode[output.HTTPCode]++
+	}
+	seconds := duration.Seconds()
+	s.LatencySum += seconds
+	for i, bound := range LatencyBuckets {
+		if seconds <= bound {
+			s.LatencyBuckets[i]++
+		}
+	}
+}
+
+func (s *ExecutionStatistics) copy() ExecutionStatistics {
+	c := ExecutionStatistics{Executions: s.Executions, ErrorsByCode: make(map[int]uint64, len(s.ErrorsByCode)), LatencyBuckets: make([]uint64, len(s.LatencyBuckets)), LatencySum: s.LatencySum}
+	for code, n := range s.ErrorsByCode {
+		c.ErrorsByCode[code] = n
+	}
+	copy(c.LatencyBuckets, s.LatencyBuckets)
+	return c
+}
+
+// recordFlowExecution adds a finished flow execution to the statistics, skipped executions are not counted
+func (ge *FlowEngine) recordFlowExecution(flowID string, output *base.OperatorIO, duration time.Duration) {
+	if output.IsSkipped() {
+		return
+	}
+	ge.statisticsLock.Lock()
+	defer ge.statisticsLock.Unlock()
+	s, exists := ge.flowStatistics[flowID]
+	if !exists {
+		s = newExecutionStatistics()
+		ge.flowStatistics[flowID] = s
+	}
+	s.record(output, duration)
+}
+
+// recordOperationExecution adds a finished operation to the statistics, skipped operations and switches are not counted
+func (ge *FlowEngine) recordOperationExecution(opDesc *FlowOperationDesc, output *base.OperatorIO, duration time.Duration) {
+	if output.IsSkipped() || opDesc.Switch != nil {
+		return
+	}
+	key := OperationKey{Operator: strings.ToLower(opDesc.Operator), Function: strings.ToLower(opDesc.Function)}
+	ge.statisticsLock.Lock()
+	defer ge.statisticsLock.Unlock()
+	s, exists := ge.operationStatistics[key]
+	if !exists {
+		s = newExecutionStatistics()
+		ge.operationStatistics[key] = s
+	}
+	s.record(output, duration)
+}
+
+// GetFlowStatistics returns a copy of the execution statistics of all flows that were executed since the start
+func (ge *FlowEngine) GetFlowStatistics() map[string]ExecutionStatistics {
+	ge.statisticsLock.Lock()
+	defer ge.statisticsLock.Unlock()
+	r := make(map[string]ExecutionStatistics, len(ge.flowStatistics))
+	for flowID, s := range ge.flowStatistics {
+		r[flowID] = s.copy()
+	}
+	return r
+}
+
+// GetOperationStatistics returns a copy of the execution statistics of all operator functions that were executed since the start
+func (ge *FlowEngine) GetOperationStatistics() map[OperationKey]ExecutionStatistics {
+	ge.statisticsLock.Lock()
+	defer ge.statisticsLock.Unlock()
+	r := make(map[OperationKey]ExecutionStatistics, len(ge.operationStatistics))
+	for key, s := range ge.operationStatistics {
+		r[key] = s.copy()
+	}
+	return r
+}
@@ -0,0 +1,958 @@
+package freepsflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/hannesrauhe/freeps/base"
+	"github.com/hannesrauhe/freeps/utils"
+)
+
+// ExpressionVariables resolves the identifiers used in an expression, values can be operator outputs, FunctionArguments or plain values
+type ExpressionVariables func(name string) (interface{}, bool)
+
+// errVariableNotFound is wrapped by all errors caused by missing variables, so "??" can provide a default
+var errVariableNotFound = errors.New("variable not found")
+
+type notFoundError struct {
+	msg string
+}
+
+func (e *notFoundError) Error() string { return e.msg }
+func (e *notFoundError) Unwrap() error { return errVariableNotFound }
+
+func makeNotFoundError(format string, a ...interface{}) error {
+	return &notFoundError{msg: fmt.Sprintf(format, a...)}
+}
+
+// EvaluateExpression parses and evaluates an expression like `devices.list[0].name`, `temp * 1.8 + 32`, `upper(x ?? "off")` or `_args.foo == "bar"`
+func EvaluateExpression(expr string, vars ExpressionVariables) (interface{}, error) {
+	node, err := parseExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	return node.eval(vars)
+}
+
+// ExpressionIdentifiers returns all identifiers used in an expression, or nil if the expression cannot be parsed
+func ExpressionIdentifiers(expr string) []string {
+	tokens, err := tokenizeExpression(expr)
+	if err != nil {
+		return nil
+	}
+	idents := []string{}
+	for i, t := range tokens {
+		// skip member names and function names
+		if t.kind != tokenIdent || (i > 0 && tokens[i-1].text == ".") || (i+1 < len(tokens) && tokens[i+1].text == "(") {
+			continue
+		}
+		idents = append(idents, t.text)
+	}
+	return idents
+}
+
+// ExpressionValueToString converts the result of an expression to the string that is used for argument substitution
+func ExpressionValueToString(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case bool:
+		return strconv.FormatBool(t)
+	case float64:
+		// drop binary rounding noise like 69.80000000000001
+		rounded, _ := strconv.ParseFloat(strconv.FormatFloat(t, 'g', 15, 64), 64)
+		return strconv.FormatFloat(rounded, 'f', -1, 64)
+	case *base.OperatorIO:
+		return t.GetString()
+	case base.FunctionArguments:
+		b, _ := json.Marshal(t.GetOriginalCaseMapJoined())
+		return string(b)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
+
+// ExpressionValueToBool interprets the result of an expression as a boolean
+func ExpressionValueToBool(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	case float64:
+		return t != 0
+	case string:
+		if b, err := utils.ConvertToBool(t); err == nil {
+			return b
+		}
+		return t != ""
+	case *base.OperatorIO:
+		if t.IsError() || t.IsEmpty() {
+			return false
+		}
+		if t.OutputType == base.Integer || t.OutputType == base.FloatingPoint {
+			f, err := toNumber(t)
+			return err == nil && f != 0
+		}
+		if t.OutputType == base.Object {
+			if b, ok := t.Output.(bool); ok {
+				return b
+			}
+			return true
+		}
+		return ExpressionValueToBool(t.GetString())
+	}
+	return true
+}
+
+// ExpressionValueToOutput converts the result of an expression to an OperatorIO
+func ExpressionValueToOutput(v interface{}) *base.OperatorIO {
+	switch t := v.(type) {
+	case nil:
+		return base.MakeEmptyOutput()
+	case *base.OperatorIO:
+		return t
+	case bool:
+		return base.MakeObjectOutput(t)
+	case base.FunctionArguments:
+		return base.MakeObjectOutput(t.GetOriginalCaseMapJoined())
+	}
+	return base.MakeOutputInferType(v)
+}
+
+/* tokenizer */
+
+type tokenKind int
+
+const (
+	tokenIdent tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenOperator
+)
+
+type exprToken struct {
+	kind tokenKind
+	text string
+}
+
+var exprOperators = []string{"??", "==", "!=", "<=", ">=", "&&", "||", "<", ">", "+", "-", "*", "/", "%", "!", "(", ")", "[", "]", ".", ","}
+
+func isIdentStart(r rune) bool {
+	return unicode.IsLetter(r) || r == '_' || r == '#'
+}
+
+func isIdentPart(r rune) bool {
+	return isIdentStart(r) || unicode.IsDigit(r)
+}
+
+func tokenizeExpression(expr string) ([]exprToken, error) {
+	tokens := []exprToken{}
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case isIdentStart(r):
+			start := i
+			for i < len(runes) && isIdentPart(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: tokenIdent, text: string(runes[start:i])})
+		case unicode.IsDigit(r):
+			start := i
+			for i < len(runes) && unicode.IsDigit(runes[i]) {
+				i++
+			}
+			if i+1 < len(runes) && runes[i] == '.' && unicode.IsDigit(runes[i+1]) {
+				i++
+				for i < len(runes) && unicode.IsDigit(runes[i]) {
+					i++
+				}
+			}
+			tokens = append(tokens, exprToken{kind: tokenNumber, text: string(runes[start:i])})
+		case r == '"' || r == '\'':
+			quote := r
+			i++
+			var sb strings.Builder
+			closed := false
+			for i < len(runes) {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					sb.WriteRune(runes[i+1])
+					i += 2
+					continue
+				}
+				if runes[i] == quote {
+					closed = true
+					i++
+					break
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("Unterminated string in expression \"%s\"", expr)
+			}
+			tokens = append(tokens, exprToken{kind: tokenString, text: sb.String()})
+		default:
+			found := false
+			for _, op := range exprOperators {
+				if strings.HasPrefix(string(runes[i:]), op) {
+					tokens = append(tokens, exprToken{kind: tokenOperator, text: op})
+					i += len([]rune(op))
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("Unexpected character '%c' in expression \"%s\"", r, expr)
+			}
+		}
+	}
+	return tokens, nil
+}
+
+/* parser */
+
+type exprNode interface {
+	eval(vars ExpressionVariables) (interface{}, error)
+	String() string
+}
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+	expr   string
+}
+
+func parseExpression(expr string) (exprNode, error) {
+	tokens, err := tokenizeExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("Empty expression")
+	}
+	p := &exprParser{tokens: tokens, expr: expr}
+	node, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("Unexpected \"%s\" in expression \"%s\"", p.tokens[p.pos].text, expr)
+	}
+	return node, nil
+}
+
+func (p *exprParser) peek() *exprToken {
+	if p.pos >= len(p.tokens) {
+		return nil
+	}
+	return &p.tokens[p.pos]
+}
+
+func (p *exprParser) peekOperator(ops ...string) string {
+	t := p.peek()
+	if t == nil || t.kind != tokenOperator {
+		return ""
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return op
+		}
+	}
+	return ""
+}
+
+func (p *exprParser) expect(op string) error {
+	if p.peekOperator(op) == "" {
+		return fmt.Errorf("Expected \"%s\" in expression \"%s\"", op, p.expr)
+	}
+	p.pos++
+	return nil
+}
+
+// binary operators from lowest to highest precedence
+var exprPrecedence = [][]string{
+	{"??"},
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *exprParser) parseBinary(level int) (exprNode, error) {
+	if level >= len(exprPrecedence) {
+		return p.parseUnary()
+	}
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peekOperator(exprPrecedence[level]...)
+		if op == "" {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if op := p.peekOperator("!", "-"); op != "" {
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: op, operand: operand}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *exprParser) parsePostfix() (exprNode, error) {
+	node, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peekOperator(".", "[") {
+		case ".":
+			p.pos++
+			t := p.peek()
+			if t == nil || (t.kind != tokenIdent && t.kind != tokenNumber) {
+				return nil, fmt.Errorf("Expected a name after \".\" in expression \"%s\"", p.expr)
+			}
+			p.pos++
+			// "list.0.1" is tokenized as "list", ".", "0.1"
+			for _, key := range strings.Split(t.text, ".") {
+				node = &memberNode{target: node, key: key}
+			}
+		case "[":
+			p.pos++
+			index, err := p.parseBinary(0)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			node = &indexNode{target: node, index: index}
+		default:
+			return node, nil
+		}
+	}
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, fmt.Errorf("Unexpected end of expression \"%s\"", p.expr)
+	}
+	p.pos++
+	switch t.kind {
+	case tokenNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, err
+		}
+		return &literalNode{value: f}, nil
+	case tokenString:
+		return &literalNode{value: t.text}, nil
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null", "nil":
+			return &literalNode{value: nil}, nil
+		}
+		if p.peekOperator("(") != "" {
+			p.pos++
+			return p.parseCall(t.text)
+		}
+		return &identNode{name: t.text}, nil
+	}
+	if t.text == "(" {
+		node, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+	return nil, fmt.Errorf("Unexpected \"%s\" in expression \"%s\"", t.text, p.expr)
+}
+
+func (p *exprParser) parseCall(name string) (exprNode, error) {
+	fn, ok := expressionFunctions[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("Unknown function \"%s\" in expression \"%s\"", name, p.expr)
+	}
+	call := &callNode{name: name, fn: fn, args: []exprNode{}}
+	if p.peekOperator(")") != "" {
+		p.pos++
+		return call, nil
+	}
+	for {
+		arg, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+		if p.peekOperator(",") != "" {
+			p.pos++
+			continue
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return call, nil
+	}
+}
+
+/* evaluation */
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) eval(vars ExpressionVariables) (interface{}, error) { return n.value, nil }
+func (n *literalNode) String() string                                     { return ExpressionValueToString(n.value) }
+
+type identNode struct {
+	name string
+}
+
+func (n *identNode) eval(vars ExpressionVariables) (interface{}, error) {
+	v, ok := vars(n.name)
+	if !ok {
+		return nil, makeNotFoundError("Output \"%s\" not found", n.name)
+	}
+	return v, nil
+}
+func (n *identNode) String() string { return n.name }
+
+type memberNode struct {
+	target exprNode
+	key    string
+}
+
+func (n *memberNode) eval(vars ExpressionVariables) (interface{}, error) {
+	v, err := n.target.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	return getMember(v, n.key, n.target.String())
+}
+func (n *memberNode) String() string { return n.target.String() + "." + n.key }
+
+type indexNode struct {
+	target exprNode
+	index  exprNode
+}
+
+func (n *indexNode) eval(vars ExpressionVariables) (interface{}, error) {
+	v, err := n.target.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	i, err := n.index.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	if f, ok := i.(float64); ok {
+		return getIndex(v, int(f), n.target.String())
+	}
+	return getMember(v, ExpressionValueToString(i), n.target.String())
+}
+func (n *indexNode) String() string { return n.target.String() + "[" + n.index.String() + "]" }
+
+type unaryNode struct {
+	op      string
+	operand exprNode
+}
+
+func (n *unaryNode) eval(vars ExpressionVariables) (interface{}, error) {
+	v, err := n.operand.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "!" {
+		return !ExpressionValueToBool(v), nil
+	}
+	f, err := toNumber(v)
+	if err != nil {
+		return nil, err
+	}
+	return -f, nil
+}
+func (n *unaryNode) String() string { return n.op + n.operand.String() }
+
+type binaryNode struct {
+	op    string
+	left  exprNode
+	right exprNode
+}
+
+func (n *binaryNode) String() string { return n.left.String() + " " + n.op + " " + n.right.String() }
+
+func (n *binaryNode) eval(vars ExpressionVariables) (interface{}, error) {
+	left, err := n.left.eval(vars)
+	switch n.op {
+	case "??":
+		if err != nil && !errors.Is(err, errVariableNotFound) {
+			return nil, err
+		}
+		if err != nil || isNullValue(left) {
+			return n.right.eval(vars)
+		}
+		return left, nil
+	case "&&":
+		if err != nil {
+			return nil, err
+		}
+		if !ExpressionValueToBool(left) {
+			return false, nil
+		}
+		right, err := n.right.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		return ExpressionValueToBool(right), nil
+	case "||":
+		if err != nil {
+			return nil, err
+		}
+		if ExpressionValueToBool(left) {
+			return true, nil
+		}
+		right, err := n.right.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		return ExpressionValueToBool(right), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	lf, lerr := toNumber(left)
+	rf, rerr := toNumber(right)
+	bothNumbers := lerr == nil && rerr == nil
+	switch n.op {
+	case "+":
+		if bothNumbers {
+			return lf + rf, nil
+		}
+		return ExpressionValueToString(left) + ExpressionValueToString(right), nil
+	case "-", "*", "/", "%":
+		if !bothNumbers {
+			return nil, fmt.Errorf("Operator \"%s\" needs numbers, got \"%s\" and \"%s\"", n.op, ExpressionValueToString(left), ExpressionValueToString(right))
+		}
+		switch n.op {
+		case "-":
+			return lf - rf, nil
+		case "*":
+			return lf * rf, nil
+		case "/":
+			if rf == 0 {
+				return nil, fmt.Errorf("Division by zero in \"%s\"", n.String())
+			}
+			return lf / rf, nil
+		default:
+			if rf == 0 {
+				return nil, fmt.Errorf("Division by zero in \"%s\"", n.String())
+			}
+			return math.Mod(lf, rf), nil
+		}
+	}
+
+	// comparisons are numeric if both sides are numbers, otherwise strings are compared
+	cmp := 0
+	if bothNumbers {
+		if lf < rf {
+			cmp = -1
+		} else if lf > rf {
+			cmp = 1
+		}
+	} else {
+		if lb, ok := left.(bool); ok {
+			if rb, ok := right.(bool); ok && (n.op == "==" || n.op == "!=") {
+				return (lb == rb) == (n.op == "=="), nil
+			}
+		}
+		cmp = strings.Compare(ExpressionValueToString(left), ExpressionValueToString(right))
+	}
+	switch n.op {
+	case "==":
+		return cmp == 0, nil
+	case "!=":
+		return cmp != 0, nil
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	case ">=":
+		return cmp >= 0, nil
+	}
+	return nil, fmt.Errorf("Unknown operator \"%s\"", n.op)
+}
+
+type callNode struct {
+	name string
+	fn   func(args []interface{}) (interface{}, error)
+	args []exprNode
+}
+
+func (n *callNode) eval(vars ExpressionVariables) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, a := range n.args {
+		v, err := a.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	r, err := n.fn(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", n.name, err)
+	}
+	return r, nil
+}
+
+func (n *callNode) String() string {
+	args := make([]string, len(n.args))
+	for i, a := range n.args {
+		args[i] = a.String()
+	}
+	return n.name + "(" + strings.Join(args, ", ") + ")"
+}
+
+func isNullValue(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	if io, ok := v.(*base.OperatorIO); ok {
+		return io.IsEmpty() || io.IsError()
+	}
+	return false
+}
+
+// toNumber converts v to a finite number, NaN and infinity are rejected because they cannot be used as indices or converted to integers
+func toNumber(v interface{}) (float64, error) {
+	f, err := toAnyNumber(v)
+	if err == nil && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		return 0, fmt.Errorf("%v is not a finite number", f)
+	}
+	return f, err
+}
+
+func toAnyNumber(v interface{}) (float64, error) {
+	switch t := v.(type) {
+	case float64:
+		return t, nil
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(t), 64)
+	case *base.OperatorIO:
+		switch t.OutputType {
+		case base.Integer, base.FloatingPoint:
+			return utils.ConvertToFloat(t.Output)
+		case base.Empty, base.Error, base.Skipped:
+			return 0, fmt.Errorf("Output is not a number")
+		}
+		return strconv.ParseFloat(strings.TrimSpace(t.GetString()), 64)
+	case bool, nil:
+		return 0, fmt.Errorf("%v is not a number", t)
+	}
+	return utils.ConvertToFloat(v)
+}
+
+// toContainer converts operator outputs and structs to maps and slices
+func toContainer(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case map[string]interface{}, []interface{}, map[string]*base.OperatorIO, base.FunctionArguments:
+		return t, nil
+	case *base.OperatorIO:
+		if m, ok := t.Output.(map[string]*base.OperatorIO); ok {
+			return m, nil
+		}
+		b, err := t.GetBytes()
+		if err != nil {
+			return nil, err
+		}
+		var parsed interface{}
+		if err := json.Unmarshal(b, &parsed); err != nil {
+			return nil, err
+		}
+		return parsed, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var parsed interface{}
+	err = json.Unmarshal(b, &parsed)
+	return parsed, err
+}
+
+func getMember(v interface{}, key string, path string) (interface{}, error) {
+	c, err := toContainer(v)
+	if err == nil {
+		switch t := c.(type) {
+		case map[string]interface{}:
+			if r, ok := t[key]; ok {
+				return r, nil
+			}
+			return nil, makeNotFoundError("Variable \"%s\" not found in output \"%s\"", key, path)
+		case map[string]*base.OperatorIO:
+			if r, ok := t[key]; ok {
+				return r, nil
+			}
+			return nil, makeNotFoundError("Variable \"%s\" not found in output \"%s\"", key, path)
+		case base.FunctionArguments:
+			if t.Has(key) {
+				return t.Get(key), nil
+			}
+			return nil, makeNotFoundError("Variable \"%s\" not found in output \"%s\"", key, path)
+		case []interface{}:
+			if i, err := strconv.Atoi(key); err == nil {
+				return getIndex(t, i, path)
+			}
+		}
+	}
+	if io, ok := v.(*base.OperatorIO); ok {
+		// keep the error message of the simple lookup
+		if _, argsErr := io.GetArgsMap(); argsErr != nil {
+			err = argsErr
+		}
+	}
+	if err == nil {
+		err = fmt.Errorf("Output is not a map, type is %T", c)
+	}
+	return nil, fmt.Errorf("Cannot get \"%s\" from \"%s\": %s", key, path, err)
+}
+
+func getIndex(v interface{}, i int, path string) (interface{}, error) {
+	c, err := toContainer(v)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot get [%d] from \"%s\": %s", i, path, err)
+	}
+	switch t := c.(type) {
+	case []interface{}:
+		if i < 0 {
+			i += len(t)
+		}
+		if i < 0 || i >= len(t) {
+			return nil, makeNotFoundError("Index %d out of range in output \"%s\"", i, path)
+		}
+		return t[i], nil
+	case map[string]interface{}, map[string]*base.OperatorIO, base.FunctionArguments:
+		return getMember(c, strconv.Itoa(i), path)
+	}
+	return nil, fmt.Errorf("Cannot get [%d] from \"%s\": Output is not an array", i, path)
+}
+
+/* functions */
+
+func checkArgCount(args []interface{}, min int, max int) error {
+	if len(args) < min || len(args) > max {
+		if min == max {
+			return fmt.Errorf("expected %d arguments, got %d", min, len(args))
+		}
+		return fmt.Errorf("expected %d to %d arguments, got %d", min, max, len(args))
+	}
+	return nil
+}
+
+func stringFunction(f func(string) interface{}) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		if err := checkArgCount(args, 1, 1); err != nil {
+			return nil, err
+		}
+		return f(ExpressionValueToString(args[0])), nil
+	}
+}
+
+func stringPairFunction(f func(string, string) interface{}) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		if err := checkArgCount(args, 2, 2); err != nil {
+			return nil, err
+		}
+		return f(ExpressionValueToString(args[0]), ExpressionValueToString(args[1])), nil
+	}
+}
+
+func numberFunction(f func(float64) float64) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		if err := checkArgCount(args, 1, 1); err != nil {
+			return nil, err
+		}
+		n, err := toNumber(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return f(n), nil
+	}
+}
+
+func minMaxFunction(useMax bool) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("expected at least one argument")
+		}
+		var r float64
+		for i, a := range args {
+			n, err := toNumber(a)
+			if err != nil {
+				return nil, err
+			}
+			if i == 0 || (useMax && n > r) || (!useMax && n < r) {
+				r = n
+			}
+		}
+		return r, nil
+	}
+}
+
+// expressionFunctions are all functions that can be called in expressions, names are case-insensitive
+var expressionFunctions = map[string]func(args []interface{}) (interface{}, error){
+	"upper":      stringFunction(func(s string) interface{} { return strings.ToUpper(s) }),
+	"lower":      stringFunction(func(s string) interface{} { return strings.ToLower(s) }),
+	"trim":       stringFunction(func(s string) interface{} { return strings.TrimSpace(s) }),
+	"string":     stringFunction(func(s string) interface{} { return s }),
+	"contains":   stringPairFunction(func(s, sub string) interface{} { return strings.Contains(s, sub) }),
+	"startswith": stringPairFunction(func(s, prefix string) interface{} { return strings.HasPrefix(s, prefix) }),
+	"endswith":   stringPairFunction(func(s, suffix string) interface{} { return strings.HasSuffix(s, suffix) }),
+	"split": stringPairFunction(func(s, sep string) interface{} {
+		parts := strings.Split(s, sep)
+		r := make([]interface{}, len(parts))
+		for i, p := range parts {
+			r[i] = p
+		}
+		return r
+	}),
+	"replace": func(args []interface{}) (interface{}, error) {
+		if err := checkArgCount(args, 3, 3); err != nil {
+			return nil, err
+		}
+		return strings.ReplaceAll(ExpressionValueToString(args[0]), ExpressionValueToString(args[1]), ExpressionValueToString(args[2])), nil
+	},
+	"substr": func(args []interface{}) (interface{}, error) {
+		if err := checkArgCount(args, 2, 3); err != nil {
+			return nil, err
+		}
+		s := []rune(ExpressionValueToString(args[0]))
+		start, err := toNumber(args[1])
+		if err != nil {
+			return nil, err
+		}
+		end := float64(len(s))
+		if len(args) == 3 {
+			length, err := toNumber(args[2])
+			if err != nil {
+				return nil, err
+			}
+			end = start + length
+		}
+		from := int(math.Max(0, math.Min(start, float64(len(s)))))
+		to := int(math.Max(float64(from), math.Min(end, float64(len(s)))))
+		return string(s[from:to]), nil
+	},
+	"len": func(args []interface{}) (interface{}, error) {
+		if err := checkArgCount(args, 1, 1); err != nil {
+			return nil, err
+		}
+		if _, isString := args[0].(string); !isString {
+			if c, err := toContainer(args[0]); err == nil {
+				switch t := c.(type) {
+				case []interface{}:
+					return float64(len(t)), nil
+				case map[string]interface{}:
+					return float64(len(t)), nil
+				case map[string]*base.OperatorIO:
+					return float64(len(t)), nil
+				}
+			}
+		}
+		return float64(len([]rune(ExpressionValueToString(args[0])))), nil
+	},
+	"keys": func(args []interface{}) (interface{}, error) {
+		if err := checkArgCount(args, 1, 1); err != nil {
+			return nil, err
+		}
+		c, err := toContainer(args[0])
+		if err != nil {
+			return nil, err
+		}
+		keys := []string{}
+		switch t := c.(type) {
+		case map[string]interface{}:
+			for k := range t {
+				keys = append(keys, k)
+			}
+		case map[string]*base.OperatorIO:
+			for k := range t {
+				keys = append(keys, k)
+			}
+		default:
+			return nil, fmt.Errorf("argument is not a map")
+		}
+		sort.Strings(keys)
+		r := make([]interface{}, len(keys))
+		for i, k := range keys {
+			r[i] = k
+		}
+		return r, nil
+	},
+	"number": numberFunction(func(f float64) float64 { return f }),
+	"int":    numberFunction(math.Trunc),
+	"round":  numberFunction(math.Round),
+	"floor":  numberFunction(math.Floor),
+	"ceil":   numberFunction(math.Ceil),
+	"abs":    numberFunction(math.Abs),
+	"min":    minMaxFunction(false),
+	"max":    minMaxFunction(true),
+	"bool": func(args []interface{}) (interface{}, error) {
+		if err := checkArgCount(args, 1, 1); err != nil {
+			return nil, err
+		}
+		return ExpressionValueToBool(args[0]), nil
+	},
+	"if": func(args []interface{}) (interface{}, error) {
+		if err := checkArgCount(args, 3, 3); err != nil {
+			return nil, err
+		}
+		if ExpressionValueToBool(args[0]) {
+			return args[1], nil
+		}
+		return args[2], nil
+	},
+}
@@ -1,11 +1,17 @@
 package freepsflow
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hannesrauhe/freeps/base"
@@ -14,12 +20,16 @@ import (
 const ROOT_SYMBOL = "_"
 const FlowTimeout = time.Minute * 2
 const FlowOperationTimeout = time.Minute
+const FlowParallelism = 4
 
 // Flow is the instance created from a FlowDesc and contains the runtime data
 type Flow struct {
-	desc      *FlowDesc
-	engine    *FlowEngine
-	opOutputs map[string]*base.OperatorIO
+	desc       *FlowDesc
+	engine     *FlowEngine
+	opOutputs  map[string]*base.OperatorIO
+	outputLock sync.Mutex
+
+	onOperationFinished func() // optional callback to report the progress of asynchronous executions
 }
 
 // NewFlow creates a new flow from a flow description
@@ -63,8 +73,120 @@ func (g *Flow) GetTimeout() time.Duration {
 	return d
 }
 
+// GetParallelism returns how many operations may run concurrently, flows need the tag "parallel" or "parallel:<limit>" to run operations concurrently
+func (g *Flow) GetParallelism() int {
+	if !g.desc.HasAllTags([]string{"parallel"}) {
+		return 1
+	}
+	ps := g.desc.GetTagValue("parallel")
+	p, err := strconv.Atoi(ps)
+	if ps == "" || err != nil || p < 1 {
+		return FlowParallelism
+	}
+	return p
+}
+
+// getOutput returns the output of an operation that was already executed
+func (g *Flow) getOutput(name string) (*base.OperatorIO, bool) {
+	g.outputLock.Lock()
+	defer g.outputLock.Unlock()
+	o, exists := g.opOutputs[name]
+	return o, exists
+}
+
+func (g *Flow) setOutput(name string, output *base.OperatorIO) {
+	g.outputLock.Lock()
+	defer g.outputLock.Unlock()
+	g.opOutputs[name] = output
+}
+
+var variableRegexp = regexp.MustCompile(`\${([^}]+)}`)
+
+// getDependencies returns the indices of the previous operations the operation at index i depends on
+func (gd *FlowDesc) getDependencies(i int) []int {
+	opIndex := map[string]int{}
+	for j := 0; j < i; j++ {
+		opIndex[gd.Operations[j].Name] = j
+	}
+	deps := map[int]bool{}
+	addDep := func(name string) {
+		if j, ok := opIndex[name]; ok {
+			deps[j] = true
+		}
+	}
+	op := gd.Operations[i]
+	addDep(op.InputFrom)
+	addDep(op.ArgumentsFrom)
+	addDep(op.ExecuteOnSuccessOf)
+	addDep(op.ExecuteOnFailOf)
+	addDep(op.ForEach)
+	expressions := []string{op.Condition}
+	if op.Switch != nil {
+		expressions = append(expressions, op.Switch.Value)
+	}
+	for _, v := range op.Arguments {
+		expressions = append(expressions, v)
+	}
+	for _, v := range expressions {
+		for _, m := range variableRegexp.FindAllStringSubmatch(v, -1) {
+			addDep(m[1])
+			name, _, _ := strings.Cut(m[1], ".")
+			addDep(name)
+			for _, ident := range ExpressionIdentifiers(m[1]) {
+				addDep(ident)
+			}
+		}
+	}
+	// operations that are targets of a switch are executed after the switch
+	for j := 0; j < i; j++ {
+		if sw := gd.Operations[j].Switch; sw != nil && slices.Contains(sw.GetTargets(), op.Name) {
+			deps[j] = true
+		}
+	}
+	r := make([]int, 0, len(deps))
+	for j := range deps {
+		r = append(r, j)
+	}
+	return r
+}
+
+// executeOperationsParallel executes all operations as soon as the operations they depend on are finished
+func (g *Flow) executeOperationsParallel(ctx *base.Context, mainArgs base.FunctionArguments, parallelism int) {
+	numOps := len(g.desc.Operations)
+	done := make([]chan struct{}, numOps)
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	semaphore := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i := 0; i < numOps; i++ {
+		wg.Add(1)
+		go func(i int, deps []int) {
+			defer wg.Done()
+			defer close(done[i])
+			for _, d := range deps {
+				<-done[d]
+			}
+			operation := g.desc.Operations[i]
+			select {
+			case <-ctx.Done():
+				g.setOutput(operation.Name, base.MakeOutputError(http.StatusServiceUnavailable, "Execution aborted"))
+				return
+			case semaphore <- struct{}{}:
+			}
+			defer func() { <-semaphore }()
+			output := g.executeOperation(ctx, &operation, mainArgs)
+			g.setOutput(operation.Name, output)
+			if g.onOperationFinished != nil {
+				g.onOperationFinished()
+			}
+		}(i, g.desc.getDependencies(i))
+	}
+	wg.Wait()
+}
+
 func (g *Flow) executeSync(parentCtx *base.Context, mainArgs base.FunctionArguments, mainInput *base.OperatorIO) *base.OperatorIO {
-	g.engine.metrics.FlowExecutions++
+	atomic.AddInt64(&g.engine.metrics.FlowExecutions, 1)
 	ctx := parentCtx.ChildContextWithField("flow", g.desc.FlowID)
 	if g.desc.HasAllTags([]string{"debuglogging"}) {
 		prevLevel := ctx.EnableDebugLogging()
@@ -73,16 +195,28 @@ func (g *Flow) executeSync(parentCtx *base.Context, mainArgs base.FunctionArgume
 	ctx.GetLogger().Debugf("Executing flow \"%s\"(\"%s\") with arguments \"%v\"", g.desc.FlowID, g.desc.DisplayName, mainArgs)
 	defer ctx.GetLogger().Debugf("Flow \"%s\" finished", g.desc.FlowID)
 
-	g.opOutputs[ROOT_SYMBOL] = mainInput
+	g.setOutput(ROOT_SYMBOL, mainInput)
 	logger := ctx.GetLogger()
-	for i := 0; i < len(g.desc.Operations); i++ {
+	if parallelism := g.GetParallelism(); parallelism > 1 {
+		g.executeOperationsParallel(ctx, mainArgs, parallelism)
 		select {
 		case <-ctx.Done():
 			return base.MakeOutputError(http.StatusServiceUnavailable, "Execution aborted")
 		default:
-			operation := g.desc.Operations[i]
-			output := g.executeOperation(ctx, &operation, mainArgs)
-			g.opOutputs[operation.Name] = output
+		}
+	} else {
+		for i := 0; i < len(g.desc.Operations); i++ {
+			select {
+			case <-ctx.Done():
+				return base.MakeOutputError(http.StatusServiceUnavailable, "Execution aborted")
+			default:
+				operation := g.desc.Operations[i]
+				output := g.executeOperation(ctx, &operation, mainArgs)
+				g.setOutput(operation.Name, output)
+				if g.onOperationFinished != nil {
+					g.onOperationFinished()
+				}
+			}
 		}
 	}
 	if g.desc.OutputFrom == "" {
@@ -97,7 +231,17 @@ func (g *Flow) executeSync(parentCtx *base.Context, mainArgs base.FunctionArgume
 	return flowOutput
 }
 
+// execute runs the flow inside a span of the trace of pctx
 func (g *Flow) execute(pctx *base.Context, mainArgs base.FunctionArguments, mainInput *base.OperatorIO) *base.OperatorIO {
+	startTime := time.Now()
+	ctx, span := g.engine.startSpan(pctx, SpanKindFlow, g.desc.FlowID, g.desc.FlowID, nil)
+	output := g.executeWithOptionalTimeout(ctx, mainArgs, mainInput)
+	g.engine.finishSpan(ctx, span, output)
+	g.engine.recordFlowExecution(g.desc.FlowID, output, time.Since(startTime))
+	return output
+}
+
+func (g *Flow) executeWithOptionalTimeout(pctx *base.Context, mainArgs base.FunctionArguments, mainInput *base.OperatorIO) *base.OperatorIO {
 	if g.GetTimeout() == 0 {
 		return g.executeSync(pctx, mainArgs, mainInput)
 	}
@@ -114,6 +258,10 @@ func (g *Flow) execute(pctx *base.Context, mainArgs base.FunctionArguments, main
 
 	select {
 	case <-ctx.Done():
+		if pctx.GoContext.Err() != nil {
+			output = base.MakeOutputError(http.StatusServiceUnavailable, "Execution of flow \"%v\" was cancelled after %v", g.desc.DisplayName, time.Now().Sub(startTime))
+			break
+		}
 		alertExpire := 5 * time.Minute
 		output = base.MakeOutputError(http.StatusGatewayTimeout, "Timeout after %v when executing flow \"%v\" with arguments \"%v\"", time.Now().Sub(startTime), g.desc.DisplayName, mainArgs)
 		g.engine.SetSystemAlert(pctx, fmt.Sprintf("flowTimeout.%s", g.desc.FlowID), "system", 2, output.GetError(), &alertExpire)
@@ -130,8 +278,29 @@ func (g *Flow) collectAndReturnOperationError(ctx *base.Context, input *base.Ope
 	return error
 }
 
-// replaceVariablesInArgs replaces variables of the form ${varName} in plainArgs with the values from the opOutputs
-func (g *Flow) replaceVariablesInArgs(plainArgs map[string]string) (map[string]string, error) {
+// lookupVariable returns the output of an operation or a loop variable
+func (g *Flow) lookupVariable(name string, loopVars map[string]*base.OperatorIO) (*base.OperatorIO, bool) {
+	if v, exists := loopVars[name]; exists {
+		return v, true
+	}
+	return g.getOutput(name)
+}
+
+// expressionVariables resolves identifiers in expressions to loop variables, operation outputs or the main arguments ("_args")
+func (g *Flow) expressionVariables(mainArgs base.FunctionArguments, loopVars map[string]*base.OperatorIO) ExpressionVariables {
+	return func(name string) (interface{}, bool) {
+		if v, exists := g.lookupVariable(name, loopVars); exists {
+			return v, true
+		}
+		if name == "_args" && mainArgs != nil {
+			return mainArgs, true
+		}
+		return nil, false
+	}
+}
+
+// replaceVariablesInArgs replaces expressions of the form ${expr} in plainArgs, see EvaluateExpression for the syntax
+func (g *Flow) replaceVariablesInArgs(plainArgs map[string]string, mainArgs base.FunctionArguments, loopVars map[string]*base.OperatorIO) (map[string]string, error) {
 	r := make(map[string]string)
 
 	if plainArgs == nil {
@@ -139,50 +308,59 @@ func (g *Flow) replaceVariablesInArgs(plainArgs map[string]string) (map[string]s
 	}
 
 	var returnErr error
+	vars := g.expressionVariables(mainArgs, loopVars)
 
-	re := regexp.MustCompile(`\${([^}]+)}`)
 	for k, v := range plainArgs {
-		r[k] = re.ReplaceAllStringFunc(v, func(match string) string {
-			outputName := match[2 : len(match)-1]
-			if opOutput, exists := g.opOutputs[outputName]; exists {
+		r[k] = variableRegexp.ReplaceAllStringFunc(v, func(match string) string {
+			expr := match[2 : len(match)-1]
+			if opOutput, exists := g.lookupVariable(expr, loopVars); exists {
 				return opOutput.GetString()
 			}
-			// split varName by "." and try to find the value in the opOutputs
-			parts := strings.SplitN(outputName, ".", 2)
-			if len(parts) < 2 {
-				returnErr = fmt.Errorf("Output \"%s\" not found", outputName)
-				return ""
-			}
-			outputName = parts[0]
-			varInMap := parts[1]
-			opOutput, exists := g.opOutputs[outputName]
-			if !exists {
-				returnErr = fmt.Errorf("Output \"%s\" not found", outputName)
-				return ""
+			// keys of the flattened output can contain characters that are not valid in expressions
+			if outputName, varInMap, found := strings.Cut(expr, "."); found {
+				if opOutput, exists := g.lookupVariable(outputName, loopVars); exists {
+					if args, err := opOutput.GetArgsMap(); err == nil {
+						if val, exists := args[varInMap]; exists {
+							return val
+						}
+					}
+				}
 			}
-			args, err := opOutput.GetArgsMap()
+			val, err := EvaluateExpression(expr, vars)
 			if err != nil {
-				returnErr = fmt.Errorf("Cannot get \"%s\" from \"%s\": %s", varInMap, outputName, err)
-				return ""
-			}
-			val, exists := args[varInMap]
-			if !exists {
-				returnErr = fmt.Errorf("Variable \"%s\" not found in output \"%s\"", varInMap, outputName)
+				returnErr = err
 				return ""
 			}
-			return val
+			return ExpressionValueToString(val)
 		})
 	}
 	return r, returnErr
 }
 
+// executeOperation runs the operation inside a span, operations that execute flows make it the parent span of these flows
 func (g *Flow) executeOperation(parentCtx *base.Context, originalOpDesc *FlowOperationDesc, mainArgs base.FunctionArguments) *base.OperatorIO {
-	g.engine.metrics.OperationExecutions++
+	startTime := time.Now()
+	spanCtx, span := g.engine.startSpan(parentCtx, SpanKindOperation, originalOpDesc.Name, g.GetFlowID(), originalOpDesc)
+	output := g.executeOperationInSpan(spanCtx, originalOpDesc, mainArgs)
+	g.engine.finishSpan(spanCtx, span, output)
+	g.engine.recordOperationExecution(originalOpDesc, output, time.Since(startTime))
+	return output
+}
+
+func (g *Flow) executeOperationInSpan(parentCtx *base.Context, originalOpDesc *FlowOperationDesc, mainArgs base.FunctionArguments) *base.OperatorIO {
+	atomic.AddInt64(&g.engine.metrics.OperationExecutions, 1)
 	ctx := parentCtx.ChildContextWithField("operation", originalOpDesc.Name)
 	logger := ctx.GetLogger()
 	input := base.MakeEmptyOutput()
+	if skipped := g.checkSkipped(ctx, originalOpDesc, mainArgs); skipped != nil {
+		if skipped.IsSkipped() {
+			logger.Debugf("Skipping operation \"%v\": %v", originalOpDesc.Name, skipped.GetSkipReason())
+		}
+		g.engine.TriggerOnExecuteOperationHooks(ctx, input, skipped, g.GetFlowID(), originalOpDesc)
+		return skipped
+	}
 	if originalOpDesc.InputFrom != "" {
-		input = g.opOutputs[originalOpDesc.InputFrom]
+		input, _ = g.getOutput(originalOpDesc.InputFrom)
 		if input.IsError() {
 			// reduce logging of eval-related "errors"
 			if input.HTTPCode != http.StatusExpectationFailed {
@@ -192,18 +370,204 @@ func (g *Flow) executeOperation(parentCtx *base.Context, originalOpDesc *FlowOpe
 		}
 	}
 
-	if originalOpDesc.ExecuteOnSuccessOf != "" && g.opOutputs[originalOpDesc.ExecuteOnSuccessOf].IsError() {
-		return base.MakeOutputError(http.StatusExpectationFailed, "Operation not executed because \"%v\" did not succeed", originalOpDesc.ExecuteOnSuccessOf)
+	if originalOpDesc.ExecuteOnSuccessOf != "" {
+		if o, _ := g.getOutput(originalOpDesc.ExecuteOnSuccessOf); o.IsError() {
+			return base.MakeOutputError(http.StatusExpectationFailed, "Operation not executed because \"%v\" did not succeed", originalOpDesc.ExecuteOnSuccessOf)
+		}
+	}
+
+	if originalOpDesc.ExecuteOnFailOf != "" {
+		if o, _ := g.getOutput(originalOpDesc.ExecuteOnFailOf); !o.IsError() {
+			return base.MakeOutputError(http.StatusExpectationFailed, "Operation not executed because \"%v\" did not fail", originalOpDesc.ExecuteOnFailOf)
+		}
+	}
+
+	if originalOpDesc.Switch != nil {
+		output := g.executeSwitch(originalOpDesc, mainArgs)
+		g.engine.TriggerOnExecuteOperationHooks(ctx, input, output, g.GetFlowID(), originalOpDesc)
+		return output
+	}
+	if originalOpDesc.ForEach != "" {
+		return g.executeForEach(ctx, originalOpDesc, mainArgs, input)
+	}
+	return g.executeSingleOperation(ctx, originalOpDesc, mainArgs, input, nil)
+}
+
+// evaluateExpressionString replaces all ${...} in str, see replaceVariablesInArgs
+func (g *Flow) evaluateExpressionString(str string, mainArgs base.FunctionArguments) (string, error) {
+	r, err := g.replaceVariablesInArgs(map[string]string{"": str}, mainArgs, nil)
+	return r[""], err
+}
+
+// checkSkipped returns a skipped output if the operation must not be executed because of its condition, a switch or
+// because an operation it depends on was skipped, returns an error if the condition cannot be evaluated and nil otherwise
+func (g *Flow) checkSkipped(ctx *base.Context, opDesc *FlowOperationDesc, mainArgs base.FunctionArguments) *base.OperatorIO {
+	for _, dep := range []string{opDesc.InputFrom, opDesc.ExecuteOnSuccessOf, opDesc.ExecuteOnFailOf, opDesc.ForEach} {
+		if dep == "" {
+			continue
+		}
+		if o, _ := g.getOutput(dep); o != nil && o.IsSkipped() {
+			return base.MakeSkippedOutput("\"%v\" was skipped", dep)
+		}
+	}
+
+	targeted := false
+	selectedBy := ""
+	for _, op := range g.desc.Operations {
+		if op.Switch == nil || !slices.Contains(op.Switch.GetTargets(), opDesc.Name) {
+			continue
+		}
+		targeted = true
+		if o, _ := g.getOutput(op.Name); o != nil && o.IsPlain() && o.GetString() == opDesc.Name {
+			selectedBy = op.Name
+			break
+		}
+	}
+	if targeted && selectedBy == "" {
+		return base.MakeSkippedOutput("Not selected by a switch")
+	}
+
+	if opDesc.Condition == "" {
+		return nil
+	}
+	condition, err := g.evaluateExpressionString(opDesc.Condition, mainArgs)
+	if err != nil {
+		return base.MakeOutputError(http.StatusBadRequest, "Cannot evaluate condition \"%v\": %v", opDesc.Condition, err)
+	}
+	if !ExpressionValueToBool(condition) {
+		return base.MakeSkippedOutput("Condition \"%v\" is false", opDesc.Condition)
+	}
+	return nil
+}
+
+// executeSwitch returns the name of the operation selected by the switch as plain output
+func (g *Flow) executeSwitch(opDesc *FlowOperationDesc, mainArgs base.FunctionArguments) *base.OperatorIO {
+	value, err := g.evaluateExpressionString(opDesc.Switch.Value, mainArgs)
+	if err != nil {
+		return base.MakeOutputError(http.StatusBadRequest, "Cannot evaluate switch value \"%v\": %v", opDesc.Switch.Value, err)
+	}
+	target := opDesc.Switch.Select(value)
+	if target == "" {
+		return base.MakeSkippedOutput("No case for \"%v\"", value)
+	}
+	return base.MakePlainOutput(target)
+}
+
+// getForEachElements returns the keys and elements of an array or map output in a stable order
+func getForEachElements(io *base.OperatorIO) ([]string, []*base.OperatorIO, error) {
+	keys := []string{}
+	items := []*base.OperatorIO{}
+	if io.IsEmpty() {
+		return keys, items, nil
+	}
+	if outputMap, ok := io.Output.(map[string]*base.OperatorIO); ok {
+		for k := range outputMap {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			items = append(items, outputMap[k])
+		}
+		return keys, items, nil
+	}
+
+	// plain text and byte outputs might contain JSON as well
+	b, err := io.GetBytes()
+	if err != nil {
+		return nil, nil, err
+	}
+	var parsed interface{}
+	err = json.Unmarshal(b, &parsed)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch t := parsed.(type) {
+	case []interface{}:
+		for i, v := range t {
+			keys = append(keys, strconv.Itoa(i))
+			items = append(items, base.MakeOutputInferType(v))
+		}
+	case map[string]interface{}:
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			items = append(items, base.MakeOutputInferType(t[k]))
+		}
+	default:
+		return nil, nil, fmt.Errorf("Output is neither an array nor a map, type is %T", parsed)
+	}
+	return keys, items, nil
+}
+
+// acquireUnlessDone waits for a free slot in the semaphore and returns false without acquiring it if the context is done
+func acquireUnlessDone(ctx *base.Context, semaphore chan struct{}) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case semaphore <- struct{}{}:
+	}
+	// both might have been ready, cancellation takes precedence
+	select {
+	case <-ctx.Done():
+		<-semaphore
+		return false
+	default:
+		return true
+	}
+}
+
+// executeForEach executes the operation once for every element of the ForEach output and collects the results by index or key
+func (g *Flow) executeForEach(ctx *base.Context, opDesc *FlowOperationDesc, mainArgs base.FunctionArguments, input *base.OperatorIO) *base.OperatorIO {
+	loopOutput, exists := g.getOutput(opDesc.ForEach)
+	if !exists {
+		return g.collectAndReturnOperationError(ctx, input, opDesc, 404, "Output \"%s\" cannot be used in ForEach, because there is no such output", opDesc.ForEach)
+	}
+	if loopOutput.IsError() {
+		return base.MakeOutputError(http.StatusExpectationFailed, "Operation not executed because \"%v\" did not succeed", opDesc.ForEach)
+	}
+	keys, items, err := getForEachElements(loopOutput)
+	if err != nil {
+		return g.collectAndReturnOperationError(ctx, input, opDesc, http.StatusBadRequest, "Cannot iterate over output of \"%s\": %v", opDesc.ForEach, err)
 	}
 
-	if originalOpDesc.ExecuteOnFailOf != "" && !g.opOutputs[originalOpDesc.ExecuteOnFailOf].IsError() {
-		return base.MakeOutputError(http.StatusExpectationFailed, "Operation not executed because \"%v\" did not fail", originalOpDesc.ExecuteOnFailOf)
+	results := make(map[string]*base.OperatorIO, len(keys))
+	var resultLock sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, g.GetParallelism())
+	for i, k := range keys {
+		if !acquireUnlessDone(ctx, semaphore) {
+			// the flow was cancelled or timed out, the remaining elements are not executed
+			resultLock.Lock()
+			for _, k := range keys[i:] {
+				results[k] = base.MakeOutputError(http.StatusServiceUnavailable, "Execution aborted")
+			}
+			resultLock.Unlock()
+			break
+		}
+		loopVars := map[string]*base.OperatorIO{"item": items[i], "index": base.MakePlainOutput(k)}
+		wg.Add(1)
+		go func(k string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			output := g.executeSingleOperation(ctx, opDesc, mainArgs, input, loopVars)
+			resultLock.Lock()
+			results[k] = output
+			resultLock.Unlock()
+		}(k)
 	}
+	wg.Wait()
+	return base.MakeObjectOutput(results)
+}
 
+// executeSingleOperation replaces variables in the arguments and calls the operator, loopVars are additional variables available inside a ForEach loop
+func (g *Flow) executeSingleOperation(ctx *base.Context, originalOpDesc *FlowOperationDesc, mainArgs base.FunctionArguments, input *base.OperatorIO, loopVars map[string]*base.OperatorIO) *base.OperatorIO {
+	logger := ctx.GetLogger()
 	finalOpDesc := &FlowOperationDesc{}
 	*finalOpDesc = *originalOpDesc
 	var err error
-	finalOpDesc.Arguments, err = g.replaceVariablesInArgs(originalOpDesc.Arguments)
+	finalOpDesc.Arguments, err = g.replaceVariablesInArgs(originalOpDesc.Arguments, mainArgs, loopVars)
 	if err != nil {
 		return g.collectAndReturnOperationError(ctx, input, finalOpDesc, 404, "%s", err.Error())
 	}
@@ -219,7 +583,7 @@ func (g *Flow) executeOperation(parentCtx *base.Context, originalOpDesc *FlowOpe
 	}
 
 	if finalOpDesc.ArgumentsFrom != "" {
-		outputToBeArgs, exists := g.opOutputs[finalOpDesc.ArgumentsFrom]
+		outputToBeArgs, exists := g.getOutput(finalOpDesc.ArgumentsFrom)
 		if !exists {
 			return g.collectAndReturnOperationError(ctx, input, finalOpDesc, 404, "Output of \"%s\" cannot be used as arguments, because there is no such output", finalOpDesc.ArgumentsFrom)
 		}
@@ -244,14 +608,43 @@ func (g *Flow) executeOperation(parentCtx *base.Context, originalOpDesc *FlowOpe
 		ctx.GetLogger().Debugf("Calling operator \"%v\", Function \"%v\" with arguments \"%v\"", finalOpDesc.Operator, finalOpDesc.Function, combinedArgs.GetOriginalCaseMap())
 		defer ctx.GetLogger().Debugf("Operation \"%s\" finished", originalOpDesc.Name)
 
-		output := g.executeOperationWithOptionalTimeout(ctx, op, finalOpDesc.Function, combinedArgs, input)
-		g.engine.TriggerOnExecuteOperationHooks(ctx, input, output, g.GetFlowID(), finalOpDesc)
-		return output
+		return g.executeOperationWithOptionalTimeout(ctx, op, finalOpDesc, combinedArgs, input)
 	}
 	return g.collectAndReturnOperationError(ctx, input, finalOpDesc, 404, "No operator with name \"%s\" found", finalOpDesc.Operator)
 }
 
-func (g *Flow) executeOperationWithOptionalTimeout(parentCtx *base.Context, op base.FreepsBaseOperator, fn string, mainArgs base.FunctionArguments, input *base.OperatorIO) *base.OperatorIO {
+// executeOperationWithOptionalTimeout calls the operator and retries according to the RetryPolicy of the operation, hooks are triggered after every attempt
+func (g *Flow) executeOperationWithOptionalTimeout(ctx *base.Context, op base.FreepsBaseOperator, opDesc *FlowOperationDesc, mainArgs base.FunctionArguments, input *base.OperatorIO) *base.OperatorIO {
+	if opDesc.Retry == nil {
+		output := g.executeOperationAttempt(ctx, op, opDesc.Function, mainArgs, input)
+		g.engine.TriggerOnExecuteOperationHooks(ctx, input, output, g.GetFlowID(), opDesc)
+		return output
+	}
+
+	maxAttempts := opDesc.Retry.GetMaxAttempts()
+	backoff := opDesc.Retry.GetBackoff()
+	for attempt := 1; ; attempt++ {
+		attemptCtx := ctx.ChildContextWithField("attempt", strconv.Itoa(attempt))
+		output := g.executeOperationAttempt(attemptCtx, op, opDesc.Function, mainArgs, input)
+		if attempt >= maxAttempts || !opDesc.Retry.ShouldRetry(output) {
+			g.engine.TriggerOnExecuteOperationHooks(attemptCtx, input, output, g.GetFlowID(), opDesc)
+			return output
+		}
+
+		attemptCtx.GetLogger().Debugf("Attempt %d/%d of operation \"%s\" failed, retrying in %v: %v", attempt, maxAttempts, opDesc.Name, backoff, output.GetError())
+		failedAttempt := base.MakeOutputError(output.HTTPCode, "Attempt %d/%d failed, retrying in %v: %v", attempt, maxAttempts, backoff, output.GetError())
+		g.engine.TriggerOnExecuteOperationHooks(attemptCtx, input, failedAttempt, g.GetFlowID(), opDesc)
+
+		select {
+		case <-ctx.Done():
+			return base.MakeOutputError(http.StatusServiceUnavailable, "Execution aborted while waiting for attempt %d/%d: %v", attempt+1, maxAttempts, output.GetError())
+		case <-time.After(backoff):
+		}
+		backoff = opDesc.Retry.NextBackoff(backoff)
+	}
+}
+
+func (g *Flow) executeOperationAttempt(parentCtx *base.Context, op base.FreepsBaseOperator, fn string, mainArgs base.FunctionArguments, input *base.OperatorIO) *base.OperatorIO {
 	fnctx := parentCtx.ChildContextWithField("op-fn", op.GetName()+"/"+fn)
 	if g.GetOperationTimeout() == 0 {
 		return op.Execute(fnctx, fn, mainArgs, input)
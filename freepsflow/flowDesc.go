@@ -4,8 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/hannesrauhe/freeps/base"
 	"github.com/hannesrauhe/freeps/utils"
 )
 
@@ -20,6 +24,112 @@ type FlowOperationDesc struct {
 	ExecuteOnFailOf    string            `json:",omitempty"`
 	ArgumentsFrom      string            `json:",omitempty"`
 	UseMainArgs        bool              `json:",omitempty"`
+	ForEach            string            `json:",omitempty"` // execute the operation for every element of this output, ${item} and ${index} can be used in Arguments
+	Retry              *RetryPolicy      `json:",omitempty"`
+	Condition          string            `json:",omitempty"` // the operation is skipped unless the condition is true, e.g. "${temp > 20}"
+	Switch             *SwitchDesc       `json:",omitempty"`
+}
+
+// SwitchDesc turns an operation into a branch: the operation named in the matching case is executed, all other cases are skipped
+type SwitchDesc struct {
+	Value   string            // e.g. "${weather.condition}"
+	Cases   map[string]string // maps values to the names of the operations to execute
+	Default string            `json:",omitempty"` // the operation to execute if no case matches
+}
+
+// GetTargets returns the names of all operations the switch can route to
+func (s *SwitchDesc) GetTargets() []string {
+	targets := []string{}
+	for _, t := range s.Cases {
+		if !slices.Contains(targets, t) {
+			targets = append(targets, t)
+		}
+	}
+	if s.Default != "" && !slices.Contains(targets, s.Default) {
+		targets = append(targets, s.Default)
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// Select returns the name of the operation for the given value or "" if there is none
+func (s *SwitchDesc) Select(value string) string {
+	if t, ok := s.Cases[value]; ok {
+		return t
+	}
+	return s.Default
+}
+
+// RetryPolicy defines if and how often an operation is retried when it fails
+type RetryPolicy struct {
+	MaxAttempts   int     // number of attempts including the first one
+	Backoff       string  `json:",omitempty"` // time to wait before the first retry, e.g. "500ms"
+	BackoffFactor float64 `json:",omitempty"` // the wait time is multiplied by this factor after each retry, defaults to 2
+	MaxBackoff    string  `json:",omitempty"` // upper limit for the wait time
+	RetryOnCodes  []int   `json:",omitempty"` // HTTP codes that cause a retry, all server errors (5xx) if empty
+}
+
+const DefaultRetryBackoff = time.Second
+
+// Validate checks if all durations can be parsed
+func (r *RetryPolicy) Validate() error {
+	if r.MaxAttempts < 1 {
+		return fmt.Errorf("MaxAttempts must be at least 1")
+	}
+	if r.BackoffFactor < 0 {
+		return fmt.Errorf("BackoffFactor must not be negative")
+	}
+	for _, ds := range []string{r.Backoff, r.MaxBackoff} {
+		if ds == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(ds); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetMaxAttempts returns the number of attempts, at least 1
+func (r *RetryPolicy) GetMaxAttempts() int {
+	if r.MaxAttempts < 1 {
+		return 1
+	}
+	return r.MaxAttempts
+}
+
+// GetBackoff returns the time to wait before the first retry
+func (r *RetryPolicy) GetBackoff() time.Duration {
+	d, err := time.ParseDuration(r.Backoff)
+	if r.Backoff == "" || err != nil {
+		return DefaultRetryBackoff
+	}
+	return d
+}
+
+// NextBackoff returns the time to wait before the next retry
+func (r *RetryPolicy) NextBackoff(current time.Duration) time.Duration {
+	factor := r.BackoffFactor
+	if factor == 0 {
+		factor = 2
+	}
+	next := time.Duration(float64(current) * factor)
+	maxBackoff, err := time.ParseDuration(r.MaxBackoff)
+	if r.MaxBackoff != "" && err == nil && next > maxBackoff {
+		return maxBackoff
+	}
+	return next
+}
+
+// ShouldRetry returns true if the output is an error that should be retried
+func (r *RetryPolicy) ShouldRetry(output *base.OperatorIO) bool {
+	if !output.IsError() {
+		return false
+	}
+	if len(r.RetryOnCodes) == 0 {
+		return output.HTTPCode >= 500
+	}
+	return slices.Contains(r.RetryOnCodes, output.HTTPCode)
 }
 
 // ToQuicklink returns the URL to call a standalone-operation outside of a Flow
@@ -160,6 +270,15 @@ func (gd *FlowDesc) RenameOperation(oldName string, newName string) {
 		rename(&gd.Operations[i].InputFrom)
 		rename(&gd.Operations[i].ExecuteOnSuccessOf)
 		rename(&gd.Operations[i].ExecuteOnFailOf)
+		rename(&gd.Operations[i].ForEach)
+		if sw := gd.Operations[i].Switch; sw != nil {
+			rename(&sw.Default)
+			for k := range sw.Cases {
+				t := sw.Cases[k]
+				rename(&t)
+				sw.Cases[k] = t
+			}
+		}
 	}
 	rename(&gd.OutputFrom)
 }
@@ -184,6 +303,14 @@ func (gd *FlowDesc) GetCompleteDesc(flowID string, ge *FlowEngine) (*FlowDesc, e
 		return &completeFlowDesc, errors.New("FlowEngine not set")
 	}
 
+	// switches can only route to operations that are executed after them
+	laterOperations := make(map[string]int)
+	for i, op := range gd.Operations {
+		if op.Name != "" {
+			laterOperations[op.Name] = i
+		}
+	}
+
 	// create a copy of each operation and add it to the flow
 	for i, op := range gd.Operations {
 		if op.Name == ROOT_SYMBOL {
@@ -195,7 +322,7 @@ func (gd *FlowDesc) GetCompleteDesc(flowID string, ge *FlowEngine) (*FlowDesc, e
 		if op.Name == "" {
 			op.Name = fmt.Sprintf("#%d", i)
 		}
-		if !ge.HasOperator(op.Operator) {
+		if op.Switch == nil && !ge.HasOperator(op.Operator) {
 			return &completeFlowDesc, fmt.Errorf("Operation \"%v\" references unknown operator \"%v\"", op.Name, op.Operator)
 		}
 		if op.ArgumentsFrom != "" && outputNames[op.ArgumentsFrom] != true {
@@ -220,6 +347,27 @@ func (gd *FlowDesc) GetCompleteDesc(flowID string, ge *FlowEngine) (*FlowDesc, e
 				return &completeFlowDesc, fmt.Errorf("Operation \"%v\" references the same ExecuteOnSuccessOf and ExecuteOnFailOf \"%v\"", op.Name, op.ExecuteOnFailOf)
 			}
 		}
+		if op.Retry != nil {
+			if err := op.Retry.Validate(); err != nil {
+				return &completeFlowDesc, fmt.Errorf("Operation \"%v\" has an invalid retry policy: %v", op.Name, err)
+			}
+		}
+		if op.ForEach != "" && outputNames[op.ForEach] != true {
+			return &completeFlowDesc, fmt.Errorf("Operation \"%v\" references unknown ForEach \"%v\"", op.Name, op.ForEach)
+		}
+		if op.Switch != nil {
+			if op.Switch.Value == "" {
+				return &completeFlowDesc, fmt.Errorf("Operation \"%v\" has a switch without a value", op.Name)
+			}
+			if op.ForEach != "" {
+				return &completeFlowDesc, fmt.Errorf("Operation \"%v\" cannot combine Switch and ForEach", op.Name)
+			}
+			for _, target := range op.Switch.GetTargets() {
+				if j, ok := laterOperations[target]; !ok || j <= i {
+					return &completeFlowDesc, fmt.Errorf("Switch \"%v\" references unknown or previous operation \"%v\"", op.Name, target)
+				}
+			}
+		}
 		outputNames[op.Name] = true
 		completeFlowDesc.Operations[i] = op
 
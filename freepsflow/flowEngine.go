@@ -7,6 +7,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hannesrauhe/freeps/base"
@@ -17,6 +18,8 @@ import (
 // FlowEngineConfig is the configuration for the FlowEngine
 type FlowEngineConfig struct {
 	AlertDuration time.Duration
+	MaxTraces     int    // number of execution traces kept in memory, 0 disables tracing
+	OTLPEndpoint  string // optional OTLP/HTTP endpoint (e.g. http://localhost:4318/v1/traces) finished traces are sent to
 }
 
 var DefaultFlowEngineConfig = FlowEngineConfig{AlertDuration: time.Hour}
@@ -36,15 +39,26 @@ type FlowEngine struct {
 	metrics         FlowEngineMetrics
 	config          FlowEngineConfig
 	reloadRequested bool
+	jobs            map[string]*flowJob
+	traces          map[string]*trace
+	traceOrder      []string
 	flowLock        sync.Mutex
+	jobLock         sync.Mutex
+	traceLock       sync.Mutex
 	operatorLock    sync.Mutex
 	hookMapLock     sync.Mutex
+
+	flowStatistics      map[string]*ExecutionStatistics
+	operationStatistics map[OperationKey]*ExecutionStatistics
+	statisticsLock      sync.Mutex
 }
 
 // NewFlowEngine creates the flow engine from the config
 func NewFlowEngine(ctx *base.Context, cr *utils.ConfigReader, cancel context.CancelFunc) *FlowEngine {
-	ge := &FlowEngine{cr: cr, flows: make(map[string]*FlowDesc), reloadRequested: false}
+	ge := &FlowEngine{cr: cr, flows: make(map[string]*FlowDesc), jobs: make(map[string]*flowJob), traces: make(map[string]*trace), reloadRequested: false}
 
+	ge.flowStatistics = make(map[string]*ExecutionStatistics)
+	ge.operationStatistics = make(map[OperationKey]*ExecutionStatistics)
 	ge.operators = make(map[string]base.FreepsBaseOperator)
 	ge.operators["flow"] = &OpFlow{ge: ge}
 	ge.operators["flowbytag"] = &OpFlowByTag{ge: ge}
@@ -513,7 +527,7 @@ func (ge *FlowEngine) DeleteFlow(ctx *base.Context, flowID string) (*FlowDesc, e
 
 // GetMetrics returns the metrics of the flow engine
 func (ge *FlowEngine) GetMetrics() FlowEngineMetrics {
-	return ge.metrics
+	return FlowEngineMetrics{OperationExecutions: atomic.LoadInt64(&ge.metrics.OperationExecutions), FlowExecutions: atomic.LoadInt64(&ge.metrics.FlowExecutions)}
 }
 
 // StartListening starts all listening operators
@@ -532,6 +546,8 @@ func (ge *FlowEngine) StartListening(pctx *base.Context) {
 
 // Shutdown should be called for graceful shutdown
 func (ge *FlowEngine) Shutdown(ctx *base.Context) {
+	ge.cancelAllJobs(ctx)
+
 	ge.operatorLock.Lock()
 	defer ge.operatorLock.Unlock()
 
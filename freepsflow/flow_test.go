@@ -1,12 +1,23 @@
 package freepsflow_test
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
 	"sort"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/hannesrauhe/freeps/base"
+	freepsstore "github.com/hannesrauhe/freeps/connectors/store"
+	optime "github.com/hannesrauhe/freeps/connectors/time"
 	"github.com/hannesrauhe/freeps/freepsd/helper"
 	"github.com/hannesrauhe/freeps/freepsflow"
 	"github.com/hannesrauhe/freeps/utils"
@@ -345,6 +356,63 @@ func TestArgumentReplacement(t *testing.T) {
 	assert.Equal(t, r.GetError().Error(), "Variable \"doesntexist\" not found in output \"stat_output\"")
 }
 
+func TestExpressions(t *testing.T) {
+	ctx, ge, _ := helper.SetupEngineWithCommonOperators(t, nil)
+
+	eval := func(expr string) *base.OperatorIO {
+		g := freepsflow.FlowDesc{Operations: []freepsflow.FlowOperationDesc{
+			{Name: "devices", Operator: "eval", Function: "echo", Arguments: map[string]string{"output": `{"list":[{"name":"lamp","power":12.5},{"name":"tv-set","power":80}],"on":true}`}},
+			{Name: "temp", Operator: "eval", Function: "echo", Arguments: map[string]string{"output": "21"}},
+			{Name: "output", Operator: "eval", Function: "echo", Arguments: map[string]string{"output": expr}},
+		}, OutputFrom: "output"}
+		ge.AddFlow(ctx, "exprtest", g, true)
+		return ge.ExecuteFlow(ctx, "exprtest", base.NewFunctionArguments(map[string]string{"foo": "bar"}), base.MakeEmptyOutput())
+	}
+
+	tests := map[string]string{
+		"${devices.list[0].name}":                          "lamp",
+		"${devices.list[-1].name}":                         "tv-set",
+		"${devices.list.1.name}":                           "tv-set",
+		"${devices.list[1][\"name\"]}":                     "tv-set",
+		"${devices.on}":                                    "true",
+		"${len(devices.list)} devices":                     "2 devices",
+		"${temp * 1.8 + 32}":                               "69.8",
+		"${(temp + 1) % 5}":                                "2",
+		"${devices.list[0].power + devices.list[1].power}": "92.5",
+		"${upper(devices.list[0].name)}":                   "LAMP",
+		"${replace(devices.list[1].name, \"-\", \" \")}":   "tv set",
+		"${substr(\"freeps\", 1, 3)}":                      "ree",
+		"${round(2.5)}":                                    "3",
+		"${missing ?? \"off\"}":                            "off",
+		"${devices.nope ?? devices.list[5] ?? 'x'}":        "x",
+		"${temp ?? 0}":                                     "21",
+		"${_args.foo}":                                     "bar",
+		"${_args.FOO == \"bar\" && temp > 20}":             "true",
+		"${temp >= 22 || !devices.on}":                     "false",
+		"${\"t\" + temp}":                                  "t21",
+		"${if(temp > 20, \"warm\", \"cold\")}":             "warm",
+	}
+	for expr, expected := range tests {
+		r := eval(expr)
+		assert.Assert(t, !r.IsError(), "%v: %v", expr, r.GetString())
+		assert.Equal(t, r.GetString(), expected, expr)
+	}
+
+	for _, invalid := range []string{"${temp +}", "${unknownfn(temp)}", "${devices.list[7].name}", "${temp / 0}", "${\"a\" - 1}", "${devices.list[0].name.foo}",
+		"${substr(\"abc\", number(\"NaN\"))}", "${substr(\"abc\", 1, number(\"Inf\"))}", "${substr(\"abc\", number(\"-Inf\"), 1)}", "${int(1e308 * 10)}"} {
+		assert.Assert(t, eval(invalid).IsError(), invalid)
+	}
+
+	/* eval exposes the same evaluator */
+	input := base.MakeObjectOutput(map[string]interface{}{"temperature": 23.5})
+	r := ge.ExecuteOperatorByName(ctx, "eval", "eval", base.NewFunctionArguments(map[string]string{"expression": "input.temperature >= 20 && input.temperature < 25", "output": "input"}), input)
+	assert.Equal(t, r, input)
+	r = ge.ExecuteOperatorByName(ctx, "eval", "eval", base.NewSingleFunctionArgument("expression", "input.temperature > 25"), input)
+	assert.Equal(t, r.GetStatusCode(), http.StatusExpectationFailed)
+	r = ge.ExecuteOperatorByName(ctx, "eval", "eval", base.NewSingleFunctionArgument("expression", "round(input.temperature)"), input)
+	assert.Equal(t, r.GetString(), "24")
+}
+
 func TestIfElseInputLogic(t *testing.T) {
 	ctx, ge, _ := helper.SetupEngineWithCommonOperators(t, nil)
 
@@ -392,3 +460,464 @@ func TestIfElseInputLogic(t *testing.T) {
 	assert.Assert(t, outMap["no_echo_main_input_on_fail"].IsError())
 	assert.Assert(t, outMap["no_echo_first_output"].IsError())
 }
+
+func TestConditionAndSwitch(t *testing.T) {
+	ctx, ge, _ := helper.SetupEngineWithCommonOperators(t, nil)
+
+	echo := func(name string, output string) freepsflow.FlowOperationDesc {
+		return freepsflow.FlowOperationDesc{Name: name, Operator: "utils", Function: "echo", Arguments: map[string]string{"output": output}}
+	}
+	sunny := echo("sunny", "sunglasses")
+	rainy := echo("rainy", "umbrella")
+	other := echo("other", "jacket")
+	hot := echo("hot", "water")
+	hot.Condition = "${temp > 25}"
+	warm := echo("warm", "shorts")
+	warm.Condition = "${temp > 20 && _args.outside}"
+	afterRain := freepsflow.FlowOperationDesc{Name: "after_rain", Operator: "utils", Function: "echo", InputFrom: "rainy"}
+	testFlow := freepsflow.FlowDesc{Operations: []freepsflow.FlowOperationDesc{
+		echo("weather", "${_args.weather}"),
+		echo("temp", "23"),
+		{Name: "route", Switch: &freepsflow.SwitchDesc{Value: "${weather}", Cases: map[string]string{"sun": "sunny", "rain": "rainy"}, Default: "other"}},
+		sunny, rainy, other, hot, warm, afterRain,
+	}, Source: "test"}
+
+	for _, tags := range [][]string{{}, {"parallel"}} {
+		testFlow.Tags = tags
+		assert.NilError(t, ge.AddFlow(ctx, "test", testFlow, true))
+
+		out := ge.ExecuteFlow(ctx, "test", base.NewFunctionArguments(map[string]string{"weather": "sun", "outside": "true"}), base.MakeEmptyOutput())
+		outMap := out.GetObject().(map[string]*base.OperatorIO)
+		assert.Equal(t, outMap["route"].GetString(), "sunny")
+		assert.Equal(t, outMap["sunny"].GetString(), "sunglasses")
+		assert.Assert(t, outMap["rainy"].IsSkipped())
+		assert.Assert(t, !outMap["rainy"].IsError())
+		assert.Assert(t, outMap["other"].IsSkipped())
+		assert.Assert(t, outMap["after_rain"].IsSkipped())
+		assert.Assert(t, outMap["hot"].IsSkipped())
+		assert.Equal(t, outMap["hot"].GetSkipReason(), "Condition \"${temp > 25}\" is false")
+		assert.Equal(t, outMap["warm"].GetString(), "shorts")
+
+		out = ge.ExecuteFlow(ctx, "test", base.NewFunctionArguments(map[string]string{"weather": "snow"}), base.MakeEmptyOutput())
+		outMap = out.GetObject().(map[string]*base.OperatorIO)
+		assert.Assert(t, outMap["sunny"].IsSkipped())
+		assert.Equal(t, outMap["other"].GetString(), "jacket")
+		/* _args.outside is missing */
+		assert.Assert(t, outMap["warm"].IsError())
+	}
+
+	/* switches can only route to later operations */
+	invalid := freepsflow.FlowDesc{Operations: []freepsflow.FlowOperationDesc{
+		echo("first", "x"),
+		{Name: "route", Switch: &freepsflow.SwitchDesc{Value: "${first}", Cases: map[string]string{"x": "first"}}},
+	}}
+	assert.ErrorContains(t, ge.AddFlow(ctx, "invalid", invalid, true), "references unknown or previous operation")
+}
+
+// ConcurrencyOperator records how many operations run at the same time, every operation waits until Target operations ran concurrently once
+type ConcurrencyOperator struct {
+	MockOperator
+	Target  int32
+	running atomic.Int32
+	max     atomic.Int32
+	reached chan struct{}
+	once    sync.Once
+}
+
+// GetName returns the name of the operator
+func (*ConcurrencyOperator) GetName() string {
+	return "concurrency"
+}
+
+func (o *ConcurrencyOperator) Execute(ctx *base.Context, fn string, fa base.FunctionArguments, input *base.OperatorIO) *base.OperatorIO {
+	n := o.running.Add(1)
+	defer o.running.Add(-1)
+	for m := o.max.Load(); n > m && !o.max.CompareAndSwap(m, n); m = o.max.Load() {
+	}
+	if n >= o.Target {
+		o.once.Do(func() { close(o.reached) })
+	}
+	select {
+	case <-o.reached:
+	case <-time.After(5 * time.Second):
+		return base.MakeOutputError(http.StatusRequestTimeout, "only %d operations ran concurrently", o.max.Load())
+	}
+	return base.MakePlainOutput(fn)
+}
+
+func newConcurrencyOperator(target int32) *ConcurrencyOperator {
+	return &ConcurrencyOperator{Target: target, reached: make(chan struct{})}
+}
+
+func TestParallelExecution(t *testing.T) {
+	ctx, ge, _ := helper.SetupEngineWithCommonOperators(t, nil)
+
+	testFlow := freepsflow.FlowDesc{Operations: []freepsflow.FlowOperationDesc{
+		{Name: "wait1", Operator: "concurrency", Function: "convert"},
+		{Name: "wait2", Operator: "concurrency", Function: "convert"},
+		{Name: "wait3", Operator: "concurrency", Function: "convert"},
+		{Name: "echo", Operator: "utils", Function: "echo", Arguments: map[string]string{"output": "echo"}},
+		/* depends on the waiting operations and must wait for them */
+		{Name: "after_wait", Operator: "utils", Function: "echo", InputFrom: "echo", ExecuteOnSuccessOf: "wait1", Arguments: map[string]string{"output": "${echo}${wait2}${wait3}"}},
+		{Name: "fail", Operator: "system", Function: "fail"},
+		{Name: "echo_on_fail", Operator: "utils", Function: "echo", Arguments: map[string]string{"output": "echo_on_fail"}, ExecuteOnFailOf: "fail"},
+		{Name: "no_echo_on_success", Operator: "utils", Function: "echo", InputFrom: "_", ExecuteOnSuccessOf: "fail"},
+	}, Tags: []string{"parallel"}, Source: "test"}
+
+	/* all independent operations run at the same time */
+	op := newConcurrencyOperator(3)
+	ge.AddOperator(op)
+	ge.AddFlow(ctx, "test", testFlow, true)
+	out := ge.ExecuteFlow(ctx, "test", base.MakeEmptyFunctionArguments(), base.MakePlainOutput("MainInput"))
+	assert.Equal(t, op.max.Load(), int32(3))
+	outMap := out.GetObject().(map[string]*base.OperatorIO)
+	assert.Equal(t, outMap["after_wait"].GetString(), "echoconvertconvert")
+	assert.Equal(t, outMap["echo_on_fail"].GetString(), "echo_on_fail")
+	assert.Assert(t, outMap["no_echo_on_success"].IsError())
+
+	/* limit the number of concurrent operations */
+	for _, limit := range []int32{1, 2} {
+		op = newConcurrencyOperator(limit)
+		ge.AddOperator(op)
+		testFlow.Tags = []string{fmt.Sprintf("parallel:%d", limit)}
+		ge.AddFlow(ctx, "test", testFlow, true)
+		out = ge.ExecuteFlow(ctx, "test", base.MakeEmptyFunctionArguments(), base.MakePlainOutput("MainInput"))
+		assert.Equal(t, op.max.Load(), limit)
+		outMap = out.GetObject().(map[string]*base.OperatorIO)
+		assert.Equal(t, outMap["after_wait"].GetString(), "echoconvertconvert")
+	}
+}
+
+func TestForEach(t *testing.T) {
+	ctx, ge, _ := helper.SetupEngineWithCommonOperators(t, nil)
+
+	testFlow := freepsflow.FlowDesc{Operations: []freepsflow.FlowOperationDesc{
+		{Name: "list", Operator: "utils", Function: "echo", Arguments: map[string]string{"output": `["a","b","c"]`}},
+		{Name: "map", Operator: "utils", Function: "echo", Arguments: map[string]string{"output": `{"x":{"name":"foo"},"y":{"name":"bar"}}`}},
+		{Name: "noList", Operator: "utils", Function: "echo", Arguments: map[string]string{"output": "nolist"}},
+		{Name: "loopList", Operator: "utils", Function: "echo", ForEach: "list", Arguments: map[string]string{"output": "${index}:${item}"}},
+		{Name: "loopMap", Operator: "utils", Function: "echo", ForEach: "map", Arguments: map[string]string{"output": "${index}:${item.name}"}},
+		/* loop over the results of another loop */
+		{Name: "loopLoop", Operator: "utils", Function: "echo", ForEach: "loopList", Arguments: map[string]string{"output": "${item}!"}},
+		{Name: "loopNoList", Operator: "utils", Function: "echo", ForEach: "noList", Arguments: map[string]string{"output": "${item}"}},
+	}, Source: "test"}
+
+	assert.NilError(t, ge.AddFlow(ctx, "test", testFlow, true))
+	out := ge.ExecuteFlow(ctx, "test", base.MakeEmptyFunctionArguments(), base.MakeEmptyOutput())
+	outMap := out.GetObject().(map[string]*base.OperatorIO)
+
+	loopList := outMap["loopList"].GetObject().(map[string]*base.OperatorIO)
+	assert.Equal(t, len(loopList), 3)
+	assert.Equal(t, loopList["0"].GetString(), "0:a")
+	assert.Equal(t, loopList["2"].GetString(), "2:c")
+
+	loopMap := outMap["loopMap"].GetObject().(map[string]*base.OperatorIO)
+	assert.Equal(t, len(loopMap), 2)
+	assert.Equal(t, loopMap["x"].GetString(), "x:foo")
+	assert.Equal(t, loopMap["y"].GetString(), "y:bar")
+
+	loopLoop := outMap["loopLoop"].GetObject().(map[string]*base.OperatorIO)
+	assert.Equal(t, loopLoop["1"].GetString(), "1:b!")
+
+	assert.Assert(t, outMap["loopNoList"].IsError())
+
+	/* ForEach must reference a previous operation */
+	testFlow.Operations[3].ForEach = "loopMap"
+	assert.Assert(t, ge.AddFlow(ctx, "test", testFlow, true) != nil)
+}
+
+// CancelOperator cancels the context of the flow when it is called
+type CancelOperator struct {
+	MockOperator
+	Calls  int
+	cancel func()
+}
+
+// GetName returns the name of the operator
+func (*CancelOperator) GetName() string {
+	return "cancel"
+}
+
+func (o *CancelOperator) Execute(ctx *base.Context, fn string, fa base.FunctionArguments, input *base.OperatorIO) *base.OperatorIO {
+	o.Calls++
+	o.cancel()
+	return base.MakeEmptyOutput()
+}
+
+func TestForEachCancellation(t *testing.T) {
+	_, ge, _ := helper.SetupEngineWithCommonOperators(t, nil)
+	ctx, cancel := base.NewBaseContext(log.StandardLogger())
+	op := &CancelOperator{cancel: cancel}
+	ge.AddOperator(op)
+
+	testFlow := freepsflow.FlowDesc{Operations: []freepsflow.FlowOperationDesc{
+		{Name: "list", Operator: "utils", Function: "echo", Arguments: map[string]string{"output": `["a","b","c","d"]`}},
+		{Name: "loop", Operator: "cancel", Function: "convert", ForEach: "list"},
+	}, Tags: []string{"flowTimeout:0"}, Source: "test"}
+	assert.NilError(t, ge.AddFlow(ctx, "test", testFlow, true))
+
+	out := ge.ExecuteFlow(ctx, "test", base.MakeEmptyFunctionArguments(), base.MakeEmptyOutput())
+	assert.Equal(t, op.Calls, 1)
+	loop := out.GetObject().(map[string]*base.OperatorIO)["loop"].GetObject().(map[string]*base.OperatorIO)
+	assert.Equal(t, len(loop), 4)
+	for _, k := range []string{"1", "2", "3"} {
+		assert.Equal(t, loop[k].GetStatusCode(), http.StatusServiceUnavailable)
+	}
+}
+
+type FlakyOperator struct {
+	MockOperator
+	Calls     int
+	FailTimes int
+	FailCode  int
+}
+
+// GetName returns the name of the operator
+func (*FlakyOperator) GetName() string {
+	return "flaky"
+}
+
+func (o *FlakyOperator) Execute(ctx *base.Context, fn string, fa base.FunctionArguments, input *base.OperatorIO) *base.OperatorIO {
+	o.Calls++
+	if o.Calls <= o.FailTimes {
+		return base.MakeOutputError(o.FailCode, "call %d failed", o.Calls)
+	}
+	return base.MakeSprintfOutput("call %d succeeded", o.Calls)
+}
+
+func TestRetry(t *testing.T) {
+	ctx, ge, _ := helper.SetupEngineWithCommonOperators(t, nil)
+	flaky := &FlakyOperator{FailTimes: 2, FailCode: 503}
+	ge.AddOperator(flaky)
+
+	retry := &freepsflow.RetryPolicy{MaxAttempts: 3, Backoff: "10ms"}
+	testFlow := freepsflow.FlowDesc{Operations: []freepsflow.FlowOperationDesc{
+		{Name: "flaky", Operator: "flaky", Function: "convert", Retry: retry},
+	}, Source: "test"}
+	assert.NilError(t, ge.AddFlow(ctx, "retrytest", testFlow, true))
+
+	out := ge.ExecuteFlow(ctx, "retrytest", base.MakeEmptyFunctionArguments(), base.MakeEmptyOutput())
+	assert.Equal(t, out.GetString(), "call 3 succeeded")
+	assert.Equal(t, flaky.Calls, 3)
+
+	/* every attempt is logged */
+	logNs, err := freepsstore.GetGlobalStore().GetNamespace("_execution_log")
+	assert.NilError(t, err)
+	attempts := 0
+	for _, e := range logNs.GetSearchResultWithMetadata("", "", "", 0, math.MaxInt64) {
+		entry := freepsstore.ExecutionLogEntry{}
+		assert.NilError(t, e.ParseJSON(&entry))
+		if entry.FlowID == "retrytest" {
+			attempts++
+		}
+	}
+	assert.Equal(t, attempts, 3)
+
+	/* give up after MaxAttempts */
+	flaky.Calls = 0
+	flaky.FailTimes = 5
+	out = ge.ExecuteFlow(ctx, "retrytest", base.MakeEmptyFunctionArguments(), base.MakeEmptyOutput())
+	assert.Assert(t, out.IsError())
+	assert.Equal(t, out.GetError().Error(), "call 3 failed")
+
+	/* codes that are not configured are not retried */
+	flaky.Calls = 0
+	flaky.FailCode = 404
+	out = ge.ExecuteFlow(ctx, "retrytest", base.MakeEmptyFunctionArguments(), base.MakeEmptyOutput())
+	assert.Equal(t, out.GetError().Error(), "call 1 failed")
+
+	retry.RetryOnCodes = []int{404}
+	assert.NilError(t, ge.AddFlow(ctx, "retrytest", testFlow, true))
+	flaky.Calls = 0
+	flaky.FailTimes = 1
+	out = ge.ExecuteFlow(ctx, "retrytest", base.MakeEmptyFunctionArguments(), base.MakeEmptyOutput())
+	assert.Equal(t, out.GetString(), "call 2 succeeded")
+
+	retry.Backoff = "soon"
+	assert.Assert(t, ge.AddFlow(ctx, "retrytest", testFlow, true) != nil)
+}
+
+func TestAsyncExecution(t *testing.T) {
+	ctx, ge, cr := helper.SetupEngineWithCommonOperators(t, nil)
+	ge.AddOperators(base.MakeFreepsOperators(&optime.OpTime{GE: ge}, cr, ctx))
+
+	/* the first operation waits until the test has checked that the job is still running */
+	blocker := newConcurrencyOperator(2)
+	ge.AddOperator(blocker)
+	testFlow := freepsflow.FlowDesc{Operations: []freepsflow.FlowOperationDesc{
+		{Name: "wait", Operator: "concurrency", Function: "convert"},
+		{Name: "echo", Operator: "utils", Function: "echo", Arguments: map[string]string{"output": "${_args.foo}"}},
+	}, OutputFrom: "echo", Source: "test"}
+	assert.NilError(t, ge.AddFlow(ctx, "test", testFlow, true))
+	sleepFlow := freepsflow.FlowDesc{Operations: []freepsflow.FlowOperationDesc{
+		{Name: "sleep", Operator: "time", Function: "sleep", Arguments: map[string]string{"Duration": "100ms"}},
+	}, Source: "test"}
+	assert.NilError(t, ge.AddFlow(ctx, "sleep", sleepFlow, true))
+
+	waitForJob := func(jobID string) freepsflow.JobStatus {
+		for i := 0; i < 100; i++ {
+			status, found := ge.GetJobStatus(jobID)
+			assert.Assert(t, found)
+			if status.IsDone() {
+				return status
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatalf("job %v did not finish", jobID)
+		return freepsflow.JobStatus{}
+	}
+
+	out := ge.ExecuteOperatorByName(ctx, "flow", "test", base.NewFunctionArguments(map[string]string{"async": "true", "foo": "bar"}), base.MakeEmptyOutput())
+	assert.Assert(t, !out.IsError(), out.GetString())
+	job := out.GetObject().(freepsflow.JobStatus)
+	assert.Equal(t, job.State, freepsflow.JobRunning)
+
+	out = ge.ExecuteOperatorByName(ctx, "system", "GetJobStatus", base.NewSingleFunctionArgument("jobID", job.ID), base.MakeEmptyOutput())
+	assert.Equal(t, out.GetObject().(freepsflow.JobStatus).ID, job.ID)
+	assert.Equal(t, out.GetObject().(freepsflow.JobStatus).State, freepsflow.JobRunning)
+	blocker.once.Do(func() { close(blocker.reached) })
+
+	status := waitForJob(job.ID)
+	assert.Equal(t, status.State, freepsflow.JobFinished)
+	assert.Equal(t, status.ExecutedOperations, 2)
+	assert.Equal(t, status.Output.GetString(), "bar")
+	stored := freepsstore.GetGlobalStore().GetNamespaceNoError("_jobs").GetValue(job.ID)
+	assert.Assert(t, !stored.IsError())
+	storedStatus := freepsflow.JobStatus{}
+	assert.NilError(t, stored.ParseJSON(&storedStatus))
+	assert.Equal(t, storedStatus.State, freepsflow.JobFinished)
+
+	/* async is only consumed if it is a bool */
+	echoFlow := freepsflow.FlowDesc{Operations: []freepsflow.FlowOperationDesc{
+		{Name: "echo", Operator: "utils", Function: "echo", Arguments: map[string]string{"output": "${_args.async}"}},
+	}, Source: "test"}
+	assert.NilError(t, ge.AddFlow(ctx, "echoAsync", echoFlow, true))
+	out = ge.ExecuteOperatorByName(ctx, "flow", "echoAsync", base.NewSingleFunctionArgument("async", "later"), base.MakeEmptyOutput())
+	assert.Assert(t, !out.IsError(), out.GetString())
+	assert.Equal(t, out.GetString(), "later")
+	out = ge.ExecuteOperatorByName(ctx, "flow", "echoAsync", base.NewSingleFunctionArgument("async", "false"), base.MakeEmptyOutput())
+	assert.Assert(t, out.IsError(), "async=false must be consumed and run the flow synchronously")
+	assert.Assert(t, ge.ExecuteOperatorByName(ctx, "flow", "ExecuteAsync", base.NewSingleFunctionArgument("flowID", "sleep"), base.MakeEmptyOutput()).IsError())
+
+	/* cancel a running job */
+	out = ge.ExecuteOperatorByName(ctx, "system", "ExecuteAsync", base.NewSingleFunctionArgument("flowID", "sleep"), base.MakeEmptyOutput())
+	job = out.GetObject().(freepsflow.JobStatus)
+	out = ge.ExecuteOperatorByName(ctx, "system", "CancelJob", base.NewSingleFunctionArgument("jobID", job.ID), base.MakeEmptyOutput())
+	assert.Assert(t, !out.IsError(), out.GetString())
+	status = waitForJob(job.ID)
+	assert.Equal(t, status.State, freepsflow.JobCancelled)
+	assert.Assert(t, status.Output.IsError())
+	assert.Assert(t, ge.CancelJob(ctx, job.ID).IsError())
+	assert.Equal(t, ge.CancelJob(ctx, "unknown").GetStatusCode(), http.StatusNotFound)
+}
+
+type otlpSpan struct {
+	Name         string `json:"name"`
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Status       struct {
+		Code int `json:"code"`
+	} `json:"status"`
+}
+
+type otlpRequest struct {
+	ResourceSpans []struct {
+		ScopeSpans []struct {
+			Spans []otlpSpan `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+func TestTracing(t *testing.T) {
+	exported := make(chan []byte, 10)
+	otlpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		exported <- b
+	}))
+	defer otlpServer.Close()
+	ctx, ge, _ := helper.SetupEngineWithCommonOperators(t, map[string]interface{}{"flows": freepsflow.FlowEngineConfig{AlertDuration: time.Hour, MaxTraces: 10, OTLPEndpoint: otlpServer.URL}})
+
+	inner := freepsflow.FlowDesc{Operations: []freepsflow.FlowOperationDesc{
+		{Name: "echo", Operator: "utils", Function: "echo", Arguments: map[string]string{"output": "inner"}},
+		{Name: "fail", Operator: "utils", Function: "doesNotExist"},
+	}, OutputFrom: "echo", Source: "test"}
+	outer := freepsflow.FlowDesc{Operations: []freepsflow.FlowOperationDesc{
+		{Name: "callInner", Operator: "flow", Function: "inner"},
+		{Name: "skipped", Operator: "utils", Function: "echo", Condition: "${callInner == \"outer\"}"},
+	}, Source: "test"}
+	assert.NilError(t, ge.AddFlow(ctx, "inner", inner, true))
+	assert.NilError(t, ge.AddFlow(ctx, "outer", outer, true))
+
+	execCtx := base.CreateContextWithField(ctx, "test", "tracing", "test")
+	ge.ExecuteFlow(execCtx, "outer", base.MakeEmptyFunctionArguments(), base.MakeEmptyOutput())
+
+	spans, found := ge.GetTrace(execCtx.GetID())
+	assert.Assert(t, found)
+	assert.Equal(t, len(spans), 6)
+	byName := map[string]freepsflow.Span{}
+	for _, s := range spans {
+		assert.Equal(t, s.TraceID, execCtx.GetID())
+		assert.Assert(t, !s.End.Before(s.Start))
+		byName[s.Name] = s
+	}
+	assert.Equal(t, byName["outer"].Kind, freepsflow.SpanKindFlow)
+	assert.Equal(t, byName["outer"].ParentSpanID, "")
+	assert.Equal(t, spans[0].SpanID, byName["outer"].SpanID)
+	assert.Equal(t, byName["callInner"].ParentSpanID, byName["outer"].SpanID)
+	assert.Equal(t, byName["callInner"].Operator, "flow")
+	assert.Equal(t, byName["inner"].ParentSpanID, byName["callInner"].SpanID)
+	assert.Equal(t, byName["echo"].ParentSpanID, byName["inner"].SpanID)
+	assert.Equal(t, byName["echo"].Status, freepsflow.SpanOK)
+	assert.Equal(t, byName["fail"].Status, freepsflow.SpanError)
+	assert.Assert(t, byName["fail"].StatusCode >= 400)
+	assert.Equal(t, byName["skipped"].Status, freepsflow.SpanSkipped)
+
+	summaries := ge.GetTraces()
+	assert.Equal(t, summaries[0].TraceID, execCtx.GetID())
+	assert.Equal(t, summaries[0].Name, "outer")
+	assert.Equal(t, summaries[0].NumberOfSpans, 6)
+
+	out := ge.ExecuteOperatorByName(ctx, "system", "GetTrace", base.NewFunctionArguments(map[string]string{"traceID": execCtx.GetID(), "format": "otlp"}), base.MakeEmptyOutput())
+	assert.Assert(t, !out.IsError(), out.GetString())
+	otlp := otlpRequest{}
+	assert.NilError(t, out.ParseJSON(&otlp))
+	otlpSpans := otlp.ResourceSpans[0].ScopeSpans[0].Spans
+	assert.Equal(t, len(otlpSpans), 6)
+	assert.Equal(t, len(otlpSpans[0].TraceID), 32)
+	assert.Equal(t, otlpSpans[0].ParentSpanID, "")
+	assert.Equal(t, otlpSpans[1].ParentSpanID, otlpSpans[0].SpanID)
+
+	/* every root flow exports its own spans only, even if the context executes several of them */
+	receiveExport := func() []otlpSpan {
+		for {
+			select {
+			case b := <-exported:
+				r := otlpRequest{}
+				assert.NilError(t, json.Unmarshal(b, &r))
+				spans := r.ResourceSpans[0].ScopeSpans[0].Spans
+				// operators executed by the test are traced as well
+				if spans[0].Name == "outer" {
+					return spans
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("trace was not exported")
+				return nil
+			}
+		}
+	}
+	first := receiveExport()
+	assert.Equal(t, len(first), 6)
+	ge.ExecuteFlow(execCtx, "outer", base.MakeEmptyFunctionArguments(), base.MakeEmptyOutput())
+	second := receiveExport()
+	assert.Equal(t, len(second), 6)
+	for _, s := range second {
+		for _, f := range first {
+			assert.Assert(t, s.SpanID != f.SpanID)
+		}
+	}
+	spans, _ = ge.GetTrace(execCtx.GetID())
+	assert.Equal(t, len(spans), 12)
+
+	assert.Equal(t, ge.ExecuteOperatorByName(ctx, "system", "GetTrace", base.NewSingleFunctionArgument("traceID", "unknown"), base.MakeEmptyOutput()).GetStatusCode(), http.StatusNotFound)
+}
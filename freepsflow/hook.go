@@ -27,6 +27,10 @@ type FreepsAlertHook interface {
 	OnResetSystemAlert(ctx *base.Context, name string, category string) error
 }
 
+type FreepsJobHook interface {
+	OnJobChanged(ctx *base.Context, status JobStatus) error
+}
+
 type FreepsHookWrapper struct {
 	hookImpl interface{}
 }
@@ -35,6 +39,7 @@ var _ FlowEngineHook = &FreepsHookWrapper{}
 var _ FreepsExecutionHook = &FreepsHookWrapper{}
 var _ FreepsFlowChangedHook = &FreepsHookWrapper{}
 var _ FreepsAlertHook = &FreepsHookWrapper{}
+var _ FreepsJobHook = &FreepsHookWrapper{}
 
 func NewFreepsHookWrapper(hookImpl interface{}) *FreepsHookWrapper {
 	return &FreepsHookWrapper{hookImpl: hookImpl}
@@ -96,3 +101,11 @@ func (h *FreepsHookWrapper) OnResetSystemAlert(ctx *base.Context, name string, c
 	}
 	return nil
 }
+
+func (h *FreepsHookWrapper) OnJobChanged(ctx *base.Context, status JobStatus) error {
+	i, ok := h.hookImpl.(FreepsJobHook)
+	if ok {
+		return i.OnJobChanged(ctx, status)
+	}
+	return nil
+}
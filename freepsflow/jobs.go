@@ -0,0 +1,194 @@
+package freepsflow
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+)
+
+// JobRetention is the time the status of a finished job is kept in memory
+const JobRetention = time.Hour
+
+// states of an asynchronous flow execution
+const (
+	JobRunning   = "running"
+	JobFinished  = "finished"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// JobStatus describes the progress and result of an asynchronous flow execution
+type JobStatus struct {
+	ID                  string
+	FlowID              string
+	State               string
+	Started             time.Time
+	Finished            *time.Time       `json:",omitempty"`
+	ExecutedOperations  int              // number of operations that finished so far
+	NumberOfOperations  int              // number of operations in the flow
+	Output              *base.OperatorIO `json:",omitempty"`
+	CancellationRequest bool             `json:",omitempty"`
+}
+
+// IsDone returns true if the job is not running anymore
+func (s JobStatus) IsDone() bool {
+	return s.State != JobRunning
+}
+
+type flowJob struct {
+	status JobStatus
+	cancel context.CancelFunc
+	lock   sync.Mutex
+}
+
+func (j *flowJob) getStatus() JobStatus {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.status
+}
+
+// removeExpiredJobs removes jobs that finished more than JobRetention ago, the caller needs to hold the jobLock
+func (ge *FlowEngine) removeExpiredJobs(now time.Time) {
+	for id, j := range ge.jobs {
+		s := j.getStatus()
+		if s.Finished != nil && now.Sub(*s.Finished) > JobRetention {
+			delete(ge.jobs, id)
+		}
+	}
+}
+
+// ExecuteFlowAsync starts the execution of a flow in the background and returns the JobStatus with the ID of the job immediately
+func (ge *FlowEngine) ExecuteFlowAsync(ctx *base.Context, flowName string, mainArgs base.FunctionArguments, mainInput *base.OperatorIO) *base.OperatorIO {
+	g, o := ge.prepareFlowExecution(ctx, flowName)
+	if g == nil {
+		return o
+	}
+
+	jobCtx, cancel := ctx.DetachedChildContext("job", flowName, fmt.Sprintf("Asynchronous execution of \"%v\"", flowName))
+	j := &flowJob{cancel: cancel, status: JobStatus{ID: jobCtx.GetID(), FlowID: flowName, State: JobRunning, Started: time.Now(), NumberOfOperations: len(g.desc.Operations)}}
+	g.onOperationFinished = func() {
+		j.lock.Lock()
+		if j.status.IsDone() {
+			// the flow was cancelled or timed out but the current operation still finished
+			j.lock.Unlock()
+			return
+		}
+		j.status.ExecutedOperations++
+		j.lock.Unlock()
+		ge.TriggerJobChangedHooks(jobCtx, j.getStatus())
+	}
+
+	ge.jobLock.Lock()
+	ge.removeExpiredJobs(time.Now())
+	ge.jobs[j.status.ID] = j
+	ge.jobLock.Unlock()
+
+	ctx.GetLogger().Debugf("Started job \"%v\" for flow \"%v\"", j.status.ID, flowName)
+	status := j.getStatus()
+	ge.TriggerJobChangedHooks(jobCtx, status)
+
+	go func() {
+		defer cancel()
+		ge.TriggerOnExecuteHooks(jobCtx, flowName, mainArgs, mainInput)
+		output := g.execute(jobCtx, mainArgs, mainInput)
+		ge.TriggerOnExecutionFinishedHooks(jobCtx, flowName, mainArgs, mainInput)
+
+		finished := time.Now()
+		j.lock.Lock()
+		j.status.Output = output
+		j.status.Finished = &finished
+		if j.status.CancellationRequest {
+			j.status.State = JobCancelled
+		} else if output.IsError() {
+			j.status.State = JobFailed
+		} else {
+			j.status.State = JobFinished
+		}
+		j.lock.Unlock()
+		ge.TriggerJobChangedHooks(jobCtx, j.getStatus())
+	}()
+
+	return base.MakeObjectOutput(status)
+}
+
+// GetJobStatus returns the status of a job that is running or finished recently
+func (ge *FlowEngine) GetJobStatus(jobID string) (JobStatus, bool) {
+	ge.jobLock.Lock()
+	defer ge.jobLock.Unlock()
+	j, ok := ge.jobs[jobID]
+	if !ok {
+		return JobStatus{}, false
+	}
+	return j.getStatus(), true
+}
+
+// GetJobs returns the status of all jobs in memory ordered by start time
+func (ge *FlowEngine) GetJobs() []JobStatus {
+	ge.jobLock.Lock()
+	r := make([]JobStatus, 0, len(ge.jobs))
+	for _, j := range ge.jobs {
+		r = append(r, j.getStatus())
+	}
+	ge.jobLock.Unlock()
+	sort.Slice(r, func(a, b int) bool { return r[a].Started.Before(r[b].Started) })
+	return r
+}
+
+// CancelJob cancels the context of a running job, the flow stops before the next operation is executed
+func (ge *FlowEngine) CancelJob(ctx *base.Context, jobID string) *base.OperatorIO {
+	ge.jobLock.Lock()
+	j, ok := ge.jobs[jobID]
+	ge.jobLock.Unlock()
+	if !ok {
+		return base.MakeOutputError(http.StatusNotFound, "No job with ID \"%v\"", jobID)
+	}
+
+	j.lock.Lock()
+	if j.status.State != JobRunning {
+		j.lock.Unlock()
+		return base.MakeOutputError(http.StatusConflict, "Job \"%v\" is not running anymore", jobID)
+	}
+	j.status.CancellationRequest = true
+	j.lock.Unlock()
+
+	ctx.GetLogger().Infof("Cancelling job \"%v\"", jobID)
+	j.cancel()
+	return base.MakeObjectOutput(j.getStatus())
+}
+
+// cancelAllJobs cancels all running jobs on shutdown
+func (ge *FlowEngine) cancelAllJobs(ctx *base.Context) {
+	ge.jobLock.Lock()
+	ids := make([]string, 0, len(ge.jobs))
+	for id, j := range ge.jobs {
+		if !j.getStatus().IsDone() {
+			ids = append(ids, id)
+		}
+	}
+	ge.jobLock.Unlock()
+	for _, id := range ids {
+		ge.CancelJob(ctx, id)
+	}
+}
+
+// TriggerJobChangedHooks informs hooks about the progress of asynchronous flow executions
+func (ge *FlowEngine) TriggerJobChangedHooks(ctx *base.Context, status JobStatus) {
+	hooks := ge.getHookMapCopy()
+
+	for name, h := range hooks {
+		fh, ok := h.(FreepsJobHook)
+		if !ok {
+			continue
+		}
+		err := fh.OnJobChanged(ctx, status)
+		if err != nil {
+			upErr := fmt.Errorf("Execution of JobHook \"%v\" failed with error: %v", name, err.Error())
+			ge.SetSystemAlert(ctx, "JobHook"+name, "system", 3, upErr, &ge.config.AlertDuration)
+		}
+	}
+}
@@ -26,11 +26,12 @@ type OpEval struct {
 var _ base.FreepsBaseOperator = &OpEval{}
 
 type EvalArgs struct {
-	ValueName string
-	ValueType string
-	Operation string
-	Operand   interface{}
-	Output    string
+	Expression string
+	ValueName  string
+	ValueType  string
+	Operation  string
+	Operand    interface{}
+	Output     string
 }
 
 type DedupArgs struct {
@@ -137,7 +138,7 @@ func (m *OpEval) GetPossibleArgs(fn string) []string {
 	if fn == "dedup" {
 		return []string{"retention"}
 	}
-	ret := []string{"valueName", "valueType", "operation", "operand"}
+	ret := []string{"expression", "valueName", "valueType", "operation", "operand", "output"}
 	return ret
 }
 
@@ -152,6 +153,10 @@ func (m *OpEval) GetArgSuggestions(fn string, arg string, otherArgs base.Functio
 		return map[string]string{"eq": "eq", "gt": "gt", "lt": "lt", "id": "id"}
 	case "retention":
 		return utils.GetDurationMap()
+	case "expression":
+		return map[string]string{"input > 20": "input > 20", "input.temperature >= 20 && input.temperature < 25": "input.temperature >= 20 && input.temperature < 25", "upper(input ?? \"off\")": "upper(input ?? \"off\")"}
+	case "output":
+		return map[string]string{"input": "input", "empty": "empty"}
 	}
 	return map[string]string{}
 }
@@ -173,6 +178,9 @@ func (m *OpEval) Flatten(vars map[string]string, input *base.OperatorIO) *base.O
 func (m *OpEval) Eval(vars map[string]string, input *base.OperatorIO) *base.OperatorIO {
 	var args EvalArgs
 	err := utils.ArgsMapToObject(vars, &args)
+	if err == nil && args.Expression != "" {
+		return m.EvalExpression(args, vars, input)
+	}
 	if err != nil || args.ValueName == "" || args.ValueType == "" {
 		return base.MakeOutputError(http.StatusBadRequest, "Missing args")
 	}
@@ -232,6 +240,34 @@ func (m *OpEval) Eval(vars map[string]string, input *base.OperatorIO) *base.Oper
 	return base.MakeOutputError(http.StatusExpectationFailed, "Eval %v resulted in false", vars)
 }
 
+// EvalExpression evaluates args.Expression with the same evaluator that is used for ${...} in flow arguments,
+// "input" refers to the input and "_args" to the arguments; a boolean result behaves like the comparison of Eval
+func (m *OpEval) EvalExpression(args EvalArgs, vars map[string]string, input *base.OperatorIO) *base.OperatorIO {
+	result, err := EvaluateExpression(args.Expression, func(name string) (interface{}, bool) {
+		switch name {
+		case "input":
+			return input, true
+		case "_args":
+			return base.NewFunctionArguments(vars), true
+		}
+		return nil, false
+	})
+	if err != nil {
+		return base.MakeOutputError(http.StatusBadRequest, "Cannot evaluate \"%v\": %v", args.Expression, err)
+	}
+	b, isBool := result.(bool)
+	if !isBool {
+		return ExpressionValueToOutput(result)
+	}
+	if !b {
+		return base.MakeOutputError(http.StatusExpectationFailed, "Eval %v resulted in false", args.Expression)
+	}
+	if args.Output == "input" {
+		return input
+	}
+	return base.MakeEmptyOutput()
+}
+
 func (m *OpEval) EvalInt(vInterface interface{}, op string, v2Interface interface{}) (bool, error) {
 	v, err := utils.ConvertToInt64(vInterface)
 	if err != nil {
@@ -2,9 +2,11 @@ package freepsflow
 
 import (
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/hannesrauhe/freeps/base"
+	"github.com/hannesrauhe/freeps/utils"
 )
 
 type OpFlow struct {
@@ -22,14 +24,29 @@ func (o *OpFlow) Execute(ctx *base.Context, fn string, fa base.FunctionArguments
 	if input.IsError() { // flow has been called by another operator, but the operator returned an error
 		return input
 	}
+	// async is only consumed if it is a bool, other values are passed to the flow
+	if async, err := strconv.ParseBool(fa.Get("async")); fa.Has("async") && err == nil {
+		if async {
+			return o.ge.ExecuteFlowAsync(ctx, fn, removeArgument(fa, "async"), input)
+		}
+		fa = removeArgument(fa, "async")
+	}
 	return o.ge.ExecuteFlow(ctx, fn, fa, input)
 }
 
 func (o *OpFlow) ExecuteOld(ctx *base.Context, fn string, args map[string]string, input *base.OperatorIO) *base.OperatorIO {
-	if input.IsError() { // flow has been called by another operator, but the operator returned an error
-		return input
+	return o.Execute(ctx, fn, base.NewFunctionArguments(args), input)
+}
+
+// removeArgument returns a copy of fa without the given argument
+func removeArgument(fa base.FunctionArguments, arg string) base.FunctionArguments {
+	m := map[string][]string{}
+	for k, v := range fa.GetOriginalCaseMap() {
+		if !utils.StringEqualsIgnoreCase(k, arg) {
+			m[k] = v
+		}
 	}
-	return o.ge.ExecuteFlow(ctx, fn, base.NewFunctionArguments(args), input)
+	return base.NewFunctionArgumentsFromURLValues(m)
 }
 
 // GetName returns the name of the operator
@@ -54,7 +71,7 @@ func (o *OpFlow) GetPossibleArgs(fn string) []string {
 	if !exists {
 		return []string{}
 	}
-	possibleArgs := make([]string, 0)
+	possibleArgs := []string{"async"}
 	for _, op := range agd.Operations {
 		if !op.UseMainArgs {
 			continue
@@ -67,6 +84,9 @@ func (o *OpFlow) GetPossibleArgs(fn string) []string {
 
 // GetArgSuggestions returns suggestions based on the suggestions of the operators in the flow
 func (o *OpFlow) GetArgSuggestions(fn string, arg string, otherArgs base.FunctionArguments) map[string]string {
+	if arg == "async" {
+		return map[string]string{"true": "true", "false": "false"}
+	}
 	agd, exists := o.ge.GetFlowDesc(fn)
 	if !exists {
 		return map[string]string{}
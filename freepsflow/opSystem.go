@@ -2,8 +2,10 @@ package freepsflow
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/hannesrauhe/freeps/base"
 	"github.com/hannesrauhe/freeps/utils"
@@ -26,6 +28,13 @@ func (o *OpSystem) GetName() string {
 }
 
 func (o *OpSystem) Execute(ctx *base.Context, fn string, fa base.FunctionArguments, input *base.OperatorIO) *base.OperatorIO {
+	if fn == "ExecuteAsync" {
+		// all other arguments are passed to the flow unchanged
+		if !fa.Has("flowID") {
+			return base.MakeOutputError(http.StatusBadRequest, "Missing argument flowID")
+		}
+		return o.ge.ExecuteFlowAsync(ctx, fa.Get("flowID"), removeArgument(fa, "flowID"), input)
+	}
 	return o.ExecuteOld(ctx, fn, fa.GetOriginalCaseMapJoined(), input)
 }
 
@@ -65,12 +74,44 @@ func (o *OpSystem) ExecuteOld(ctx *base.Context, fn string, args map[string]stri
 
 	case "version":
 		return base.MakePlainOutput(utils.BuildFullVersion())
+
+	case "GetTraces":
+		return base.MakeObjectOutput(o.ge.GetTraces())
+
+	case "GetTrace":
+		spans, ok := o.ge.GetTrace(args["traceID"])
+		if !ok {
+			return base.MakeOutputError(http.StatusNotFound, "No trace with ID \"%v\"", args["traceID"])
+		}
+		switch args["format"] {
+		case "", "spans":
+			return base.MakeObjectOutput(spans)
+		case "otlp":
+			return base.MakeObjectOutput(SpansToOTLP(spans))
+		}
+		return base.MakeOutputError(http.StatusBadRequest, "Unknown format \"%v\", must be \"spans\" or \"otlp\"", args["format"])
+
+	case "GetJobStatus":
+		if args["jobID"] == "" {
+			return base.MakeObjectOutput(o.ge.GetJobs())
+		}
+		status, found := o.ge.GetJobStatus(args["jobID"])
+		if !found {
+			return base.MakeOutputError(http.StatusNotFound, "No job with ID \"%v\"", args["jobID"])
+		}
+		return base.MakeObjectOutput(status)
+
+	case "CancelJob":
+		if args["jobID"] == "" {
+			return base.MakeOutputError(http.StatusBadRequest, "Missing argument jobID")
+		}
+		return o.ge.CancelJob(ctx, args["jobID"])
 	}
 	return base.MakeOutputError(http.StatusBadRequest, "Unknown function: %v", fn)
 }
 
 func (o *OpSystem) GetFunctions() []string {
-	return []string{"shutdown", "reload", "stats", "getFlowDesc", "getFlowInfo", "getFlowDescByTag", "deleteFlow", "version", "metrics"}
+	return []string{"shutdown", "reload", "stats", "getFlowDesc", "getFlowInfo", "getFlowDescByTag", "deleteFlow", "version", "metrics", "GetTraces", "GetTrace", "ExecuteAsync", "GetJobStatus", "CancelJob"}
 }
 
 func (o *OpSystem) GetPossibleArgs(fn string) []string {
@@ -85,6 +126,14 @@ func (o *OpSystem) GetPossibleArgs(fn string) []string {
 		return []string{"tags", "tag"}
 	case "deleteFlow":
 		return []string{"name"}
+	case "GetTraces":
+		return []string{}
+	case "GetTrace":
+		return []string{"traceID", "format"}
+	case "ExecuteAsync":
+		return []string{"flowID"}
+	case "GetJobStatus", "CancelJob":
+		return []string{"jobID"}
 	}
 	return []string{"name"}
 }
@@ -129,6 +178,35 @@ func (o *OpSystem) GetArgSuggestions(fn string, arg string, otherArgs base.Funct
 			tags := o.ge.GetTags()
 			return tags
 		}
+	case "ExecuteAsync":
+		switch arg {
+		case "flowID":
+			flows := map[string]string{}
+			for f := range o.ge.GetAllFlowDesc() {
+				flows[f] = f
+			}
+			return flows
+		}
+	case "GetJobStatus", "CancelJob":
+		switch arg {
+		case "jobID":
+			jobs := map[string]string{}
+			for _, j := range o.ge.GetJobs() {
+				jobs[fmt.Sprintf("%v (%v, %v)", j.FlowID, j.State, j.Started.Format(time.Kitchen))] = j.ID
+			}
+			return jobs
+		}
+	case "GetTrace":
+		switch arg {
+		case "traceID":
+			traces := map[string]string{}
+			for _, t := range o.ge.GetTraces() {
+				traces[fmt.Sprintf("%v (%v)", t.Name, t.TraceID)] = t.TraceID
+			}
+			return traces
+		case "format":
+			return map[string]string{"spans": "spans", "otlp": "otlp"}
+		}
 	}
 	return map[string]string{}
 }
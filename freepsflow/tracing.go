@@ -0,0 +1,266 @@
+package freepsflow
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hannesrauhe/freeps/base"
+)
+
+// MaxSpansPerTrace limits the number of spans kept for a single trace, contexts that are reused for many executions would grow indefinitely otherwise
+const MaxSpansPerTrace = 1000
+
+// kinds of spans
+const (
+	SpanKindFlow      = "flow"
+	SpanKindOperation = "operation"
+)
+
+// states of a finished span
+const (
+	SpanOK      = "ok"
+	SpanError   = "error"
+	SpanSkipped = "skipped"
+)
+
+// Span is the timing information of a single flow or operation execution, all spans of one execution tree share the TraceID (the ID of the context)
+type Span struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string `json:",omitempty"`
+	Kind         string
+	Name         string
+	FlowID       string
+	Operator     string `json:",omitempty"`
+	Function     string `json:",omitempty"`
+	Start        time.Time
+	End          time.Time
+	StatusCode   int
+	Status       string
+	Error        string `json:",omitempty"`
+}
+
+// Duration returns the time between start and end of the span
+func (s Span) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// TraceSummary describes a trace without the single spans
+type TraceSummary struct {
+	TraceID       string
+	Name          string
+	Start         time.Time
+	Duration      time.Duration
+	StatusCode    int
+	Status        string
+	NumberOfSpans int
+	DroppedSpans  int `json:",omitempty"`
+}
+
+type trace struct {
+	spans   []Span
+	dropped int
+}
+
+func (t *trace) summary(traceID string) TraceSummary {
+	s := TraceSummary{TraceID: traceID, NumberOfSpans: len(t.spans), DroppedSpans: t.dropped}
+	var end time.Time
+	for _, span := range t.spans {
+		if s.Start.IsZero() || span.Start.Before(s.Start) {
+			s.Start = span.Start
+			s.Name = span.Name
+			s.StatusCode = span.StatusCode
+			s.Status = span.Status
+		}
+		if span.End.After(end) {
+			end = span.End
+		}
+	}
+	s.Duration = end.Sub(s.Start)
+	return s
+}
+
+// subtree returns the span with the given ID and all spans below it
+func (t *trace) subtree(rootSpanID string) []Span {
+	children := map[string][]Span{}
+	var root *Span
+	for i, span := range t.spans {
+		if span.SpanID == rootSpanID {
+			root = &t.spans[i]
+			continue
+		}
+		children[span.ParentSpanID] = append(children[span.ParentSpanID], span)
+	}
+	if root == nil {
+		return nil
+	}
+	r := []Span{*root}
+	for i := 0; i < len(r); i++ {
+		r = append(r, children[r[i].SpanID]...)
+	}
+	return r
+}
+
+func newSpanID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// startSpan returns a context that makes the new span the parent of all spans started with it, the span is nil if tracing is disabled
+func (ge *FlowEngine) startSpan(ctx *base.Context, kind string, name string, flowID string, opDesc *FlowOperationDesc) (*base.Context, *Span) {
+	if ge.config.MaxTraces <= 0 {
+		return ctx, nil
+	}
+	span := &Span{TraceID: ctx.GetID(), SpanID: newSpanID(), ParentSpanID: ctx.GetSpanID(), Kind: kind, Name: name, FlowID: flowID, Start: time.Now()}
+	if opDesc != nil {
+		span.Operator = opDesc.Operator
+		span.Function = opDesc.Function
+	}
+	return ctx.ChildContextWithSpan(span.SpanID), span
+}
+
+// finishSpan records the result of the span and exports the trace once the root span is finished
+func (ge *FlowEngine) finishSpan(ctx *base.Context, span *Span, output *base.OperatorIO) {
+	if span == nil {
+		return
+	}
+	span.End = time.Now()
+	span.StatusCode = output.HTTPCode
+	span.Status = SpanOK
+	if output.IsSkipped() {
+		span.Status = SpanSkipped
+	} else if output.IsError() {
+		span.Status = SpanError
+		span.Error = output.GetError().Error()
+	}
+
+	ge.traceLock.Lock()
+	t, exists := ge.traces[span.TraceID]
+	if !exists {
+		t = &trace{}
+		ge.traces[span.TraceID] = t
+		ge.traceOrder = append(ge.traceOrder, span.TraceID)
+		for len(ge.traceOrder) > ge.config.MaxTraces {
+			delete(ge.traces, ge.traceOrder[0])
+			ge.traceOrder = ge.traceOrder[1:]
+		}
+	}
+	if len(t.spans) < MaxSpansPerTrace {
+		t.spans = append(t.spans, *span)
+	} else {
+		t.dropped++
+	}
+	var spans []Span
+	if span.ParentSpanID == "" && ge.config.OTLPEndpoint != "" {
+		// a context might execute several root flows, the spans of the previous ones have been exported already
+		spans = t.subtree(span.SpanID)
+	}
+	ge.traceLock.Unlock()
+
+	if spans != nil {
+		go ge.exportTrace(ctx, spans)
+	}
+}
+
+// GetTrace returns all spans of a trace ordered by their start time
+func (ge *FlowEngine) GetTrace(traceID string) ([]Span, bool) {
+	ge.traceLock.Lock()
+	t, exists := ge.traces[traceID]
+	if !exists {
+		ge.traceLock.Unlock()
+		return nil, false
+	}
+	spans := make([]Span, len(t.spans))
+	copy(spans, t.spans)
+	ge.traceLock.Unlock()
+
+	sort.SliceStable(spans, func(a, b int) bool { return spans[a].Start.Before(spans[b].Start) })
+	return spans, true
+}
+
+// GetTraces returns summaries of all traces in memory, the most recent first
+func (ge *FlowEngine) GetTraces() []TraceSummary {
+	ge.traceLock.Lock()
+	r := make([]TraceSummary, 0, len(ge.traceOrder))
+	for i := len(ge.traceOrder) - 1; i >= 0; i-- {
+		id := ge.traceOrder[i]
+		r = append(r, ge.traces[id].summary(id))
+	}
+	ge.traceLock.Unlock()
+	return r
+}
+
+// exportTrace sends the spans to the configured OTLP/HTTP endpoint
+func (ge *FlowEngine) exportTrace(ctx *base.Context, spans []Span) {
+	b, err := json.Marshal(SpansToOTLP(spans))
+	if err == nil {
+		var resp *http.Response
+		resp, err = http.Post(ge.config.OTLPEndpoint, "application/json", bytes.NewReader(b))
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				err = fmt.Errorf("OTLP endpoint returned status %v", resp.Status)
+			}
+		}
+	}
+	if err != nil {
+		ge.SetSystemAlert(ctx, "TraceExport", "system", 3, fmt.Errorf("Exporting trace failed: %v", err), &ge.config.AlertDuration)
+	}
+}
+
+func otlpAttribute(key string, value string) map[string]interface{} {
+	return map[string]interface{}{"key": key, "value": map[string]interface{}{"stringValue": value}}
+}
+
+// SpansToOTLP converts spans to the JSON encoding of an OTLP ExportTraceServiceRequest
+func SpansToOTLP(spans []Span) map[string]interface{} {
+	otlpSpans := make([]map[string]interface{}, 0, len(spans))
+	for _, s := range spans {
+		attributes := []map[string]interface{}{
+			otlpAttribute("freeps.kind", s.Kind),
+			otlpAttribute("freeps.flow", s.FlowID),
+			{"key": "http.status_code", "value": map[string]interface{}{"intValue": fmt.Sprint(s.StatusCode)}},
+		}
+		if s.Operator != "" {
+			attributes = append(attributes, otlpAttribute("freeps.operator", s.Operator), otlpAttribute("freeps.function", s.Function))
+		}
+		// 0: unset, 1: ok, 2: error
+		status := map[string]interface{}{"code": 1}
+		if s.Status == SpanError {
+			status = map[string]interface{}{"code": 2, "message": s.Error}
+		} else if s.Status == SpanSkipped {
+			status = map[string]interface{}{"code": 0, "message": SpanSkipped}
+		}
+		otlpSpan := map[string]interface{}{
+			"traceId":           strings.ReplaceAll(s.TraceID, "-", ""),
+			"spanId":            s.SpanID,
+			"name":              s.Name,
+			"kind":              1, // internal
+			"startTimeUnixNano": fmt.Sprint(s.Start.UnixNano()),
+			"endTimeUnixNano":   fmt.Sprint(s.End.UnixNano()),
+			"attributes":        attributes,
+			"status":            status,
+		}
+		if s.ParentSpanID != "" {
+			otlpSpan["parentSpanId"] = s.ParentSpanID
+		}
+		otlpSpans = append(otlpSpans, otlpSpan)
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource":   map[string]interface{}{"attributes": []interface{}{otlpAttribute("service.name", "freeps")}},
+				"scopeSpans": []interface{}{map[string]interface{}{"scope": map[string]interface{}{"name": "freepsflow"}, "spans": otlpSpans}},
+			},
+		},
+	}
+}
@@ -36,6 +36,7 @@ func (tkl *TopKList) Add(value string, timestamp time.Time) *string {
 	if len(tkl.entries) > tkl.maxLen {
 		// if timestamp is older than the oldest entry, we can skip the insert and return the value
 		if timestamp.Before(tkl.entries[0].Timestamp) {
+			tkl.entries = tkl.entries[:len(tkl.entries)-1]
 			return &value
 		}
 
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestTopKList(t *testing.T) {
+	start := time.Now()
+	at := func(minutes int) time.Time {
+		return start.Add(time.Duration(minutes) * time.Minute)
+	}
+
+	tkl := NewTopKList(2)
+	assert.Assert(t, tkl.Add("b", at(2)) == nil)
+	assert.Assert(t, tkl.Add("c", at(3)) == nil)
+
+	/* an entry older than all others is rejected right away and must not stay in the list */
+	removed := tkl.Add("a", at(1))
+	assert.Assert(t, removed != nil)
+	assert.Equal(t, *removed, "a")
+	assert.Equal(t, len(tkl.entries), 2)
+
+	removed = tkl.Add("d", at(4))
+	assert.Assert(t, removed != nil)
+	assert.Equal(t, *removed, "b")
+	removed = tkl.Add("e", at(5))
+	assert.Assert(t, removed != nil)
+	assert.Equal(t, *removed, "c")
+}